@@ -0,0 +1,30 @@
+package main
+
+import "fmt"
+
+// actionFlag is a command line flag that replaces activating the matched
+// window with another action.
+type actionFlag struct {
+	name   string // flag name, without the leading dashes
+	action string // action name understood by the KWin script
+	set    bool
+}
+
+// pickAction returns the action selected by flags, or "" to activate the
+// matched window as usual. At most one action flag may be set.
+func pickAction(flags []actionFlag) (string, error) {
+	var picked *actionFlag
+	for i := range flags {
+		if !flags[i].set {
+			continue
+		}
+		if picked != nil {
+			return "", fmt.Errorf("--%s and --%s cannot be used together", picked.name, flags[i].name)
+		}
+		picked = &flags[i]
+	}
+	if picked == nil {
+		return "", nil
+	}
+	return picked.action, nil
+}
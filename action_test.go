@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestPickAction(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   []actionFlag
+		want    string
+		wantErr string
+	}{
+		{name: "no flags", want: ""},
+		{
+			name:  "none set",
+			flags: []actionFlag{{name: "raise-only", action: "raise"}, {name: "close", action: "close"}},
+			want:  "",
+		},
+		{
+			name:  "one set",
+			flags: []actionFlag{{name: "raise-only", action: "raise"}, {name: "close", action: "close", set: true}},
+			want:  "close",
+		},
+		{
+			name:    "two set",
+			flags:   []actionFlag{{name: "raise-only", action: "raise", set: true}, {name: "close", action: "close", set: true}},
+			wantErr: "--raise-only and --close cannot be used together",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pickAction(tt.flags)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("pickAction: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("pickAction = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import "strings"
+
+// activationToken returns the activation token jumpkwapp was started with,
+// if any. Launchers such as KDE's global shortcuts hand one to the commands
+// they run so the window they open may take focus. jumpkwapp opens no window
+// of its own, so it passes the token on to what it launches.
+//
+// A fresh token cannot be obtained here: KWin only grants ones that may take
+// focus to the focused Wayland client, which jumpkwapp is not. The KWin script
+// activates the new window when it appears, so it comes up focused either way.
+func activationToken(getenv func(string) string) string {
+	return firstNonEmpty(getenv("XDG_ACTIVATION_TOKEN"), getenv("DESKTOP_STARTUP_ID"))
+}
+
+// activationEnv returns environ with token set both as XDG_ACTIVATION_TOKEN,
+// read by Wayland clients, and DESKTOP_STARTUP_ID, read by X11 ones. environ
+// is returned unchanged when there is no token.
+func activationEnv(environ []string, token string) []string {
+	if token == "" {
+		return environ
+	}
+	env := make([]string, 0, len(environ)+2)
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, "XDG_ACTIVATION_TOKEN=") && !strings.HasPrefix(kv, "DESKTOP_STARTUP_ID=") {
+			env = append(env, kv)
+		}
+	}
+	return append(env, "XDG_ACTIVATION_TOKEN="+token, "DESKTOP_STARTUP_ID="+token)
+}
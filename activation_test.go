@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestActivationToken(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want string
+	}{
+		{env: nil, want: ""},
+		{env: map[string]string{"DESKTOP_STARTUP_ID": "x11-id"}, want: "x11-id"},
+		{env: map[string]string{"XDG_ACTIVATION_TOKEN": "wl-token", "DESKTOP_STARTUP_ID": "x11-id"}, want: "wl-token"},
+	}
+	for _, tt := range tests {
+		if got := activationToken(func(key string) string { return tt.env[key] }); got != tt.want {
+			t.Errorf("activationToken(%v) = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestActivationEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		environ []string
+		token   string
+		want    []string
+	}{
+		{
+			name:    "no token",
+			environ: []string{"HOME=/home/me", "DESKTOP_STARTUP_ID=stale"},
+			want:    []string{"HOME=/home/me", "DESKTOP_STARTUP_ID=stale"},
+		},
+		{
+			name:    "token added",
+			environ: []string{"HOME=/home/me"},
+			token:   "abc",
+			want:    []string{"HOME=/home/me", "XDG_ACTIVATION_TOKEN=abc", "DESKTOP_STARTUP_ID=abc"},
+		},
+		{
+			name:    "old values replaced",
+			environ: []string{"XDG_ACTIVATION_TOKEN=old", "HOME=/home/me", "DESKTOP_STARTUP_ID=old"},
+			token:   "abc",
+			want:    []string{"HOME=/home/me", "XDG_ACTIVATION_TOKEN=abc", "DESKTOP_STARTUP_ID=abc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := activationEnv(tt.environ, tt.token); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("activationEnv = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
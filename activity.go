@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	activityManagerService = "org.kde.ActivityManager"
+	activityManagerPath    = "/ActivityManager/Activities"
+	activityManagerIface   = "org.kde.ActivityManager.Activities"
+)
+
+// resolveActivity returns the id of the KDE activity spec refers to, either
+// by id or by name. KWin scripts only know activities by id, so names are
+// looked up through the activity manager on the session bus.
+func resolveActivity(conn busConn, spec string) (string, error) {
+	obj := conn.Object(activityManagerService, dbus.ObjectPath(activityManagerPath))
+	var ids []string
+	if err := obj.Call(activityManagerIface+".ListActivities", 0).Store(&ids); err != nil {
+		return "", fmt.Errorf("list activities: %w", err)
+	}
+	var names []string
+	for _, id := range ids {
+		if id == spec {
+			return id, nil
+		}
+		var name string
+		if err := obj.Call(activityManagerIface+".ActivityName", 0, id).Store(&name); err != nil {
+			return "", fmt.Errorf("look up name of activity %s: %w", id, err)
+		}
+		if name == spec {
+			return id, nil
+		}
+		names = append(names, name)
+	}
+	return "", fmt.Errorf("no activity with id or name %q (activities: %s)", spec, strings.Join(names, ", "))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// activityBus answers the activity manager's calls with the activities
+// listed in names, keyed by id.
+type activityBus struct {
+	ids   []string
+	names map[string]string
+}
+
+func (b activityBus) BusObject() dbus.BusObject {
+	return fakeBusObject{reply: func(method string, args ...any) ([]any, error) {
+		return nil, errors.New("unexpected call " + method)
+	}}
+}
+
+func (b activityBus) Object(dest string, path dbus.ObjectPath) dbus.BusObject {
+	return fakeBusObject{reply: func(method string, args ...any) ([]any, error) {
+		if dest != activityManagerService || path != activityManagerPath {
+			return nil, errors.New("unexpected call " + method)
+		}
+		switch method {
+		case activityManagerIface + ".ListActivities":
+			return []any{b.ids}, nil
+		case activityManagerIface + ".ActivityName":
+			return []any{b.names[args[0].(string)]}, nil
+		}
+		return nil, errors.New("unexpected call " + method)
+	}}
+}
+
+func TestResolveActivity(t *testing.T) {
+	bus := activityBus{
+		ids:   []string{"a1b2", "c3d4"},
+		names: map[string]string{"a1b2": "Work", "c3d4": "Play"},
+	}
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr string
+	}{
+		{spec: "c3d4", want: "c3d4"},
+		{spec: "Work", want: "a1b2"},
+		{spec: "Games", wantErr: "activities: Work, Play"},
+	}
+	for _, tt := range tests {
+		got, err := resolveActivity(bus, tt.spec)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("resolveActivity(%q): got error %v, want one containing %q", tt.spec, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolveActivity(%q) = %q, %v, want %q", tt.spec, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fileConfig is the optional on-disk configuration, read from --config or
+// $XDG_CONFIG_HOME/jumpkwapp/config.json.
+type fileConfig struct {
+	Profiles map[string]profile `json:"profiles"`
+	// Groups are named lists of window classes, used as -f @name.
+	Groups map[string][]string `json:"groups"`
+	// Ignore lists windows that no invocation should ever match.
+	Ignore ignoreList `json:"ignore"`
+}
+
+// ignoreList holds regular expressions for windows to skip globally, on top
+// of any --exclude-class and --exclude-caption flags.
+type ignoreList struct {
+	Class   []string `json:"class"`
+	Caption []string `json:"caption"`
+}
+
+// profile is a named set of filters selected with --profile.
+type profile struct {
+	Filter             string   `json:"filter"`
+	FilterAlternative  string   `json:"filter-alternative"`
+	FilterRegex        string   `json:"filter-regex"`
+	Glob               string   `json:"glob"`
+	Title              string   `json:"title"`
+	CaptionExact       string   `json:"caption-exact"`
+	Cmdline            string   `json:"cmdline"`
+	DesktopFile        string   `json:"desktop-file"`
+	Name               string   `json:"name"`
+	Role               string   `json:"role"`
+	Match              string   `json:"match"`
+	ExcludeClass       []string `json:"exclude-class"`
+	ExcludeCaption     []string `json:"exclude-caption"`
+	SmartClass         bool     `json:"smart-class"`
+	AllFilters         bool     `json:"all-filters"`
+	Desktop            string   `json:"desktop"`
+	CurrentDesktop     bool     `json:"current-desktop"`
+	CurrentActivity    bool     `json:"current-activity"`
+	CurrentScreen      bool     `json:"current-screen"`
+	IncludeSkipTaskbar bool     `json:"include-skip-taskbar"`
+	OnlyTaskbar        bool     `json:"only-taskbar"` // deprecated; accepted with a warning
+	Command            string   `json:"command"`
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "jumpkwapp", "config.json")
+}
+
+// loadFileConfig reads the config file at path, or the default location when
+// path is empty. A missing file is only an error when path was given explicitly.
+func loadFileConfig(path string) (fileConfig, error) {
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigPath()
+		if path == "" {
+			return fileConfig{}, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return fileConfig{}, nil
+		}
+		return fileConfig{}, fmt.Errorf("read config: %w", err)
+	}
+
+	var fc fileConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fc); err != nil {
+		return fileConfig{}, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return fc, nil
+}
+
+// resolveProfile expands cfg.profile into cfg. Filters given on the command
+// line replace the profile's filters entirely rather than mixing with them;
+// the command is only taken from the profile when no command was given.
+func resolveProfile(cfg config, fc fileConfig) (config, error) {
+	if cfg.profile == "" {
+		return cfg, nil
+	}
+	p, ok := fc.Profiles[cfg.profile]
+	if !ok {
+		names := sortedKeys(fc.Profiles)
+		if len(names) == 0 {
+			return cfg, fmt.Errorf("unknown profile %q: no profiles are defined in the config file", cfg.profile)
+		}
+		return cfg, fmt.Errorf("unknown profile %q (defined: %s)", cfg.profile, strings.Join(names, ", "))
+	}
+
+	if !cfg.hasFilter() {
+		cfg.filterClasses = nil
+		if p.Filter != "" {
+			cfg.filterClasses = []string{p.Filter}
+		}
+		cfg.filterAlt = p.FilterAlternative
+		cfg.filterRegex = p.FilterRegex
+		if p.Glob != "" {
+			if p.FilterRegex != "" {
+				return cfg, fmt.Errorf("profile %q sets both glob and filter-regex", cfg.profile)
+			}
+			cfg.filterRegex = globToRegex(p.Glob)
+		}
+		cfg.filterTitle = p.Title
+		cfg.captionExact = p.CaptionExact
+		cfg.cmdline = p.Cmdline
+		cfg.desktopFile = strings.TrimSuffix(p.DesktopFile, ".desktop")
+		cfg.resourceName = p.Name
+		cfg.role = p.Role
+		cfg.match = p.Match
+		cfg.excludeClasses = p.ExcludeClass
+		cfg.excludeCaptions = p.ExcludeCaption
+	}
+	cfg.smartClass = cfg.smartClass || p.SmartClass
+	cfg.allFilters = cfg.allFilters || p.AllFilters
+	if cfg.desktop == "" && !cfg.currentDesktop {
+		cfg.desktop = p.Desktop
+	}
+	cfg.currentDesktop = cfg.currentDesktop || (p.CurrentDesktop && cfg.desktop == "")
+	cfg.currentActivity = cfg.currentActivity || (p.CurrentActivity && cfg.activity == "")
+	cfg.currentScreen = cfg.currentScreen || (p.CurrentScreen && cfg.screen == "")
+	cfg.includeSkipTaskbar = cfg.includeSkipTaskbar || p.IncludeSkipTaskbar
+	cfg.onlyTaskbar = cfg.onlyTaskbar || p.OnlyTaskbar
+	if !cfg.canLaunch() {
+		cfg.commands = launchCommands(p.Command, nil)
+	}
+
+	if cfg.captionFilters() > 1 {
+		return cfg, fmt.Errorf("profile %q sets more than one of filter-alternative, title, and caption-exact", cfg.profile)
+	}
+	return cfg, nil
+}
+
+// expandGroups replaces every -f class of the form @name with the classes of
+// the named group from the config file, keeping their order so the group's
+// earlier classes are preferred.
+func expandGroups(cfg config, fc fileConfig) (config, error) {
+	var classes []string
+	for _, class := range cfg.filterClasses {
+		name, ok := strings.CutPrefix(class, "@")
+		if !ok {
+			classes = append(classes, class)
+			continue
+		}
+		group, ok := fc.Groups[name]
+		if !ok {
+			return cfg, fmt.Errorf("unknown group %q (defined: %s)", name, strings.Join(sortedKeys(fc.Groups), ", "))
+		}
+		classes = append(classes, group...)
+	}
+	cfg.filterClasses = classes
+	return cfg, nil
+}
+
+// applyIgnoreList adds the config file's global ignore list to the
+// exclusion filters of cfg.
+func applyIgnoreList(cfg config, fc fileConfig) config {
+	cfg.excludeClassRegexes = append(cfg.excludeClassRegexes, fc.Ignore.Class...)
+	cfg.excludeCaptions = append(cfg.excludeCaptions, fc.Ignore.Caption...)
+	return cfg
+}
+
+// defaultOwnDesktop names the desktop of --own-desktop after the first class
+// when no name was given. It runs after expandGroups and resolveProfile, so
+// -f @group and a profile's filter give the class rather than the group name.
+func defaultOwnDesktop(cfg config) (config, error) {
+	if cfg.action != "own-desktop" || cfg.sendToDesktop != "" {
+		return cfg, nil
+	}
+	if len(cfg.filterClasses) == 0 {
+		return cfg, errors.New("--own-desktop needs a name (--own-desktop=NAME) when no -f class is given")
+	}
+	cfg.sendToDesktop = cfg.filterClasses[0]
+	return cfg, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
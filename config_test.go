@@ -0,0 +1,252 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFileConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if _, err := loadFileConfig(""); err != nil {
+		t.Errorf("missing default config: %v", err)
+	}
+	if _, err := loadFileConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing --config file: got no error")
+	}
+	if _, err := loadFileConfig(writeConfig(t, `{"profiles": {"term": {"filtr": "konsole"}}}`)); err == nil {
+		t.Error("unknown key: got no error")
+	}
+
+	fc, err := loadFileConfig(writeConfig(t, `{"profiles": {"term": {"filter": "konsole", "current-desktop": true}}}`))
+	if err != nil {
+		t.Fatalf("loadFileConfig: %v", err)
+	}
+	if p := fc.Profiles["term"]; p.Filter != "konsole" || !p.CurrentDesktop {
+		t.Errorf("profile term = %+v", p)
+	}
+
+	fc, err = loadFileConfig(writeConfig(t, `{"profiles": {"term": {"filter": "konsole", "only-taskbar": true}}}`))
+	if err != nil {
+		t.Fatalf("the deprecated only-taskbar key is no longer accepted: %v", err)
+	}
+	if cfg, err := resolveProfile(config{profile: "term"}, fc); err != nil || !cfg.onlyTaskbar {
+		t.Errorf("only-taskbar profile: onlyTaskbar = %v, err = %v, want it noted for the warning", cfg.onlyTaskbar, err)
+	}
+
+	fc, err = loadFileConfig(writeConfig(t, `{"ignore": {"class": ["^plasmashell$"], "caption": ["Picture-in-Picture"]}}`))
+	if err != nil {
+		t.Fatalf("loadFileConfig: %v", err)
+	}
+	if want := (ignoreList{Class: []string{"^plasmashell$"}, Caption: []string{"Picture-in-Picture"}}); !reflect.DeepEqual(fc.Ignore, want) {
+		t.Errorf("ignore = %+v, want %+v", fc.Ignore, want)
+	}
+}
+
+func TestExpandGroups(t *testing.T) {
+	fc := fileConfig{Groups: map[string][]string{
+		"terminals": {"konsole", "yakuake"},
+		"editors":   {"kate", "gvim"},
+	}}
+	tests := []struct {
+		name    string
+		classes []string
+		want    []string
+		wantErr string
+	}{
+		{name: "no classes", classes: nil, want: nil},
+		{name: "plain classes", classes: []string{"firefox"}, want: []string{"firefox"}},
+		{name: "group", classes: []string{"@terminals"}, want: []string{"konsole", "yakuake"}},
+		{
+			name:    "groups and classes keep their order",
+			classes: []string{"alacritty", "@editors", "@terminals"},
+			want:    []string{"alacritty", "kate", "gvim", "konsole", "yakuake"},
+		},
+		{name: "unknown group", classes: []string{"@browsers"}, wantErr: `unknown group "browsers" (defined: editors, terminals)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandGroups(config{filterClasses: tt.classes}, fc)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expandGroups: %v", err)
+			}
+			if !reflect.DeepEqual(got.filterClasses, tt.want) {
+				t.Errorf("filterClasses = %q, want %q", got.filterClasses, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyIgnoreList(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          config
+		ignore       ignoreList
+		wantClasses  []string
+		wantCaptions []string
+	}{
+		{name: "empty", cfg: config{}, ignore: ignoreList{}},
+		{
+			name:         "ignore list only",
+			cfg:          config{},
+			ignore:       ignoreList{Class: []string{"^plasmashell$"}, Caption: []string{"Picture-in-Picture"}},
+			wantClasses:  []string{"^plasmashell$"},
+			wantCaptions: []string{"Picture-in-Picture"},
+		},
+		{
+			name:         "added to the flags",
+			cfg:          config{excludeCaptions: []string{"^write:"}},
+			ignore:       ignoreList{Caption: []string{"Picture-in-Picture"}},
+			wantCaptions: []string{"^write:", "Picture-in-Picture"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := applyIgnoreList(tt.cfg, fileConfig{Ignore: tt.ignore})
+			if !reflect.DeepEqual(got.excludeClassRegexes, tt.wantClasses) {
+				t.Errorf("excludeClassRegexes = %q, want %q", got.excludeClassRegexes, tt.wantClasses)
+			}
+			if !reflect.DeepEqual(got.excludeCaptions, tt.wantCaptions) {
+				t.Errorf("excludeCaptions = %q, want %q", got.excludeCaptions, tt.wantCaptions)
+			}
+		})
+	}
+}
+
+func TestResolveProfile(t *testing.T) {
+	fc := fileConfig{Profiles: map[string]profile{
+		"term": {Filter: "konsole", CurrentDesktop: true, Command: " konsole "},
+		"mail": {Title: "Inbox", FilterAlternative: "Mail"},
+		"web":  {DesktopFile: "org.mozilla.firefox.desktop", Desktop: "2"},
+		"ide":  {Glob: "jetbrains-*"},
+		"bad":  {Glob: "jetbrains-*", FilterRegex: "^jetbrains"},
+	}}
+	tests := []struct {
+		name    string
+		cfg     config
+		want    config
+		wantErr string
+	}{
+		{
+			name: "no profile",
+			cfg:  config{filterClasses: []string{"kate"}},
+			want: config{filterClasses: []string{"kate"}},
+		},
+		{
+			name: "profile fills filters and command",
+			cfg:  config{profile: "term"},
+			want: config{profile: "term", filterClasses: []string{"konsole"}, currentDesktop: true, commands: []string{"konsole"}},
+		},
+		{
+			name: "command line filters replace the profile's",
+			cfg:  config{profile: "term", filterRegex: "^yakuake$", commands: []string{"yakuake"}},
+			want: config{profile: "term", filterRegex: "^yakuake$", currentDesktop: true, commands: []string{"yakuake"}},
+		},
+		{
+			name: "desktop file",
+			cfg:  config{profile: "web"},
+			want: config{profile: "web", desktopFile: "org.mozilla.firefox", desktop: "2"},
+		},
+		{
+			name: "current desktop replaces the profile's desktop",
+			cfg:  config{profile: "web", currentDesktop: true},
+			want: config{profile: "web", desktopFile: "org.mozilla.firefox", currentDesktop: true},
+		},
+		{
+			name: "desktop replaces the profile's current desktop",
+			cfg:  config{profile: "term", desktop: "Two"},
+			want: config{profile: "term", filterClasses: []string{"konsole"}, desktop: "Two", commands: []string{"konsole"}},
+		},
+		{
+			name:    "unknown profile",
+			cfg:     config{profile: "editor"},
+			wantErr: "defined: bad, ide, mail, term, web",
+		},
+		{
+			name: "glob",
+			cfg:  config{profile: "ide"},
+			want: config{profile: "ide", filterRegex: "^jetbrains-.*$"},
+		},
+		{
+			name:    "glob and regex",
+			cfg:     config{profile: "bad"},
+			wantErr: "sets both glob and filter-regex",
+		},
+		{
+			name:    "conflicting caption filters",
+			cfg:     config{profile: "mail"},
+			wantErr: "sets more than one of filter-alternative, title, and caption-exact",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveProfile(tt.cfg, fc)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveProfile: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveProfile = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultOwnDesktop(t *testing.T) {
+	fc := fileConfig{
+		Groups:   map[string][]string{"terminals": {"konsole", "yakuake"}},
+		Profiles: map[string]profile{"mail": {Filter: "thunderbird"}},
+	}
+	tests := []struct {
+		name    string
+		cfg     config
+		want    string
+		wantErr bool
+	}{
+		{name: "first class", cfg: config{action: "own-desktop", filterClasses: []string{"kate", "gvim"}}, want: "kate"},
+		{name: "group", cfg: config{action: "own-desktop", filterClasses: []string{"@terminals"}}, want: "konsole"},
+		{name: "profile", cfg: config{action: "own-desktop", profile: "mail"}, want: "thunderbird"},
+		{name: "named", cfg: config{action: "own-desktop", sendToDesktop: "Work", filterClasses: []string{"@terminals"}}, want: "Work"},
+		{name: "no class", cfg: config{action: "own-desktop", filterAlt: "Inbox"}, wantErr: true},
+		{name: "other action", cfg: config{action: "raise", filterClasses: []string{"kate"}}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := resolveProfile(tt.cfg, fc)
+			if err == nil {
+				cfg, err = expandGroups(cfg, fc)
+			}
+			if err == nil {
+				cfg, err = defaultOwnDesktop(cfg)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.sendToDesktop != tt.want {
+				t.Errorf("desktop = %q, want %q", cfg.sendToDesktop, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// launchLockPath returns the lock file shared by every invocation launching
+// command, next to the state files; see stateDir.
+func launchLockPath(command string) string {
+	sum := sha256.Sum256([]byte(command))
+	return filepath.Join(stateDir(), "jumpkwapp-launch-"+hex.EncodeToString(sum[:8])+".lock")
+}
+
+// claimLaunch reports whether this invocation may launch command, given that
+// launches of the same command within window of each other are collapsed
+// into one. The lock file holds the time of the last launch; it is read and
+// updated under an exclusive flock so two near-simultaneous invocations
+// cannot both claim the launch.
+func claimLaunch(lockPath string, window time.Duration, now time.Time) (bool, error) {
+	f, err := openStateFile(lockPath, os.O_RDWR|os.O_CREATE)
+	if err != nil {
+		return false, fmt.Errorf("open launch lock: %w", err)
+	}
+	defer f.Close()
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return false, fmt.Errorf("lock %s: %w", lockPath, err)
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return false, fmt.Errorf("read launch lock: %w", err)
+	}
+	if last, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
+		// Each invocation reads the clock before waiting for the lock, so the
+		// last launch may be recorded as slightly later than now.
+		since := now.Sub(time.Unix(0, last))
+		if since < 0 {
+			since = -since
+		}
+		if since < window {
+			return false, nil
+		}
+	}
+
+	if err := f.Truncate(0); err != nil {
+		return false, fmt.Errorf("reset launch lock: %w", err)
+	}
+	if _, err := f.WriteAt([]byte(strconv.FormatInt(now.UnixNano(), 10)), 0); err != nil {
+		return false, fmt.Errorf("write launch lock: %w", err)
+	}
+	return true, nil
+}
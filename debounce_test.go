@@ -0,0 +1,83 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClaimLaunch(t *testing.T) {
+	lock := filepath.Join(t.TempDir(), "launch.lock")
+	start := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		at   time.Duration
+		want bool
+	}{
+		{0, true},
+		{500 * time.Millisecond, false},
+		{1999 * time.Millisecond, false},
+		{2 * time.Second, true},
+		{1500 * time.Millisecond, false},
+		{-time.Second, true},
+	}
+	for _, tt := range tests {
+		got, err := claimLaunch(lock, 2*time.Second, start.Add(tt.at))
+		if err != nil {
+			t.Fatalf("claimLaunch at %s: %v", tt.at, err)
+		}
+		if got != tt.want {
+			t.Errorf("claimLaunch at %s = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestClaimLaunchConcurrent(t *testing.T) {
+	lock := filepath.Join(t.TempDir(), "launch.lock")
+	now := time.Now()
+
+	const invocations = 2
+	var wg sync.WaitGroup
+	claimed := make(chan bool, invocations)
+	for i := 0; i < invocations; i++ {
+		wg.Add(1)
+		// Each invocation reads the clock at a slightly different time and
+		// may take the lock in either order.
+		go func(i int) {
+			defer wg.Done()
+			ok, err := claimLaunch(lock, time.Second, now.Add(time.Duration(i)*time.Millisecond))
+			if err != nil {
+				t.Error(err)
+			}
+			claimed <- ok
+		}(i)
+	}
+	wg.Wait()
+	close(claimed)
+
+	n := 0
+	for ok := range claimed {
+		if ok {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("%d of %d near-simultaneous invocations claimed the launch, want 1", n, invocations)
+	}
+}
+
+func TestLaunchLockPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	a, b := launchLockPath("konsole"), launchLockPath("dolphin")
+	if filepath.Dir(a) != dir {
+		t.Errorf("lock %s is not in $XDG_RUNTIME_DIR", a)
+	}
+	if a == b {
+		t.Errorf("different commands share the lock %s", a)
+	}
+	if a != launchLockPath("konsole") {
+		t.Error("the same command got a different lock")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const freedesktopApplicationIface = "org.freedesktop.Application"
+
+// desktopEntry is the part of an XDG desktop entry needed to launch it.
+type desktopEntry struct {
+	ID              string
+	File            string
+	Name            string
+	Exec            string
+	Icon            string
+	WorkDir         string
+	Terminal        bool
+	DBusActivatable bool
+}
+
+// applicationDirs returns the directories desktop entries are looked up in,
+// most important first, as the XDG base directory spec orders them.
+func applicationDirs(getenv func(string) string) []string {
+	dataHome := getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(getenv("HOME"), ".local", "share")
+	}
+	dataDirs := getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+	var dirs []string
+	for _, dir := range append([]string{dataHome}, filepath.SplitList(dataDirs)...) {
+		if dir != "" {
+			dirs = append(dirs, filepath.Join(dir, "applications"))
+		}
+	}
+	return dirs
+}
+
+// findDesktopEntry returns the path of the desktop entry with the given ID,
+// such as org.kde.konsole.desktop; the suffix may be left out. A path to a
+// .desktop file is returned as is.
+func findDesktopEntry(id string, getenv func(string) string) (string, error) {
+	if strings.Contains(id, "/") {
+		return id, nil
+	}
+	if !strings.HasSuffix(id, ".desktop") {
+		id += ".desktop"
+	}
+	dirs := applicationDirs(getenv)
+	for _, dir := range dirs {
+		path := filepath.Join(dir, id)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no desktop entry %s in %s", id, strings.Join(dirs, ", "))
+}
+
+// parseDesktopEntry reads the [Desktop Entry] group of the file at path.
+// Localized keys are skipped, as only the untranslated name is used.
+func parseDesktopEntry(path string) (desktopEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return desktopEntry{}, err
+	}
+	defer f.Close()
+
+	entry := desktopEntry{
+		ID:   strings.TrimSuffix(filepath.Base(path), ".desktop"),
+		File: path,
+	}
+	inGroup := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			inGroup = line == "[Desktop Entry]"
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !inGroup || !ok {
+			continue
+		}
+		value = unescapeDesktopValue(strings.TrimSpace(value))
+		switch strings.TrimSpace(key) {
+		case "Name":
+			entry.Name = value
+		case "Exec":
+			entry.Exec = value
+		case "Icon":
+			entry.Icon = value
+		case "Path":
+			entry.WorkDir = value
+		case "Terminal":
+			entry.Terminal = value == "true"
+		case "DBusActivatable":
+			entry.DBusActivatable = value == "true"
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return desktopEntry{}, fmt.Errorf("read %s: %w", path, err)
+	}
+	return entry, nil
+}
+
+// unescapeDesktopValue undoes the escapes allowed in desktop entry string
+// values. Exec has its own quoting on top, which splitExec handles.
+func unescapeDesktopValue(value string) string {
+	return strings.NewReplacer(`\s`, " ", `\n`, "\n", `\t`, "\t", `\r`, "\r", `\\`, `\`).Replace(value)
+}
+
+// splitExec splits an Exec value into arguments. Arguments are separated by
+// spaces and may be double-quoted, in which case a backslash escapes ", `, $
+// and \.
+func splitExec(value string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg, quoted := false, false
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quoted && c == '\\' && i+1 < len(value) && strings.IndexByte("\"`$\\", value[i+1]) >= 0:
+			i++
+			arg.WriteByte(value[i])
+		case c == '"':
+			quoted = !quoted
+			inArg = true
+		case !quoted && (c == ' ' || c == '\t'):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteByte(c)
+			inArg = true
+		}
+	}
+	if quoted {
+		return nil, errors.New("unterminated quote")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// command returns the program and arguments the entry's Exec value runs,
+// with its field codes expanded. jumpkwapp opens no files or URLs, so the
+// codes standing for them are dropped.
+func (e desktopEntry) command() ([]string, error) {
+	fields, err := splitExec(e.Exec)
+	if err != nil {
+		return nil, fmt.Errorf("%s: Exec: %w", e.File, err)
+	}
+	var args []string
+	for _, field := range fields {
+		switch field {
+		case "%f", "%F", "%u", "%U", "%d", "%D", "%n", "%N", "%v", "%m":
+			continue
+		case "%i":
+			if e.Icon != "" {
+				args = append(args, "--icon", e.Icon)
+			}
+			continue
+		}
+		var expanded strings.Builder
+		for i := 0; i < len(field); i++ {
+			if field[i] != '%' || i+1 == len(field) {
+				expanded.WriteByte(field[i])
+				continue
+			}
+			i++
+			switch field[i] {
+			case '%':
+				expanded.WriteByte('%')
+			case 'c':
+				expanded.WriteString(e.Name)
+			case 'k':
+				expanded.WriteString(e.File)
+			}
+		}
+		args = append(args, expanded.String())
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s has no Exec command", e.File)
+	}
+	return args, nil
+}
+
+// applicationObjectPath is where a D-Bus activatable application exports
+// org.freedesktop.Application: its bus name with dots as slashes and dashes
+// as underscores.
+func applicationObjectPath(id string) dbus.ObjectPath {
+	return dbus.ObjectPath("/" + strings.NewReplacer(".", "/", "-", "_").Replace(id))
+}
+
+// launchDesktopEntry starts the application of the desktop entry with the
+// given ID the way a launcher would: through D-Bus activation when the entry
+// asks for it, otherwise by running its Exec command directly, without a
+// shell, in its working directory.
+func launchDesktopEntry(conn busConn, id string, detachIO bool) error {
+	path, err := findDesktopEntry(id, os.Getenv)
+	if err != nil {
+		return err
+	}
+	entry, err := parseDesktopEntry(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no desktop entry %s", path)
+	}
+	if err != nil {
+		return err
+	}
+	if entry.DBusActivatable {
+		token := activationToken(os.Getenv)
+		platformData := map[string]dbus.Variant{}
+		if token != "" {
+			platformData["activation-token"] = dbus.MakeVariant(token)
+			platformData["desktop-startup-id"] = dbus.MakeVariant(token)
+		}
+		obj := conn.Object(entry.ID, applicationObjectPath(entry.ID))
+		return obj.Call(freedesktopApplicationIface+".Activate", 0, platformData).Err
+	}
+	if entry.Terminal {
+		return fmt.Errorf("%s runs in a terminal, which --launch-desktop does not support", path)
+	}
+	args, err := entry.command()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = entry.WorkDir
+	return startProcess(cmd, detachIO)
+}
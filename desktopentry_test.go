@@ -0,0 +1,236 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestSplitExec(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "konsole", want: []string{"konsole"}},
+		{value: "  firefox   --new-window  %u ", want: []string{"firefox", "--new-window", "%u"}},
+		{value: `"/opt/My App/app" --flag`, want: []string{"/opt/My App/app", "--flag"}},
+		{value: `sh -c "echo \"hi\" \$HOME \\ \` + "`x`" + `"`, want: []string{"sh", "-c", "echo \"hi\" $HOME \\ `x`"}},
+		{value: `app ""`, want: []string{"app", ""}},
+		{value: "app\t--tab", want: []string{"app", "--tab"}},
+		{value: `app "unterminated`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := splitExec(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("splitExec(%q) = %q, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitExec(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestDesktopEntryCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   desktopEntry
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "file and URL codes are dropped",
+			entry: desktopEntry{Exec: "firefox %u --private %F"},
+			want:  []string{"firefox", "--private"},
+		},
+		{
+			name:  "icon",
+			entry: desktopEntry{Exec: "app %i", Icon: "app-icon"},
+			want:  []string{"app", "--icon", "app-icon"},
+		},
+		{
+			name:  "no icon",
+			entry: desktopEntry{Exec: "app %i"},
+			want:  []string{"app"},
+		},
+		{
+			name:  "name, file and percent",
+			entry: desktopEntry{Exec: "app --title=%c --from=%k 100%%", Name: "My App", File: "/usr/share/applications/app.desktop"},
+			want:  []string{"app", "--title=My App", "--from=/usr/share/applications/app.desktop", "100%"},
+		},
+		{
+			name:    "only field codes",
+			entry:   desktopEntry{Exec: "%U"},
+			wantErr: true,
+		},
+		{
+			name:    "bad quoting",
+			entry:   desktopEntry{Exec: `"app`},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.entry.command()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("command() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("command() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindDesktopEntry(t *testing.T) {
+	home := t.TempDir()
+	system := t.TempDir()
+	for _, path := range []string{
+		filepath.Join(home, "applications", "org.kde.konsole.desktop"),
+		filepath.Join(system, "applications", "org.kde.konsole.desktop"),
+		filepath.Join(system, "applications", "firefox.desktop"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env := map[string]string{"XDG_DATA_HOME": home, "XDG_DATA_DIRS": system}
+	getenv := func(key string) string { return env[key] }
+
+	tests := []struct {
+		id      string
+		want    string
+		wantErr bool
+	}{
+		{id: "org.kde.konsole.desktop", want: filepath.Join(home, "applications", "org.kde.konsole.desktop")},
+		{id: "firefox", want: filepath.Join(system, "applications", "firefox.desktop")},
+		{id: "/somewhere/else.desktop", want: "/somewhere/else.desktop"},
+		{id: "gimp", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := findDesktopEntry(tt.id, getenv)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("findDesktopEntry(%q) = %q, %v, want %q", tt.id, got, err, tt.want)
+		}
+	}
+}
+
+func TestApplicationDirs(t *testing.T) {
+	getenv := func(key string) string {
+		if key == "HOME" {
+			return "/home/me"
+		}
+		return ""
+	}
+	want := []string{"/home/me/.local/share/applications", "/usr/local/share/applications", "/usr/share/applications"}
+	if got := applicationDirs(getenv); !reflect.DeepEqual(got, want) {
+		t.Errorf("applicationDirs = %q, want %q", got, want)
+	}
+}
+
+func TestParseDesktopEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "org.example.App.desktop")
+	data := `# comment
+[Desktop Entry]
+Name=Example\sApp
+Name[de]=Beispiel
+Exec=example --new %U
+Icon=example
+Path=/tmp
+Terminal=false
+DBusActivatable=true
+
+[Desktop Action new-window]
+Exec=example --other
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseDesktopEntry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := desktopEntry{
+		ID:              "org.example.App",
+		File:            path,
+		Name:            "Example App",
+		Exec:            "example --new %U",
+		Icon:            "example",
+		WorkDir:         "/tmp",
+		DBusActivatable: true,
+	}
+	if got != want {
+		t.Errorf("parseDesktopEntry = %+v, want %+v", got, want)
+	}
+}
+
+func TestApplicationObjectPath(t *testing.T) {
+	if got, want := applicationObjectPath("org.gnome.Text-Editor"), dbus.ObjectPath("/org/gnome/Text_Editor"); got != want {
+		t.Errorf("applicationObjectPath = %q, want %q", got, want)
+	}
+}
+
+// activationBus records the org.freedesktop.Application calls made on it.
+type activationBus struct {
+	calls *[]string
+}
+
+func (b activationBus) BusObject() dbus.BusObject { return nil }
+
+func (b activationBus) Object(dest string, path dbus.ObjectPath) dbus.BusObject {
+	return fakeBusObject{reply: func(method string, args ...any) ([]any, error) {
+		*b.calls = append(*b.calls, dest+" "+string(path)+" "+method)
+		return nil, nil
+	}}
+}
+
+func TestLaunchDesktopEntry(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	entries := map[string]string{
+		"org.example.Activatable.desktop": "[Desktop Entry]\nExec=false\nDBusActivatable=true\n",
+		"plain.desktop":                   "[Desktop Entry]\nExec=sh -c \"pwd > " + out + ".tmp && mv " + out + ".tmp " + out + "\"\nPath=" + dir + "\n",
+		"terminal.desktop":                "[Desktop Entry]\nExec=htop\nTerminal=true\n",
+	}
+	for name, data := range entries {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var calls []string
+	bus := activationBus{calls: &calls}
+	if err := launchDesktopEntry(bus, filepath.Join(dir, "org.example.Activatable.desktop"), true); err != nil {
+		t.Fatalf("D-Bus activatable entry: %v", err)
+	}
+	if want := []string{"org.example.Activatable /org/example/Activatable org.freedesktop.Application.Activate"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("D-Bus calls = %q, want %q", calls, want)
+	}
+
+	if err := launchDesktopEntry(bus, filepath.Join(dir, "plain.desktop"), true); err != nil {
+		t.Fatalf("plain entry: %v", err)
+	}
+	waitForFile(t, out)
+	if got, err := os.ReadFile(out); err != nil || string(got) != dir+"\n" {
+		t.Errorf("ran in %q, %v, want %s", got, err, dir)
+	}
+
+	if err := launchDesktopEntry(bus, filepath.Join(dir, "terminal.desktop"), true); err == nil {
+		t.Error("terminal entry: want an error")
+	}
+	if err := launchDesktopEntry(bus, filepath.Join(dir, "missing.desktop"), true); err == nil {
+		t.Error("missing entry: want an error")
+	}
+}
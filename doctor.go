@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// busConn is the part of *dbus.Conn the diagnostics need, so the checks can
+// run against a fake connection.
+type busConn interface {
+	BusObject() dbus.BusObject
+	Object(dest string, path dbus.ObjectPath) dbus.BusObject
+}
+
+// doctorEnv is the state shared by all doctor checks. conn is nil when the
+// session bus could not be reached, in which case connErr says why.
+type doctorEnv struct {
+	conn       busConn
+	connErr    error
+	getenv     func(string) string
+	configPath string
+}
+
+type doctorCheck struct {
+	name string
+	run  func(env doctorEnv) (string, error)
+}
+
+var errNoSessionBus = errors.New("skipped: no session bus connection")
+
+var doctorChecks = []doctorCheck{
+	{name: "session type", run: checkSessionType},
+	{name: "session bus", run: checkSessionBus},
+	{name: "KWin on session bus", run: checkKWinName},
+	{name: "KWin scripting interface", run: checkKWinScripting},
+	{name: "config file", run: checkConfigFile},
+}
+
+// doctor connects to the session bus and runs the standard checks against it.
+func doctor(w io.Writer, configPath string) error {
+	env := doctorEnv{getenv: os.Getenv, configPath: configPath}
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		env.connErr = fmt.Errorf("connect to session bus: %w", err)
+	} else {
+		defer conn.Close()
+		env.conn = conn
+	}
+	return runDoctor(w, env, doctorChecks)
+}
+
+// runDoctor runs every check, printing one line per check, and returns an
+// error if any of them failed. It never loads a script into KWin.
+func runDoctor(w io.Writer, env doctorEnv, checks []doctorCheck) error {
+	failed := 0
+	for _, check := range checks {
+		detail, err := check.run(env)
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "[FAIL] %s: %v\n", check.name, err)
+			continue
+		}
+		if detail != "" {
+			fmt.Fprintf(w, "[PASS] %s: %s\n", check.name, detail)
+		} else {
+			fmt.Fprintf(w, "[PASS] %s\n", check.name)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+func checkSessionType(env doctorEnv) (string, error) {
+	sessionType := env.getenv("XDG_SESSION_TYPE")
+	if sessionType == "" {
+		return "", errors.New("XDG_SESSION_TYPE is not set; is this running inside a desktop session?")
+	}
+	desktop := env.getenv("XDG_CURRENT_DESKTOP")
+	if !strings.Contains(strings.ToUpper(desktop), "KDE") {
+		return "", fmt.Errorf("%s session, but XDG_CURRENT_DESKTOP is %q rather than KDE", sessionType, desktop)
+	}
+	return sessionType + ", " + desktop, nil
+}
+
+func checkSessionBus(env doctorEnv) (string, error) {
+	if env.conn == nil {
+		return "", env.connErr
+	}
+	return "", nil
+}
+
+func checkKWinName(env doctorEnv) (string, error) {
+	if env.conn == nil {
+		return "", errNoSessionBus
+	}
+	var hasOwner bool
+	if err := env.conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, kwinService).Store(&hasOwner); err != nil {
+		return "", err
+	}
+	if !hasOwner {
+		return "", fmt.Errorf("%s is not registered; is KWin running in this session?", kwinService)
+	}
+	return "", nil
+}
+
+func checkKWinScripting(env doctorEnv) (string, error) {
+	if env.conn == nil {
+		return "", errNoSessionBus
+	}
+	var xml string
+	obj := env.conn.Object(kwinService, dbus.ObjectPath(kwinScriptingPath))
+	if err := obj.Call("org.freedesktop.DBus.Introspectable.Introspect", 0).Store(&xml); err != nil {
+		return "", err
+	}
+	if !strings.Contains(xml, kwinScriptingIface) {
+		return "", fmt.Errorf("%s does not implement %s", kwinScriptingPath, kwinScriptingIface)
+	}
+	return "", nil
+}
+
+func checkConfigFile(env doctorEnv) (string, error) {
+	fc, err := loadFileConfig(env.configPath)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d profiles, %d groups", len(fc.Profiles), len(fc.Groups)), nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeBusObject answers method calls through reply. Methods of dbus.BusObject
+// other than Call are not used by the code under test.
+type fakeBusObject struct {
+	dbus.BusObject
+	reply func(method string, args ...any) ([]any, error)
+}
+
+func (o fakeBusObject) Call(method string, _ dbus.Flags, args ...any) *dbus.Call {
+	body, err := o.reply(method, args...)
+	return &dbus.Call{Method: method, Args: args, Body: body, Err: err}
+}
+
+// fakeBus stands in for the session bus. hasOwner answers NameHasOwner for
+// KWin and introspection is the XML returned for the scripting object.
+type fakeBus struct {
+	hasOwner      func() (bool, error)
+	introspection string
+}
+
+func (b fakeBus) BusObject() dbus.BusObject {
+	return fakeBusObject{reply: func(method string, args ...any) ([]any, error) {
+		if method != "org.freedesktop.DBus.NameHasOwner" || len(args) != 1 || args[0] != kwinService {
+			return nil, errors.New("unexpected call " + method)
+		}
+		owned, err := b.hasOwner()
+		return []any{owned}, err
+	}}
+}
+
+func (b fakeBus) Object(dest string, path dbus.ObjectPath) dbus.BusObject {
+	return fakeBusObject{reply: func(method string, args ...any) ([]any, error) {
+		if dest != kwinService || path != kwinScriptingPath || method != "org.freedesktop.DBus.Introspectable.Introspect" {
+			return nil, errors.New("unexpected call " + method)
+		}
+		return []any{b.introspection}, nil
+	}}
+}
+
+func kwinRunning() (bool, error) { return true, nil }
+
+const scriptingXML = `<node><interface name="` + kwinScriptingIface + `"/></node>`
+
+func TestWaitForKWin(t *testing.T) {
+	calls := 0
+	bus := fakeBus{hasOwner: func() (bool, error) {
+		calls++
+		return calls >= 3, nil
+	}}
+	if err := waitForKWin(bus, time.Minute); err != nil {
+		t.Fatalf("waitForKWin: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("polled %d times, want 3", calls)
+	}
+
+	bus = fakeBus{hasOwner: func() (bool, error) { return false, nil }}
+	if err := waitForKWin(bus, 0); err == nil || !strings.Contains(err.Error(), "did not appear") {
+		t.Errorf("KWin never appearing: got error %v", err)
+	}
+
+	bus = fakeBus{hasOwner: func() (bool, error) { return false, errors.New("bus gone") }}
+	if err := waitForKWin(bus, 0); err == nil || !strings.Contains(err.Error(), "bus gone") {
+		t.Errorf("failing bus: got error %v", err)
+	}
+}
+
+func TestRunDoctor(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	config := `{"profiles": {"term": {"filter": "konsole"}}, "groups": {"terminals": ["konsole", "yakuake"]}}`
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	kdeSession := map[string]string{"XDG_SESSION_TYPE": "wayland", "XDG_CURRENT_DESKTOP": "KDE"}
+
+	tests := []struct {
+		name    string
+		env     doctorEnv
+		wantErr string
+		want    []string
+	}{
+		{
+			name: "healthy",
+			env: doctorEnv{
+				conn:   fakeBus{hasOwner: kwinRunning, introspection: scriptingXML},
+				getenv: mapEnv(kdeSession),
+			},
+			want: []string{
+				"[PASS] session type: wayland, KDE\n",
+				"[PASS] session bus\n",
+				"[PASS] KWin on session bus\n",
+				"[PASS] KWin scripting interface\n",
+				"[PASS] config file: 1 profiles, 1 groups\n",
+			},
+		},
+		{
+			name: "no KWin",
+			env: doctorEnv{
+				conn:   fakeBus{hasOwner: func() (bool, error) { return false, nil }, introspection: scriptingXML},
+				getenv: mapEnv(kdeSession),
+			},
+			wantErr: "1 of 5 checks failed",
+			want:    []string{"[FAIL] KWin on session bus: " + kwinService + " is not registered"},
+		},
+		{
+			name: "no scripting interface",
+			env: doctorEnv{
+				conn:   fakeBus{hasOwner: kwinRunning, introspection: "<node/>"},
+				getenv: mapEnv(kdeSession),
+			},
+			wantErr: "1 of 5 checks failed",
+			want:    []string{"[FAIL] KWin scripting interface: "},
+		},
+		{
+			name: "no session bus",
+			env: doctorEnv{
+				connErr: errors.New("connect to session bus: no address"),
+				getenv:  mapEnv(map[string]string{}),
+			},
+			wantErr: "4 of 5 checks failed",
+			want: []string{
+				"[FAIL] session type: XDG_SESSION_TYPE is not set",
+				"[FAIL] session bus: connect to session bus: no address\n",
+				"[FAIL] KWin on session bus: " + errNoSessionBus.Error() + "\n",
+				"[FAIL] KWin scripting interface: " + errNoSessionBus.Error() + "\n",
+				"[PASS] config file",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.env.configPath = configPath
+			var out bytes.Buffer
+			err := runDoctor(&out, tt.env, doctorChecks)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("runDoctor: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("runDoctor: got error %v, want %q", err, tt.wantErr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output lacks %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func mapEnv(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+//go:embed kwin_dump_template.js
+var kwinDumpTemplate string
+
+// windowInfo is one entry of the window list reported by the dump script.
+type windowInfo struct {
+	ResourceClass string `json:"resourceClass"`
+	ResourceName  string `json:"resourceName"`
+	Caption       string `json:"caption"`
+	InternalID    string `json:"internalId"`
+	PID           int    `json:"pid"`
+	// Desktop names the desktops the window is on; only --pick sends it.
+	Desktop string `json:"desktop"`
+}
+
+type dumpParams struct {
+	DBusAddress       string
+	ListenerPath      string
+	ListenerInterface string
+}
+
+// dumpWindows lists every window KWin knows about, ignoring all filters, so
+// users can discover the class and caption values to filter on.
+func dumpWindows(conn *dbus.Conn, tmpDir string, listenerPath dbus.ObjectPath, listenerIface string) error {
+	windows, err := queryWindows(conn, tmpDir, listenerPath, listenerIface)
+	if err != nil {
+		return err
+	}
+	return printWindowTable(os.Stdout, windows)
+}
+
+// queryWindows runs the dump script and returns every window KWin knows about.
+func queryWindows(conn *dbus.Conn, tmpDir string, listenerPath dbus.ObjectPath, listenerIface string) ([]windowInfo, error) {
+	dbusAddress, err := getUniqueName(conn)
+	if err != nil {
+		return nil, fmt.Errorf("get unique bus name: %w", err)
+	}
+
+	script, err := renderDumpScript(dumpParams{
+		DBusAddress:       dbusAddress,
+		ListenerPath:      string(listenerPath),
+		ListenerInterface: listenerIface,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("render KWin dump script: %w", err)
+	}
+
+	listener := &launchListener{windows: make(chan string, 1)}
+	if err := conn.Export(listener, listenerPath, listenerIface); err != nil {
+		return nil, fmt.Errorf("export listener on D-Bus: %w", err)
+	}
+	defer func() {
+		_ = conn.Export(nil, listenerPath, listenerIface)
+	}()
+
+	scriptFile, err := writeTempScript(tmpDir, script)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(scriptFile)
+
+	scriptPath, err := loadKWinScript(conn, scriptFile, "")
+	if err != nil {
+		return nil, err
+	}
+	scriptObj := conn.Object(kwinService, scriptPath)
+	defer func() {
+		_ = stopScript(scriptObj)
+	}()
+
+	if err := scriptObj.Call(kwinScriptIface+".run", 0).Err; err != nil {
+		return nil, fmt.Errorf("run KWin script: %w", err)
+	}
+
+	windows, err := waitForWindows(listener.windows, responseTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("wait for KWin response: %w", err)
+	}
+	return windows, nil
+}
+
+func waitForWindows(ch <-chan string, timeout time.Duration) ([]windowInfo, error) {
+	select {
+	case payload := <-ch:
+		var windows []windowInfo
+		if err := json.Unmarshal([]byte(payload), &windows); err != nil {
+			return nil, fmt.Errorf("parse window list: %w", err)
+		}
+		return windows, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("timeout waiting for window list from KWin script")
+	}
+}
+
+func printWindowTable(w io.Writer, windows []windowInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLASS\tNAME\tPID\tID\tCAPTION")
+	for _, win := range windows {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", win.ResourceClass, win.ResourceName, win.PID, win.InternalID, win.Caption)
+	}
+	return tw.Flush()
+}
+
+func renderDumpScript(params dumpParams) (string, error) {
+	return renderListenerScript("kwin-dump-script", kwinDumpTemplate, params)
+}
+
+// renderListenerScript renders a script that only needs to know where to
+// report back, such as the dump script or the --track-windows script.
+func renderListenerScript(name, text string, params dumpParams) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	data := params
+	data.DBusAddress = escapeForJS(params.DBusAddress)
+	data.ListenerPath = escapeForJS(params.ListenerPath)
+	data.ListenerInterface = escapeForJS(params.ListenerInterface)
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDumpScript(t *testing.T) {
+	script, err := renderDumpScript(dumpParams{
+		DBusAddress:       ":1.42",
+		ListenerPath:      "/org/jumpkwapp/Listener",
+		ListenerInterface: "org.jumpkwapp.Listener",
+	})
+	if err != nil {
+		t.Fatalf("renderDumpScript: %v", err)
+	}
+	result := runScript(t, script, kwinFixture{Windows: []fakeWindow{
+		{Caption: "shell", ResourceClass: "org.kde.konsole", InternalID: "{1}", PID: 100},
+		{Caption: "Inbox", ResourceClass: "thunderbird", InternalID: "{2}"},
+	}})
+	if len(result.DBus) != 1 || result.DBus[0].Method != "ReportWindows" {
+		t.Fatalf("D-Bus calls = %+v, want one ReportWindows", result.DBus)
+	}
+	var got []windowInfo
+	if err := json.Unmarshal([]byte(result.DBus[0].Args[0]), &got); err != nil {
+		t.Fatalf("parse window list: %v", err)
+	}
+	want := []windowInfo{
+		{ResourceClass: "org.kde.konsole", ResourceName: "org.kde.konsole", Caption: "shell", InternalID: "{1}", PID: 100},
+		{ResourceClass: "thunderbird", ResourceName: "thunderbird", Caption: "Inbox", InternalID: "{2}"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("windows = %+v, want %+v", got, want)
+	}
+}
+
+func TestPrintWindowTable(t *testing.T) {
+	var buf bytes.Buffer
+	err := printWindowTable(&buf, []windowInfo{
+		{ResourceClass: "org.kde.konsole", ResourceName: "konsole", Caption: "shell", InternalID: "{1}", PID: 4242},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "CLASS            NAME     PID   ID   CAPTION\n" +
+		"org.kde.konsole  konsole  4242  {1}  shell\n"
+	if buf.String() != want {
+		t.Errorf("table =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
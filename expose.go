@@ -0,0 +1,15 @@
+package main
+
+import "github.com/godbus/dbus/v5"
+
+const (
+	windowViewService = "org.kde.KWin.Effect.WindowView1"
+	windowViewPath    = "/org/kde/KWin/Effect/WindowView1"
+	windowViewIface   = "org.kde.KWin.Effect.WindowView1"
+)
+
+// exposeWindows opens KWin's window overview showing only the windows with
+// the given internal IDs, so one can be picked with the mouse or keyboard.
+func exposeWindows(conn *dbus.Conn, ids []string) error {
+	return conn.Object(windowViewService, windowViewPath).Call(windowViewIface+".activate", 0, ids).Err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// flash is a window being flashed by --flash: the opacity it had before the
+// flash, which it is restored to, and when the flash is over, in Unix
+// milliseconds.
+type flash struct {
+	Opacity float64 `json:"opacity"`
+	Until   int64   `json:"until"`
+}
+
+// flashStatePath returns the file recording the windows being flashed, keyed
+// by KWin internal window ID. Every press runs its own script, so without it
+// a press during another's flash would take the dimmed opacity for the
+// window's own and leave the window dimmed.
+func flashStatePath() string {
+	return statePath("flash")
+}
+
+// runningFlashesJSON returns the original opacities of the windows whose
+// flash is not over at now, as a JSON object literal for the KWin script.
+func runningFlashesJSON(path string, now time.Time) (string, error) {
+	flashes, err := loadState[flash](path)
+	if err != nil {
+		return "", err
+	}
+	opacities := map[string]float64{}
+	for id, f := range flashes {
+		if f.Until > now.UnixMilli() {
+			opacities[id] = f.Opacity
+		}
+	}
+	data, err := json.Marshal(opacities)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// recordFlash notes that the window with the given ID is flashed until until
+// and then restored to opacity. Flashes that are over at now are dropped.
+func recordFlash(path, id string, opacity float64, now, until time.Time) error {
+	return updateState(path, func(flashes map[string]flash) {
+		for other, f := range flashes {
+			if f.Until <= now.UnixMilli() {
+				delete(flashes, other)
+			}
+		}
+		flashes[id] = flash{Opacity: opacity, Until: until.UnixMilli()}
+	})
+}
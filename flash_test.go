@@ -0,0 +1,59 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunningFlashes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flash.json")
+	start := time.UnixMilli(10000)
+
+	if got, err := runningFlashesJSON(path, start); err != nil || got != "{}" {
+		t.Fatalf("no flashes recorded: got %s, %v, want {}", got, err)
+	}
+	if err := recordFlash(path, "{1}", 0.8, start, start.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordFlash(path, "{2}", 1, start, start.Add(2*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		at   time.Duration
+		want string
+	}{
+		{at: 0, want: `{"{1}":0.8,"{2}":1}`},
+		{at: time.Second, want: `{"{2}":1}`},
+		{at: 2 * time.Second, want: `{}`},
+	}
+	for _, tt := range tests {
+		got, err := runningFlashesJSON(path, start.Add(tt.at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("at %s: running flashes = %s, want %s", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestRecordFlashDropsFinished(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flash.json")
+	start := time.UnixMilli(10000)
+	if err := recordFlash(path, "{1}", 0.8, start, start.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	later := start.Add(5 * time.Second)
+	if err := recordFlash(path, "{2}", 1, later, later.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	flashes, err := loadState[flash](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := flashes["{1}"]; ok || len(flashes) != 1 {
+		t.Errorf("flashes = %v, want only {2}", flashes)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// --scratchpad puts the window across the top of the screen unless
+// --geometry, --place, --maximize, or --tile says otherwise.
+const (
+	defaultScratchpadSize  = "100%x40%"
+	defaultScratchpadPlace = "top-center"
+)
+
+// position is one coordinate of a --place or --geometry spec, in pixels or
+// as a percentage.
+type position struct {
+	Value   float64
+	Percent bool
+}
+
+// placement is where --place puts the window. Pixels are measured from the
+// left or top of the work area. A percentage is of the space the window
+// leaves free, so 0% is flush with the left or top and 100% flush with the
+// right or bottom.
+type placement struct {
+	X, Y position
+}
+
+// size is the window size set by --geometry. A percentage is of the work
+// area of the window's screen.
+type size struct {
+	Width, Height position
+}
+
+// namedPlacements are the --place keywords and their equivalent X,Y spec.
+var namedPlacements = map[string]string{
+	"center":        "50%,50%",
+	"top-left":      "0%,0%",
+	"top-center":    "50%,0%",
+	"top-right":     "100%,0%",
+	"center-left":   "0%,50%",
+	"center-right":  "100%,50%",
+	"bottom-left":   "0%,100%",
+	"bottom-center": "50%,100%",
+	"bottom-right":  "100%,100%",
+}
+
+// parsePlacement parses a --place spec: a keyword like center or
+// bottom-right, or X,Y where each is pixels or a percentage. An empty spec
+// returns nil.
+func parsePlacement(spec string) (*placement, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if spec == "" {
+		return nil, nil
+	}
+	if named, ok := namedPlacements[spec]; ok {
+		spec = named
+	}
+	xs, ys, ok := strings.Cut(spec, ",")
+	if !ok {
+		return nil, fmt.Errorf("want a position like center or bottom-right, or X,Y, got %q", spec)
+	}
+	x, err := parsePosition(xs)
+	if err != nil {
+		return nil, err
+	}
+	y, err := parsePosition(ys)
+	if err != nil {
+		return nil, err
+	}
+	return &placement{X: x, Y: y}, nil
+}
+
+// parsePosition parses one coordinate: a non-negative pixel count, or a
+// percentage from 0% to 100%.
+func parsePosition(s string) (position, error) {
+	s = strings.TrimSpace(s)
+	digits, percent := strings.CutSuffix(s, "%")
+	value, err := strconv.ParseFloat(digits, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return position{}, fmt.Errorf("invalid coordinate %q", s)
+	}
+	if value < 0 || (percent && value > 100) {
+		return position{}, fmt.Errorf("coordinate %q is out of range", s)
+	}
+	return position{Value: value, Percent: percent}, nil
+}
+
+// parseSize parses a --geometry spec of the form WxH, where each is pixels
+// or a percentage, e.g. 1200x800 or 80%x50%. An empty spec returns nil.
+func parseSize(spec string) (*size, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if spec == "" {
+		return nil, nil
+	}
+	ws, hs, ok := strings.Cut(spec, "x")
+	if !ok {
+		return nil, fmt.Errorf("want WIDTHxHEIGHT like 1200x800 or 80%%x50%%, got %q", spec)
+	}
+	width, err := parsePosition(ws)
+	if err != nil {
+		return nil, err
+	}
+	height, err := parsePosition(hs)
+	if err != nil {
+		return nil, err
+	}
+	if width.Value == 0 || height.Value == 0 {
+		return nil, fmt.Errorf("size %q must not be zero", spec)
+	}
+	return &size{Width: width, Height: height}, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePosition(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    position
+		wantErr bool
+	}{
+		{in: "0", want: position{Value: 0}},
+		{in: " 120 ", want: position{Value: 120}},
+		{in: "12.5", want: position{Value: 12.5}},
+		{in: "50%", want: position{Value: 50, Percent: true}},
+		{in: "100%", want: position{Value: 100, Percent: true}},
+		{in: "-1", wantErr: true},
+		{in: "101%", wantErr: true},
+		{in: "-5%", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "%", wantErr: true},
+		{in: "left", wantErr: true},
+		{in: "NaN", wantErr: true},
+		{in: "nan%", wantErr: true},
+		{in: "inf", wantErr: true},
+		{in: "+Infinity", wantErr: true},
+		{in: "1e400", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parsePosition(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parsePosition(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("parsePosition(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParsePlacement(t *testing.T) {
+	pct := func(v float64) position { return position{Value: v, Percent: true} }
+	px := func(v float64) position { return position{Value: v} }
+	tests := []struct {
+		in      string
+		want    *placement
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "center", want: &placement{X: pct(50), Y: pct(50)}},
+		{in: " Bottom-Right ", want: &placement{X: pct(100), Y: pct(100)}},
+		{in: "top-left", want: &placement{X: pct(0), Y: pct(0)}},
+		{in: "100,200", want: &placement{X: px(100), Y: px(200)}},
+		{in: "25%, 40", want: &placement{X: pct(25), Y: px(40)}},
+		{in: "middle", wantErr: true},
+		{in: "100", wantErr: true},
+		{in: "100,200,300", wantErr: true},
+		{in: "100,-5", wantErr: true},
+		{in: "nan,0", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parsePlacement(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parsePlacement(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePlacement(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    *size
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "1200x800", want: &size{Width: position{Value: 1200}, Height: position{Value: 800}}},
+		{in: " 80%X50% ", want: &size{Width: position{Value: 80, Percent: true}, Height: position{Value: 50, Percent: true}}},
+		{in: "50%x600", want: &size{Width: position{Value: 50, Percent: true}, Height: position{Value: 600}}},
+		{in: "1200", wantErr: true},
+		{in: "0x800", wantErr: true},
+		{in: "infx800", wantErr: true},
+		{in: "1200xNaN", wantErr: true},
+		{in: "1200x0%", wantErr: true},
+		{in: "120%x50%", wantErr: true},
+		{in: "-1x800", wantErr: true},
+		{in: "widexhigh", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSize(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSize(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -15,11 +15,16 @@ package main
 import (
 	"bytes"
 	_ "embed"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -36,80 +41,889 @@ const (
 	kwinScriptingIface = "org.kde.kwin.Scripting"
 	kwinScriptIface    = "org.kde.kwin.Script"
 	responseTimeout    = 5 * time.Second
-)
+	defaultDBusName    = "org.jumpkwapp"
+
+	activationRetryDelay = 50 * time.Millisecond
+	// --flash dims and restores the window twice, each step this long.
+	flashInterval      = 150 * time.Millisecond
+	flashSteps         = 4
+	maxActivateRetries = 20
+	// How long --focus-after-launch waits for the new window by default.
+	defaultFocusAfterLaunch = 10 * time.Second
+	kwinPollInterval        = 200 * time.Millisecond
 
-var (
-	listenerObjectPath = dbus.ObjectPath("/org/jumpkwapp/Listener")
-	listenerInterface  = "org.jumpkwapp.Listener"
+	// With --exit-count, match counts are clamped to maxCountExitStatus so
+	// they never collide with exitCountErrorStatus, used for errors instead
+	// of the usual status 1 (which would read as "one match").
+	maxCountExitStatus   = 254
+	exitCountErrorStatus = 255
 )
 
 type config struct {
-	filterClass    string
-	filterAlt      string
-	filterRegex    string
-	currentDesktop bool
-	toggle         bool
-	command        string
+	filterClasses       []string
+	smartClass          bool
+	ignoreCase          bool
+	filterAlt           string
+	captionFlags        string
+	captionFullMatch    bool
+	filterTitle         string
+	captionExact        string
+	filterRegex         string
+	captionFirst        bool
+	allFilters          bool
+	fuzzy               string
+	currentDesktop      bool
+	desktop             string
+	activity            string
+	currentActivity     bool
+	screen              string
+	currentScreen       bool
+	includeSkipTaskbar  bool
+	onlyTaskbar         bool // deprecated no-op, kept only to warn about it
+	other               bool
+	includeSpecial      bool
+	visibleOnly         bool
+	minimizedOnly       bool
+	skipMinimized       bool
+	state               string
+	toggle              bool
+	toggleBack          bool
+	shade               bool
+	summon              bool
+	summonToScreen      bool
+	noDesktopSwitch     bool
+	sendToScreen        string
+	toCurrentScreen     bool
+	sendToActivity      string
+	solo                bool
+	warpPointer         bool
+	flash               bool
+	expose              bool
+	pick                bool
+	menu                string
+	maximize            bool
+	fullscreen          bool
+	pin                 bool
+	keepAbove           bool
+	opacity             float64
+	size                *size
+	place               *placement
+	tile                string
+	action              string
+	sendToDesktop       string
+	follow              bool
+	includeDialogs      bool
+	raiseAll            bool
+	reverse             bool
+	cycleOrder          string
+	persistentCycle     bool
+	index               int
+	cycleSkipMinimized  bool
+	cycleCurrentScreen  bool
+	cycleSamePID        bool
+	preferVisible       bool
+	rememberGeometry    bool
+	prefer              string
+	preferOpened        bool
+	preferCurrentScreen bool
+	commands            []string
+	windowID            string
+	pid                 int
+	cmdline             string
+	openedWithin        time.Duration
+	desktopFile         string
+	resourceName        string
+	role                string
+	match               string
+	excludeClasses      []string
+	excludeCaptions     []string
+	excludeClassRegexes []string
+	dbusName            string
+	tmpDir              string
+	quiet               bool
+	profile             string
+	configPath          string
+	dumpWindows         bool
+	trackWindows        bool
+	activateRetries     int
+	doctor              bool
+	statsFile           string
+	exitCount           bool
+	waitForKWin         time.Duration
+	detachIO            bool
+	templateDebug       bool
+	launchDebounce      time.Duration
+	focusAfterLaunch    time.Duration
+	launchDesktop       string
+	argv                []string
+}
+
+// canLaunch reports whether there is something to launch when no window
+// matches.
+func (c config) canLaunch() bool {
+	return len(c.commands) > 0 || c.launchDesktop != "" || len(c.argv) > 0
+}
+
+func (c config) hasFilter() bool {
+	return len(c.filterClasses) > 0 || c.captionFilters() > 0 || c.filterRegex != "" || c.fuzzy != "" || c.windowID != "" ||
+		c.pid != 0 || c.cmdline != "" || c.desktopFile != "" || c.resourceName != "" || c.role != "" || c.match != ""
+}
+
+// captionFilters counts the caption filters that are set. They are different
+// ways of matching the same caption, so at most one may be given.
+func (c config) captionFilters() int {
+	n := 0
+	for _, s := range []string{c.filterAlt, c.filterTitle, c.captionExact} {
+		if s != "" {
+			n++
+		}
+	}
+	return n
 }
 
 type scriptParams struct {
-	ClassName          string
-	CaptionPattern     string
-	ClassRegex         string
-	Toggle             bool
-	CurrentDesktopOnly bool
-	DBusAddress        string
+	ClassNames          []string
+	SmartClass          bool
+	IgnoreCase          bool
+	CaptionPattern      string
+	CaptionFlags        string
+	CaptionFullMatch    bool
+	TitleSubstring      string
+	CaptionExact        string
+	ClassRegex          string
+	CaptionFirst        bool
+	AllFilters          bool
+	Fuzzy               string
+	Toggle              bool
+	Shade               bool
+	CurrentDesktopOnly  bool
+	Desktop             string
+	Activity            string
+	CurrentActivity     bool
+	Screen              string
+	CurrentScreen       bool
+	IncludeSkipTaskbar  bool
+	Other               bool
+	IncludeSpecial      bool
+	VisibleOnly         bool
+	MinimizedOnly       bool
+	SkipMinimized       bool
+	State               string
+	Summon              bool
+	SummonToScreen      bool
+	NoDesktopSwitch     bool
+	SendToScreen        string
+	ToCurrentScreen     bool
+	SendToActivity      string
+	Solo                bool
+	WarpPointer         bool
+	Flash               bool
+	Expose              bool
+	Pick                bool
+	FlashSteps          int
+	FlashInterval       int64
+	FlashOpacities      string // JSON object literal, rendered as is
+	Maximize            bool
+	Fullscreen          bool
+	Pin                 bool
+	KeepAbove           bool
+	Opacity             float64
+	Size                *size
+	Place               *placement
+	Tile                string
+	Action              string
+	SendToDesktop       string
+	Follow              bool
+	IncludeDialogs      bool
+	RaiseAll            bool
+	Reverse             bool
+	CycleOrder          string
+	PersistentCycle     bool
+	Index               int
+	LastCycled          string
+	ToggleBack          bool
+	FocusAfterLaunch    bool
+	AwaitLaunched       bool
+	LaunchTimeout       int64 // milliseconds
+	PreviousWindow      string
+	ActivationTimes     string // JSON object literal, rendered as is
+	CycleSkipMinimized  bool
+	CycleCurrentScreen  bool
+	CycleSamePID        bool
+	PreferVisible       bool
+	RememberGeometry    bool
+	SavedGeometry       string // JSON object literal, rendered as is
+	Prefer              string
+	PreferOpened        bool
+	PreferCurrentScreen bool
+	ActivateRetries     int
+	ActivateRetryDelay  int64
+	DBusAddress         string
+	WindowID            string
+	PID                 int
+	RestrictPIDs        bool
+	PIDs                []int
+	OpenedAfter         int64  // milliseconds since the epoch, 0 to disable
+	OpenTimes           string // JSON object literal, rendered as is
+	DesktopFile         string
+	ResourceName        string
+	Role                string
+	// MatchExpr is JavaScript compiled by compileMatch, which escapes every
+	// literal itself; it is rendered as code, not as a string.
+	MatchExpr           string
+	ExcludeClasses      []string
+	ExcludeCaptions     []string
+	ExcludeClassRegexes []string
+	ListenerPath        string
+	ListenerInterface   string
+	// PluginName is the name the script is loaded under, which it needs to
+	// unload itself.
+	PluginName string
+}
+
+// launchDecision is the outcome the KWin script reports through ShouldLaunch.
+type launchDecision int
+
+const (
+	decisionMatched launchDecision = iota // a window matched, nothing to launch
+	decisionLaunch                        // no window matched, launch the command
+	decisionError                         // the script failed before deciding
+)
+
+// parseDecision maps the string sent by the KWin script to a launchDecision.
+// Anything other than "true" or "false" is treated as a script error.
+func parseDecision(value string) launchDecision {
+	switch strings.ToLower(value) {
+	case "true":
+		return decisionLaunch
+	case "false":
+		return decisionMatched
+	default:
+		return decisionError
+	}
+}
+
+// scriptOutcome is what the KWin script reports through ReportOutcome once it
+// has acted on the matching windows.
+type scriptOutcome struct {
+	Action  string `json:"action"`
+	Matched int    `json:"matched"`
+	// Pointer is the center of the activated window, sent with --warp-pointer.
+	Pointer *point `json:"pointer"`
+	// Windows are the internal IDs of the windows to expose with --expose.
+	Windows []string `json:"windows"`
+	// Choices are the windows to pick from with --pick or --menu.
+	Choices []windowInfo `json:"choices"`
+	// Previous is the internal ID of the window that was active before the
+	// match was activated, sent with --toggle-back.
+	Previous string `json:"previous"`
+	// Activated is the internal ID of the window activated, sent with --mru
+	// and --persistent-cycle.
+	Activated string `json:"activated"`
+	// Demoted is the internal ID of the window left behind when cycling
+	// backwards with --mru, to be recorded as least recently used.
+	Demoted string `json:"demoted"`
+	// Geometry has the changes to the saved geometries of --remember-geometry.
+	Geometry map[string]*savedGeometry `json:"geometry"`
+	// Flashed maps the internal ID of the window flashed with --flash to the
+	// opacity it is restored to.
+	Flashed map[string]float64 `json:"flashed"`
 }
 
 type launchListener struct {
-	ch chan bool
+	ch       chan launchDecision
+	windows  chan string
+	outcomes chan string
 }
 
 func (l *launchListener) ShouldLaunch(decision string) *dbus.Error {
 	select {
-	case l.ch <- strings.EqualFold(decision, "true"):
+	case l.ch <- parseDecision(decision):
 	default:
 	}
 	return nil
 }
 
+// ReportWindows receives the JSON-encoded window list sent by the dump script.
+func (l *launchListener) ReportWindows(payload string) *dbus.Error {
+	select {
+	case l.windows <- payload:
+	default:
+	}
+	return nil
+}
+
+// ReportOutcome receives the JSON-encoded scriptOutcome sent after the script acted.
+func (l *launchListener) ReportOutcome(payload string) *dbus.Error {
+	select {
+	case l.outcomes <- payload:
+	default:
+	}
+	return nil
+}
+
+// drain drops a decision and outcome left over from an earlier script.
+func (l *launchListener) drain() {
+	select {
+	case <-l.ch:
+	default:
+	}
+	select {
+	case <-l.outcomes:
+	default:
+	}
+}
+
+// exitStatus is returned by run to make main exit with a specific status
+// without reporting an error, as --exit-count does.
+type exitStatus int
+
+func (s exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(s))
+}
+
+// countExitStatus maps a number of matching windows to the --exit-count
+// process status: the count itself, clamped to maxCountExitStatus.
+func countExitStatus(matched int) int {
+	if matched < 0 {
+		return 0
+	}
+	if matched > maxCountExitStatus {
+		return maxCountExitStatus
+	}
+	return matched
+}
+
 func main() {
-	cfg := parseFlags()
-	if err := run(cfg); err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+	cfg, err := parseFlags()
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err == nil {
+		err = run(cfg)
+	}
+	var status exitStatus
+	if errors.As(err, &status) {
+		os.Exit(int(status))
+	}
+	if err != nil {
+		if !errors.Is(err, errFlagSyntax) {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		}
+		if cfg.exitCount {
+			os.Exit(exitCountErrorStatus)
+		}
+		if errors.Is(err, errFlagSyntax) {
+			os.Exit(2)
+		}
 		os.Exit(1)
 	}
 }
 
-func parseFlags() config {
-	filterClass := flag.String("filter", "", "filter by window class (exact match)")
-	filterClassShort := flag.String("f", "", "filter by window class (exact match)")
+// errFlagSyntax is returned by parseFlags when the flag package rejected the
+// command line, after it has printed the problem and the usage.
+var errFlagSyntax = errors.New("invalid command line")
+
+// parseFlags builds the config from the command line. On an error it still
+// returns as much of the config as was parsed, so main knows whether
+// --exit-count was given and can exit with its error status.
+func parseFlags() (config, error) {
+	var filterClasses stringList
+	flag.Var(&filterClasses, "filter", "filter by window class (exact match, repeatable; earlier classes are preferred)")
+	flag.Var(&filterClasses, "f", "filter by window class (exact match, repeatable; earlier classes are preferred)")
+	smartClass := flag.Bool("smart-class", false, "match -f classes case-insensitively against the window class or desktop file name, with known X11/Wayland aliases")
+	ignoreCase := flag.Bool("ignore-case", false, "match the window class (exact and regex) and name case-insensitively")
+	ignoreCaseShort := flag.Bool("i", false, "match the window class (exact and regex) and name case-insensitively")
 	filterAlt := flag.String("filter-alternative", "", "filter by window caption (regex, case-insensitive)")
 	filterAltShort := flag.String("fa", "", "filter by window caption (regex, case-insensitive)")
+	captionFlags := flag.String("caption-flags", "i", "JavaScript RegExp flags for the -fa caption regex (any of i, m, s, u; '' for case-sensitive)")
+	captionFullMatch := flag.Bool("caption-full-match", false, "require the -fa caption regex to match the whole caption")
+	filterTitle := flag.String("title", "", "filter by window caption (plain substring, case-insensitive)")
+	captionExact := flag.String("caption-exact", "", "filter by window caption (literal full title, case-sensitive)")
 	filterRegex := flag.String("filter-regex", "", "filter by window class using regex")
 	filterRegexShort := flag.String("fr", "", "filter by window class using regex")
+	filterGlob := flag.String("glob", "", "filter by window class using a shell-style wildcard pattern (e.g. 'jetbrains-*')")
+	allFilters := flag.Bool("all-filters", false, "require windows to match every class and caption filter instead of any of them")
+	fuzzy := flag.String("fuzzy", "", "match window class or caption as a fuzzy subsequence and pick the best match")
+	captionFirst := flag.Bool("caption-first", false, "prefer caption matches; use class filters only if no caption matches")
 	currentDesktop := flag.Bool("current-desktop", false, "only consider windows on the current virtual desktop")
 	currentDesktopShort := flag.Bool("d", false, "only consider windows on the current virtual desktop")
+	desktop := flag.String("desktop", "", "only consider windows on this virtual desktop (1-based number or name)")
+	activity := flag.String("activity", "", "only consider windows on the KDE activity with this id")
+	currentActivity := flag.Bool("current-activity", false, "only consider windows on the current KDE activity")
+	screen := flag.String("screen", "", "only consider windows on this screen (connector name like DP-1, or 0-based index)")
+	currentScreen := flag.Bool("current-screen", false, "only consider windows on the focused screen")
+	// Skipping windows hidden from the task bar is the default now; the flag
+	// is still accepted so existing bindings keep working.
+	onlyTaskbar := flag.Bool("only-taskbar", false, "deprecated: windows hidden from the task bar are skipped by default")
+	includeSkipTaskbar := flag.Bool("include-skip-taskbar", false, "also match windows that are hidden from the task bar")
+	other := flag.Bool("other", false, "skip the focused window even if it matches, so another match is chosen")
+	includeSpecial := flag.Bool("include-special", false, "also match desktop, dock, panel, and other special windows")
+	visibleOnly := flag.Bool("visible-only", false, "only consider windows that can be seen: not minimized, on this desktop and activity, on screen, and not covered")
+	minimizedOnly := flag.Bool("minimized-only", false, "only consider minimized windows")
+	skipMinimized := flag.Bool("skip-minimized", false, "never consider minimized windows")
+	state := flag.String("state", "", "only consider windows in this state: fullscreen, maximized, or normal (prefix with ! to skip them instead)")
 	toggle := flag.Bool("toggle", false, "toggle minimize when the window is already active")
 	toggleShort := flag.Bool("t", false, "toggle minimize when the window is already active")
+	toggleBack := flag.Bool("toggle-back", false, "when the window is already active, go back to the window that was active before jumping to it")
+	shade := flag.Bool("shade", false, "shade or unshade the window when it is already active, instead of minimizing it")
+	scratchpad := flag.Bool("scratchpad", false, "dropdown mode: bring the window to the current desktop at the top of the screen, or hide it if it is active")
+	rememberGeometry := flag.Bool("remember-geometry", false, "save where a window was when --toggle or --scratchpad hides it, and put it back there when it is shown")
+	summon := flag.Bool("summon", false, "move the window to the current desktop instead of switching to its desktop")
+	summonToScreen := flag.Bool("summon-to-screen", false, "with --summon, also move the window to the focused screen")
+	sendToScreen := flag.String("send-to-screen", "", "move the window to this screen (connector name or 0-based index) before activating it")
+	toCurrentScreen := flag.Bool("to-current-screen", false, "move the window to the focused screen before activating it")
+	sendToActivity := flag.String("send-to-activity", "", "move the window to this KDE activity (id or name) before activating it")
+	noDesktopSwitch := flag.Bool("no-desktop-switch", false, "never switch desktops; flag a match on another desktop as demanding attention instead")
+	solo := flag.Bool("solo", false, "minimize every other window on the current desktop after activating the match")
+	expose := flag.Bool("expose", false, "when several windows match, show just them in KWin's window overview to pick from")
+	pick := flag.Bool("pick", false, "when several windows match, list them on the terminal to pick from (uses fzf if installed)")
+	menu := flag.String("menu", "", "when several windows match (or with no filter, any window), pick one with rofi, dmenu, fuzzel, wofi or the given shell command")
+	flash := flag.Bool("flash", false, "briefly pulse the window's opacity after activating it, to show where focus went")
+	warp := flag.Bool("warp-pointer", false, "move the mouse pointer to the center of the window after activating it (needs xdotool on X11, ydotool on Wayland)")
+	maximize := flag.Bool("maximize", false, "maximize the window when activating it")
+	fullscreen := flag.Bool("fullscreen", false, "toggle fullscreen on the window when activating it")
+	pin := flag.Bool("pin", false, "toggle whether the window is on all desktops when activating it")
+	keepAbove := flag.Bool("keep-above", false, "toggle keep-above on the window when activating it")
+	opacity := flag.Float64("opacity", 0, "set the window's opacity (above 0, up to 1) when activating it")
+	geometry := flag.String("geometry", "", "resize the window when activating it: WIDTHxHEIGHT in pixels or percent of the screen (e.g. 80%x50%)")
+	place := flag.String("place", "", "move the window when activating it: center, top-left, top-center, ..., bottom-right, or X,Y in pixels or percent")
+	tile := flag.String("tile", "", "quick-tile the window when activating it: left, right, top, bottom, topleft, topright, bottomleft, or bottomright")
+	raiseOnly := flag.Bool("raise-only", false, "raise the window without giving it keyboard focus")
+	urgent := flag.Bool("urgent", false, "mark the window as demanding attention instead of activating it")
+	closeWindow := flag.Bool("close", false, "close the matched window instead of activating it")
+	closeAll := flag.Bool("close-all", false, "close every matching window instead of activating one")
+	sendToDesktop := flag.String("send-to-desktop", "", "move the window to this virtual desktop (1-based number or name) instead of activating it")
+	follow := flag.Bool("follow", false, "with --send-to-desktop, switch to that desktop and activate the window")
+	hide := flag.Bool("hide", false, "minimize the matching windows if they are shown; never activates or launches anything")
+	var ownDesktop optionalString
+	flag.Var(&ownDesktop, "own-desktop", "move the matching windows to their own desktop, created if needed, and switch to it (--own-desktop=NAME; default name is the first -f class)")
+	swap := flag.Bool("swap", false, "swap the position and size of the window with the active window's, then focus it")
+	includeDialogs := flag.Bool("include-dialogs", false, "focus the topmost dialog of a matched window instead of the window itself")
+	raiseAll := flag.Bool("raise-all", false, "raise all matching windows together and focus the topmost")
+	reverse := flag.Bool("reverse", false, "cycle through several matches in the opposite direction")
+	reverseShort := flag.Bool("R", false, "cycle through several matches in the opposite direction")
+	mru := flag.Bool("mru", false, "same as --cycle-order mru")
+	cycleOrder := flag.String("cycle-order", "stacking", "order to cycle through several matches in: stacking, mru (by when jumpkwapp last activated them, like Alt-Tab) or caption")
+	index := flag.Int("index", 0, "pick the Nth match from the bottom of the stack instead of cycling (1-based; negative counts from the top)")
+	persistentCycle := flag.Bool("persistent-cycle", false, "remember which match was activated last with the same filters and cycle on from it")
+	cycleSkipMinimized := flag.Bool("cycle-skip-minimized", false, "when cycling through several matches, pass over minimized ones")
+	cycleCurrentScreen := flag.Bool("cycle-current-screen", false, "when cycling through several matches, stay on the active window's screen")
+	cycleSamePID := flag.Bool("cycle-same-pid", false, "when cycling through several matches, stay among the windows of the active window's process")
+	cycleIncludeMinimized := flag.Bool("cycle-include-minimized", false, "when cycling through several matches, restore minimized ones in turn (the default)")
+	preferVisible := flag.Bool("prefer-visible", false, "when no match is active, pick one that is not minimized if there is any")
+	all := flag.Bool("all", false, "same as --raise-all")
+	prefer := flag.String("prefer", "newest", "window to pick when no match is active: newest (top of the stacking order) or oldest (bottom)")
+	newest := flag.Bool("newest", false, "when no match is active, pick the most recently opened one (needs --track-windows running)")
+	oldest := flag.Bool("oldest", false, "when no match is active, pick the one opened first (needs --track-windows running)")
+	openedWithin := flag.Duration("opened-within", 0, "only consider windows opened within this long (e.g. 30s; needs --track-windows running)")
+	launchDebounce := flag.Duration("launch-debounce", 0, "skip the launch if the same command was launched within this long (e.g. 2s)")
+	var focusAfterLaunch optionalString
+	flag.Var(&focusAfterLaunch, "focus-after-launch", "after launching the command, wait until a matching window has appeared and been activated (--focus-after-launch=TIMEOUT; default 10s)")
+	detachIO := flag.Bool("detach-io", false, "connect the launched command's stdin/stdout/stderr to /dev/null")
+	preferCurrentScreen := flag.Bool("prefer-current-screen", false, "activate and cycle through matches on the focused screen before those on other screens")
+	activateRetries := flag.Int("activate-retries", 0, "re-assert activation up to N times if the window does not get focus")
 	command := flag.String("command", "", "command to run when no matching window is found")
 	commandShort := flag.String("c", "", "command to run when no matching window is found")
+	var commandFallbacks stringList
+	flag.Var(&commandFallbacks, "command-fallback", "command to try if the previous one fails to start (repeatable)")
+	launchDesktop := flag.String("launch-desktop", "", "launch this XDG desktop entry (e.g. org.kde.konsole.desktop) when no matching window is found, instead of a command")
+	pid := flag.Int("pid", 0, "filter by the process ID owning the window")
+	pidShort := flag.Int("p", 0, "filter by the process ID owning the window")
+	cmdline := flag.String("cmdline", "", "filter by a substring of the owning process's command line (/proc/PID/cmdline)")
+	desktopFile := flag.String("desktop-file", "", "filter by the window's desktop file name (e.g. org.mozilla.firefox)")
+	resourceName := flag.String("name", "", "filter by window resource name (exact match)")
+	resourceNameShort := flag.String("n", "", "filter by window resource name (exact match)")
+	role := flag.String("role", "", "filter by window role (exact match, e.g. browser)")
+	match := flag.String("match", "", "filter with an expression, e.g. 'class=firefox && caption~\"Gmail\" && !minimized'")
+	pwa := flag.String("pwa", "", "match an installed Chromium or Firefox web app by app id or name")
+	var excludeClasses, excludeCaptions stringList
+	flag.Var(&excludeClasses, "exclude-class", "skip windows of this class (exact match, repeatable)")
+	flag.Var(&excludeCaptions, "exclude-caption", "skip windows whose caption matches this regex (case-insensitive, repeatable)")
+	windowID := flag.String("window-id", "", "match only the window with this KWin internal ID (UUID)")
+	tmpDir := flag.String("tmp-dir", "", "directory for the generated KWin script (default $TMPDIR or /tmp)")
+	profileName := flag.String("profile", "", "use the named filter profile from the config file")
+	configPath := flag.String("config", "", "path to the config file (default $XDG_CONFIG_HOME/jumpkwapp/config.json)")
+	dumpWindows := flag.Bool("dump-windows", false, "list all windows with their class, name, PID, ID, and caption, ignoring filters")
+	trackWindows := flag.Bool("track-windows", false, "keep running and record when each window is opened, for --opened-within, --newest, and --oldest")
+	doctor := flag.Bool("doctor", false, "check that the D-Bus and KWin scripting environment works, then exit")
+	waitForKWin := flag.Duration("wait-for-kwin", 0, "wait up to this long for KWin to appear on the session bus (e.g. 10s)")
+	exitCount := flag.Bool("exit-count", false, "exit with the number of matching windows (capped at 254; 255 on error)")
+	statsFile := flag.String("stats-file", "", "append a CSV record of each invocation's outcome to this file")
+	templateDebug := flag.Bool("template-debug", false, "print the generated script, annotated with its parameters, to stderr")
+	quiet := flag.Bool("quiet", false, "suppress warnings")
+	quietShort := flag.Bool("q", false, "suppress warnings")
+	dbusName := flag.String("dbus-name", defaultDBusName, "D-Bus name prefix for the listener object path and interface")
+
+	flag.CommandLine.Init(flag.CommandLine.Name(), flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return config{}, err
+		}
+		// Parsing stops at the bad flag, which may come before --exit-count.
+		return config{exitCount: *exitCount || flagGiven(os.Args[1:], "exit-count")}, errFlagSyntax
+	}
 
-	flag.Parse()
+	cfg := config{
+		filterClasses:       filterClasses,
+		smartClass:          *smartClass,
+		ignoreCase:          *ignoreCase || *ignoreCaseShort,
+		filterAlt:           firstNonEmpty(*filterAlt, *filterAltShort),
+		captionFlags:        strings.TrimSpace(*captionFlags),
+		captionFullMatch:    *captionFullMatch,
+		filterTitle:         *filterTitle,
+		captionExact:        *captionExact,
+		filterRegex:         firstNonEmpty(*filterRegex, *filterRegexShort),
+		captionFirst:        *captionFirst,
+		allFilters:          *allFilters,
+		fuzzy:               strings.TrimSpace(*fuzzy),
+		currentDesktop:      *currentDesktop || *currentDesktopShort,
+		desktop:             strings.TrimSpace(*desktop),
+		activity:            strings.TrimSpace(*activity),
+		currentActivity:     *currentActivity,
+		screen:              strings.TrimSpace(*screen),
+		currentScreen:       *currentScreen,
+		includeSkipTaskbar:  *includeSkipTaskbar,
+		onlyTaskbar:         *onlyTaskbar,
+		other:               *other,
+		includeSpecial:      *includeSpecial,
+		visibleOnly:         *visibleOnly,
+		minimizedOnly:       *minimizedOnly,
+		skipMinimized:       *skipMinimized,
+		state:               strings.ToLower(strings.TrimSpace(*state)),
+		toggle:              *toggle || *toggleShort,
+		toggleBack:          *toggleBack,
+		shade:               *shade,
+		summon:              *summon || *summonToScreen,
+		summonToScreen:      *summonToScreen,
+		noDesktopSwitch:     *noDesktopSwitch,
+		sendToScreen:        strings.TrimSpace(*sendToScreen),
+		toCurrentScreen:     *toCurrentScreen,
+		sendToActivity:      strings.TrimSpace(*sendToActivity),
+		sendToDesktop:       strings.TrimSpace(*sendToDesktop),
+		follow:              *follow,
+		solo:                *solo,
+		warpPointer:         *warp,
+		flash:               *flash,
+		expose:              *expose,
+		pick:                *pick || *menu != "",
+		menu:                *menu,
+		maximize:            *maximize,
+		fullscreen:          *fullscreen,
+		pin:                 *pin,
+		keepAbove:           *keepAbove,
+		opacity:             *opacity,
+		tile:                strings.ToLower(strings.TrimSpace(*tile)),
+		includeDialogs:      *includeDialogs,
+		raiseAll:            *raiseAll || *all,
+		reverse:             *reverse || *reverseShort,
+		cycleOrder:          strings.ToLower(strings.TrimSpace(*cycleOrder)),
+		persistentCycle:     *persistentCycle,
+		index:               *index,
+		cycleSkipMinimized:  *cycleSkipMinimized,
+		cycleCurrentScreen:  *cycleCurrentScreen,
+		cycleSamePID:        *cycleSamePID,
+		preferVisible:       *preferVisible,
+		rememberGeometry:    *rememberGeometry,
+		prefer:              strings.ToLower(strings.TrimSpace(*prefer)),
+		preferCurrentScreen: *preferCurrentScreen,
+		commands:            launchCommands(firstNonEmpty(*command, *commandShort), commandFallbacks),
+		launchDesktop:       strings.TrimSpace(*launchDesktop),
+		windowID:            normalizeWindowID(*windowID),
+		pid:                 firstNonZero(*pid, *pidShort),
+		cmdline:             *cmdline,
+		openedWithin:        *openedWithin,
+		desktopFile:         strings.TrimSuffix(strings.TrimSpace(*desktopFile), ".desktop"),
+		resourceName:        firstNonEmpty(*resourceName, *resourceNameShort),
+		role:                *role,
+		match:               *match,
+		excludeClasses:      excludeClasses,
+		excludeCaptions:     excludeCaptions,
+		dbusName:            strings.TrimSpace(*dbusName),
+		tmpDir:              *tmpDir,
+		quiet:               *quiet || *quietShort,
+		profile:             strings.TrimSpace(*profileName),
+		configPath:          *configPath,
+		dumpWindows:         *dumpWindows,
+		trackWindows:        *trackWindows,
+		activateRetries:     *activateRetries,
+		doctor:              *doctor,
+		statsFile:           *statsFile,
+		exitCount:           *exitCount,
+		waitForKWin:         *waitForKWin,
+		detachIO:            *detachIO,
+		templateDebug:       *templateDebug,
+		launchDebounce:      *launchDebounce,
+	}
 
-	return config{
-		filterClass:    firstNonEmpty(*filterClass, *filterClassShort),
-		filterAlt:      firstNonEmpty(*filterAlt, *filterAltShort),
-		filterRegex:    firstNonEmpty(*filterRegex, *filterRegexShort),
-		currentDesktop: *currentDesktop || *currentDesktopShort,
-		toggle:         *toggle || *toggleShort,
-		command:        strings.TrimSpace(firstNonEmpty(*command, *commandShort)),
+	var err error
+	if cfg.argv, err = commandArgs(os.Args[1:], flag.Args()); err != nil {
+		return cfg, err
+	}
+	if cfg.pid < 0 {
+		return cfg, fmt.Errorf("--pid must be a positive process ID, got %d", cfg.pid)
+	}
+	if app := strings.TrimSpace(*pwa); app != "" {
+		cfg.match = andMatch(pwaMatch(app), cfg.match)
+	}
+	if err := validRegexFlags(cfg.captionFlags); err != nil {
+		return cfg, fmt.Errorf("--caption-flags: %w", err)
+	}
+	if cfg.minimizedOnly && (cfg.skipMinimized || cfg.visibleOnly) {
+		return cfg, errors.New("--minimized-only cannot be combined with --skip-minimized or --visible-only")
+	}
+	if cfg.state != "" {
+		switch strings.TrimPrefix(cfg.state, "!") {
+		case "fullscreen", "maximized", "normal":
+		default:
+			return cfg, fmt.Errorf("--state must be fullscreen, maximized, or normal, got %q", *state)
+		}
+	}
+	if cfg.index != 0 && (cfg.persistentCycle || cfg.raiseAll) {
+		return cfg, errors.New("--index cannot be combined with --persistent-cycle or --raise-all")
+	}
+	if *mru {
+		if cfg.cycleOrder != "stacking" && cfg.cycleOrder != "mru" {
+			return cfg, fmt.Errorf("--mru and --cycle-order %s cannot be used together", cfg.cycleOrder)
+		}
+		cfg.cycleOrder = "mru"
+	}
+	if cfg.cycleOrder != "stacking" && cfg.cycleOrder != "mru" && cfg.cycleOrder != "caption" {
+		return cfg, fmt.Errorf("--cycle-order must be stacking, mru, or caption, got %q", *cycleOrder)
+	}
+	if focusAfterLaunch.set {
+		cfg.focusAfterLaunch = defaultFocusAfterLaunch
+		if focusAfterLaunch.value != "" {
+			timeout, err := time.ParseDuration(focusAfterLaunch.value)
+			if err != nil || timeout <= 0 {
+				return cfg, fmt.Errorf("--focus-after-launch must be a positive duration such as 5s, got %q", focusAfterLaunch.value)
+			}
+			cfg.focusAfterLaunch = timeout
+		}
+	}
+	launchers := 0
+	for _, set := range []bool{len(cfg.commands) > 0, cfg.launchDesktop != "", len(cfg.argv) > 0} {
+		if set {
+			launchers++
+		}
+	}
+	if launchers > 1 {
+		return cfg, errors.New("only one of -c/--command, --launch-desktop and a command after -- can be given")
+	}
+	if cfg.persistentCycle && cfg.cycleOrder != "stacking" {
+		return cfg, fmt.Errorf("--persistent-cycle cannot be combined with --mru or --cycle-order %s", cfg.cycleOrder)
+	}
+	if *cycleSkipMinimized && *cycleIncludeMinimized {
+		return cfg, errors.New("--cycle-skip-minimized and --cycle-include-minimized cannot be used together")
+	}
+	if cfg.desktop != "" && cfg.currentDesktop {
+		return cfg, errors.New("--desktop and --current-desktop cannot be used together")
+	}
+	if cfg.activity != "" && cfg.currentActivity {
+		return cfg, errors.New("--activity and --current-activity cannot be used together")
+	}
+	action, err := pickAction([]actionFlag{
+		{name: "raise-only", action: "raise", set: *raiseOnly},
+		{name: "urgent", action: "urgent", set: *urgent},
+		{name: "close", action: "close", set: *closeWindow},
+		{name: "close-all", action: "close-all", set: *closeAll},
+		{name: "hide", action: "hide", set: *hide},
+		{name: "send-to-desktop", action: "send-to-desktop", set: cfg.sendToDesktop != ""},
+		{name: "own-desktop", action: "own-desktop", set: ownDesktop.set},
+		{name: "swap", action: "swap", set: *swap},
+	})
+	if err != nil {
+		return cfg, err
+	}
+	cfg.action = action
+	switch cfg.tile {
+	case "", "left", "right", "top", "bottom", "topleft", "topright", "bottomleft", "bottomright":
+	default:
+		return cfg, fmt.Errorf("--tile must be left, right, top, bottom, topleft, topright, bottomleft, or bottomright, got %q", *tile)
+	}
+	if math.IsNaN(cfg.opacity) || cfg.opacity < 0 || cfg.opacity > 1 {
+		return cfg, fmt.Errorf("--opacity must be above 0 and at most 1, got %g", cfg.opacity)
+	}
+	if cfg.size, err = parseSize(*geometry); err != nil {
+		return cfg, fmt.Errorf("--geometry: %w", err)
+	}
+	if cfg.place, err = parsePlacement(*place); err != nil {
+		return cfg, fmt.Errorf("--place: %w", err)
+	}
+	if *scratchpad {
+		if *shade {
+			return cfg, errors.New("--scratchpad and --shade cannot be used together")
+		}
+		// A dropdown: shown where the user is, hidden again by the same key.
+		cfg.summon = true
+		cfg.toggle = true
+		if cfg.size == nil && cfg.place == nil && !cfg.maximize && cfg.tile == "" {
+			cfg.size, _ = parseSize(defaultScratchpadSize)
+			cfg.place, _ = parsePlacement(defaultScratchpadPlace)
+		}
+	}
+	if cfg.tile != "" && cfg.maximize {
+		return cfg, errors.New("--tile and --maximize cannot be used together")
+	}
+	if cfg.rememberGeometry && !cfg.toggle {
+		return cfg, errors.New("--remember-geometry requires --toggle or --scratchpad")
+	}
+	if cfg.shade && cfg.toggle {
+		return cfg, errors.New("--shade and --toggle cannot be used together")
+	}
+	if cfg.toggleBack && (cfg.toggle || cfg.shade) {
+		return cfg, errors.New("--toggle-back cannot be combined with --toggle, --scratchpad or --shade")
+	}
+	if ownDesktop.set {
+		// Without a name, run names the desktop after the first class once
+		// groups and profiles have been expanded.
+		cfg.sendToDesktop = strings.TrimSpace(ownDesktop.value)
 	}
+	if cfg.follow && cfg.sendToDesktop == "" && cfg.action != "own-desktop" {
+		return cfg, errors.New("--follow requires --send-to-desktop")
+	}
+	if (cfg.size != nil || cfg.place != nil) && (cfg.maximize || cfg.tile != "") {
+		return cfg, errors.New("--geometry and --place cannot be combined with --maximize or --tile")
+	}
+	if cfg.action != "" {
+		// These adjust the window as it is activated, which another action
+		// does not do.
+		for _, f := range []struct {
+			name string
+			set  bool
+		}{
+			{"shade", cfg.shade},
+			{"toggle-back", cfg.toggleBack},
+			{"scratchpad", *scratchpad},
+			{"solo", cfg.solo},
+			{"warp-pointer", cfg.warpPointer},
+			{"flash", cfg.flash},
+			{"maximize", cfg.maximize},
+			{"fullscreen", cfg.fullscreen},
+			{"pin", cfg.pin},
+			{"keep-above", cfg.keepAbove},
+			{"opacity", cfg.opacity > 0},
+			{"geometry", cfg.size != nil},
+			{"place", cfg.place != nil},
+			{"tile", cfg.tile != ""},
+		} {
+			if f.set {
+				return cfg, fmt.Errorf("--%s only applies when activating the window", f.name)
+			}
+		}
+	}
+	if cfg.expose && (cfg.action != "" || cfg.raiseAll) {
+		return cfg, errors.New("--expose cannot be combined with --raise-all or another action")
+	}
+	if cfg.pick && (cfg.action != "" || cfg.raiseAll || cfg.expose || cfg.index != 0) {
+		return cfg, errors.New("--pick and --menu cannot be combined with --expose, --index, --raise-all or another action")
+	}
+	if cfg.action != "" && cfg.raiseAll {
+		return cfg, errors.New("--raise-all cannot be combined with another action")
+	}
+	if cfg.sendToScreen != "" && cfg.toCurrentScreen {
+		return cfg, errors.New("--send-to-screen and --to-current-screen cannot be used together")
+	}
+	if cfg.summonToScreen && (cfg.sendToScreen != "" || cfg.toCurrentScreen) {
+		return cfg, errors.New("--summon-to-screen cannot be combined with --send-to-screen or --to-current-screen")
+	}
+	if cfg.summon && cfg.sendToActivity != "" {
+		return cfg, errors.New("--summon and --send-to-activity cannot be used together")
+	}
+	if cfg.summon && cfg.noDesktopSwitch {
+		return cfg, errors.New("--summon and --no-desktop-switch cannot be used together")
+	}
+	if cfg.screen != "" && cfg.currentScreen {
+		return cfg, errors.New("--screen and --current-screen cannot be used together")
+	}
+	if *newest && *oldest {
+		return cfg, errors.New("--newest and --oldest cannot be used together")
+	}
+	if *newest {
+		cfg.prefer = "newest"
+		cfg.preferOpened = true
+	}
+	if *oldest {
+		cfg.prefer = "oldest"
+		cfg.preferOpened = true
+	}
+	if cfg.openedWithin < 0 {
+		return cfg, fmt.Errorf("--opened-within must not be negative, got %s", cfg.openedWithin)
+	}
+	if cfg.prefer != "newest" && cfg.prefer != "oldest" {
+		return cfg, fmt.Errorf("--prefer must be newest or oldest, got %q", *prefer)
+	}
+	if cfg.activateRetries < 0 || cfg.activateRetries > maxActivateRetries {
+		return cfg, fmt.Errorf("--activate-retries must be between 0 and %d", maxActivateRetries)
+	}
+
+	if glob := strings.TrimSpace(*filterGlob); glob != "" {
+		if cfg.filterRegex != "" {
+			return cfg, errors.New("--glob and -fr/--filter-regex cannot be used together")
+		}
+		cfg.filterRegex = globToRegex(glob)
+	}
+	if cfg.fuzzy != "" && (len(cfg.filterClasses) > 0 || cfg.filterRegex != "" || cfg.captionFilters() > 0) {
+		return cfg, errors.New("--fuzzy cannot be combined with the class or caption filters")
+	}
+	if cfg.captionFilters() > 1 {
+		return cfg, errors.New("only one of -fa/--filter-alternative, --title, and --caption-exact can be used")
+	}
+
+	return cfg, nil
 }
 
 func run(cfg config) error {
-	if cfg.filterClass == "" && cfg.filterAlt == "" && cfg.filterRegex == "" {
-		return errors.New("you need to specify a window filter (-f, -fa, or -fr)")
+	if cfg.doctor {
+		return doctor(os.Stdout, cfg.configPath)
+	}
+
+	fileCfg, err := loadFileConfig(cfg.configPath)
+	if err != nil {
+		return err
+	}
+	cfg, err = resolveProfile(cfg, fileCfg)
+	if err != nil {
+		return err
+	}
+	cfg, err = expandGroups(cfg, fileCfg)
+	if err != nil {
+		return err
+	}
+	cfg = applyIgnoreList(cfg, fileCfg)
+	cfg, err = defaultOwnDesktop(cfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.menu != "" && !cfg.hasFilter() {
+		// As a plain window switcher the menu offers every window.
+		cfg.filterRegex = ".*"
+	}
+	if !cfg.dumpWindows && !cfg.trackWindows && !cfg.hasFilter() {
+		return errors.New("you need to specify a window filter (-f, -fa, --title, --caption-exact, -fr, --glob, --fuzzy, -p, --cmdline, --desktop-file, -n, --role, --match, --pwa, --window-id, or --profile)")
+	}
+
+	if !cfg.quiet && !cfg.dumpWindows && !cfg.trackWindows {
+		for _, warning := range filterWarnings(cfg) {
+			fmt.Fprintf(os.Stderr, "WARNING: %s\n", warning)
+		}
+	}
+
+	matchExpr, err := compileMatch(cfg.match)
+	if err != nil {
+		return fmt.Errorf("--match: %w", err)
+	}
+
+	listenerPath, listenerIface, err := listenerNames(cfg.dbusName)
+	if err != nil {
+		return err
+	}
+
+	if cfg.tmpDir != "" {
+		if err := checkWritableDir(cfg.tmpDir); err != nil {
+			return err
+		}
 	}
 
 	conn, err := dbus.SessionBus()
@@ -118,44 +932,332 @@ func run(cfg config) error {
 	}
 	defer conn.Close()
 
+	if cfg.waitForKWin > 0 {
+		if err := waitForKWin(conn, cfg.waitForKWin); err != nil {
+			return err
+		}
+	}
+
+	if cfg.dumpWindows {
+		return dumpWindows(conn, cfg.tmpDir, listenerPath, listenerIface)
+	}
+	if cfg.trackWindows {
+		return trackWindows(conn, cfg.tmpDir, listenerPath, listenerIface)
+	}
+
+	sendToActivityID := ""
+	if cfg.sendToActivity != "" {
+		sendToActivityID, err = resolveActivity(conn, cfg.sendToActivity)
+		if err != nil {
+			return fmt.Errorf("--send-to-activity: %w", err)
+		}
+	}
+
+	savedGeometryJSON := "{}"
+	if cfg.rememberGeometry {
+		savedGeometryJSON, err = loadStateJSON[savedGeometry](geometryStatePath())
+		if err != nil {
+			return fmt.Errorf("load saved geometry: %w", err)
+		}
+	}
+	flashOpacitiesJSON := "{}"
+	if cfg.flash {
+		flashOpacitiesJSON, err = runningFlashesJSON(flashStatePath(), time.Now())
+		if err != nil {
+			return fmt.Errorf("load running flashes: %w", err)
+		}
+	}
+	lastCycled := ""
+	if cfg.persistentCycle {
+		cycles, err := loadState[string](cycleStatePath())
+		if err != nil {
+			return fmt.Errorf("load cycle position: %w", err)
+		}
+		lastCycled = cycles[describeFilter(cfg)]
+	}
+	previousWindow := ""
+	if cfg.toggleBack {
+		previous, err := loadState[string](previousStatePath())
+		if err != nil {
+			return fmt.Errorf("load previous window: %w", err)
+		}
+		previousWindow = previous[describeFilter(cfg)]
+	}
+	openTimesJSON := "{}"
+	var openedAfter int64
+	if cfg.openedWithin > 0 || cfg.preferOpened {
+		if !tracking(openedStatePath()) && !cfg.quiet {
+			fmt.Fprintln(os.Stderr, "WARNING: no window opening times are recorded; --opened-within, --newest, and --oldest need jumpkwapp --track-windows running")
+		}
+		openTimesJSON, err = loadStateJSON[int64](openedStatePath())
+		if err != nil {
+			return fmt.Errorf("load opening times: %w", err)
+		}
+		if cfg.openedWithin > 0 {
+			openedAfter = time.Now().Add(-cfg.openedWithin).UnixMilli()
+		}
+	}
+	activationTimesJSON := "{}"
+	if cfg.cycleOrder == "mru" {
+		activationTimesJSON, err = loadStateJSON[int64](activationStatePath())
+		if err != nil {
+			return fmt.Errorf("load activation times: %w", err)
+		}
+	}
+
+	// The script only reports back when something on this side is waiting
+	// for its decision or outcome.
+	needsOutcome := cfg.statsFile != "" || cfg.exitCount || cfg.warpPointer || cfg.expose || cfg.pick ||
+		cfg.rememberGeometry || cfg.cycleOrder == "mru" || cfg.persistentCycle || cfg.toggleBack ||
+		cfg.focusAfterLaunch > 0 || cfg.flash
+	needsListener := cfg.canLaunch() || needsOutcome
+
 	dbusAddress := ""
-	if cfg.command != "" {
+	if needsListener {
 		dbusAddress, err = getUniqueName(conn)
 		if err != nil {
 			return fmt.Errorf("get unique bus name: %w", err)
 		}
 	}
 
-	script, err := renderScript(scriptParams{
-		ClassName:          cfg.filterClass,
-		CaptionPattern:     cfg.filterAlt,
-		ClassRegex:         cfg.filterRegex,
-		Toggle:             cfg.toggle,
-		CurrentDesktopOnly: cfg.currentDesktop,
-		DBusAddress:        dbusAddress,
-	})
-	if err != nil {
-		return fmt.Errorf("render KWin script: %w", err)
+	// --cmdline needs to look at the window owners in /proc, which only this
+	// side can read, so the windows are listed first and the matching
+	// processes are passed on to the script as a PID restriction.
+	restrictPIDs := cfg.cmdline != ""
+	var pids []int
+	if restrictPIDs {
+		windows, err := queryWindows(conn, cfg.tmpDir, listenerPath, listenerIface)
+		if err != nil {
+			return fmt.Errorf("list windows: %w", err)
+		}
+		pids = filterPIDs(windowPIDs(windows), cmdlineContains(cfg.cmdline, processCmdline))
+	}
+
+	params := scriptParams{
+		ClassNames:          cfg.filterClasses,
+		SmartClass:          cfg.smartClass,
+		IgnoreCase:          cfg.ignoreCase,
+		CaptionPattern:      cfg.filterAlt,
+		CaptionFlags:        cfg.captionFlags,
+		CaptionFullMatch:    cfg.captionFullMatch,
+		TitleSubstring:      cfg.filterTitle,
+		CaptionExact:        cfg.captionExact,
+		ClassRegex:          cfg.filterRegex,
+		CaptionFirst:        cfg.captionFirst,
+		AllFilters:          cfg.allFilters,
+		Fuzzy:               cfg.fuzzy,
+		Toggle:              cfg.toggle,
+		Shade:               cfg.shade,
+		CurrentDesktopOnly:  cfg.currentDesktop,
+		Desktop:             cfg.desktop,
+		Activity:            cfg.activity,
+		CurrentActivity:     cfg.currentActivity,
+		Screen:              cfg.screen,
+		CurrentScreen:       cfg.currentScreen,
+		IncludeSkipTaskbar:  cfg.includeSkipTaskbar,
+		Other:               cfg.other,
+		IncludeSpecial:      cfg.includeSpecial,
+		VisibleOnly:         cfg.visibleOnly,
+		MinimizedOnly:       cfg.minimizedOnly,
+		SkipMinimized:       cfg.skipMinimized,
+		State:               cfg.state,
+		Summon:              cfg.summon,
+		SummonToScreen:      cfg.summonToScreen,
+		NoDesktopSwitch:     cfg.noDesktopSwitch,
+		SendToScreen:        cfg.sendToScreen,
+		ToCurrentScreen:     cfg.toCurrentScreen,
+		SendToActivity:      sendToActivityID,
+		Solo:                cfg.solo,
+		WarpPointer:         cfg.warpPointer,
+		Flash:               cfg.flash,
+		Expose:              cfg.expose,
+		Pick:                cfg.pick,
+		FlashSteps:          flashSteps,
+		FlashInterval:       flashInterval.Milliseconds(),
+		FlashOpacities:      flashOpacitiesJSON,
+		Maximize:            cfg.maximize,
+		Fullscreen:          cfg.fullscreen,
+		Pin:                 cfg.pin,
+		KeepAbove:           cfg.keepAbove,
+		Opacity:             cfg.opacity,
+		Size:                cfg.size,
+		Place:               cfg.place,
+		Tile:                cfg.tile,
+		Action:              cfg.action,
+		SendToDesktop:       cfg.sendToDesktop,
+		Follow:              cfg.follow,
+		IncludeDialogs:      cfg.includeDialogs,
+		RaiseAll:            cfg.raiseAll,
+		Reverse:             cfg.reverse,
+		CycleOrder:          cfg.cycleOrder,
+		PersistentCycle:     cfg.persistentCycle,
+		Index:               cfg.index,
+		LastCycled:          lastCycled,
+		ToggleBack:          cfg.toggleBack,
+		FocusAfterLaunch:    cfg.focusAfterLaunch > 0,
+		AwaitLaunched:       cfg.canLaunch(),
+		LaunchTimeout:       defaultFocusAfterLaunch.Milliseconds(),
+		PreviousWindow:      previousWindow,
+		ActivationTimes:     activationTimesJSON,
+		CycleSkipMinimized:  cfg.cycleSkipMinimized,
+		CycleCurrentScreen:  cfg.cycleCurrentScreen,
+		CycleSamePID:        cfg.cycleSamePID,
+		PreferVisible:       cfg.preferVisible,
+		RememberGeometry:    cfg.rememberGeometry,
+		SavedGeometry:       savedGeometryJSON,
+		Prefer:              cfg.prefer,
+		PreferOpened:        cfg.preferOpened,
+		PreferCurrentScreen: cfg.preferCurrentScreen,
+		ActivateRetries:     cfg.activateRetries,
+		ActivateRetryDelay:  activationRetryDelay.Milliseconds(),
+		DBusAddress:         dbusAddress,
+		WindowID:            cfg.windowID,
+		PID:                 cfg.pid,
+		RestrictPIDs:        restrictPIDs,
+		PIDs:                pids,
+		OpenedAfter:         openedAfter,
+		OpenTimes:           openTimesJSON,
+		DesktopFile:         cfg.desktopFile,
+		ResourceName:        cfg.resourceName,
+		Role:                cfg.role,
+		MatchExpr:           matchExpr,
+		ExcludeClasses:      cfg.excludeClasses,
+		ExcludeCaptions:     cfg.excludeCaptions,
+		ExcludeClassRegexes: cfg.excludeClassRegexes,
+		ListenerPath:        string(listenerPath),
+		ListenerInterface:   listenerIface,
+		PluginName:          scriptPluginName(),
+	}
+	var listener *launchListener
+	if needsListener {
+		listener = &launchListener{
+			ch:       make(chan launchDecision, 1),
+			outcomes: make(chan string, 1),
+		}
+		if err := conn.Export(listener, listenerPath, listenerIface); err != nil {
+			return fmt.Errorf("export listener on D-Bus: %w", err)
+		}
+		defer func() {
+			_ = conn.Export(nil, listenerPath, listenerIface)
+		}()
 	}
 
-	scriptFile, err := writeTempScript(script)
+	res, err := jumpScript(conn, listener, cfg, params)
 	if err != nil {
 		return err
 	}
+	decision, outcome, runErr := res.decision, res.outcome, res.err
+	if len(outcome.Choices) > 0 && decision == decisionMatched && runErr == nil {
+		id, err := pickWindow(cfg.menu, outcome.Choices, os.Stdin, os.Stderr)
+		if err != nil {
+			return err
+		}
+		picked, err := jumpScript(conn, listener, pickedConfig(cfg, id), pickedParams(params, id))
+		if err != nil {
+			return err
+		}
+		if picked.err != nil {
+			return picked.err
+		}
+		outcome.Action = "picked"
+		outcome.Pointer = picked.outcome.Pointer
+	}
+	if outcome.Pointer != nil && decision == decisionMatched {
+		if err := warpPointer(*outcome.Pointer, os.Getenv); err != nil {
+			runErr = fmt.Errorf("warp pointer: %w", err)
+		}
+	}
+
+	if cfg.statsFile != "" {
+		err := appendStats(cfg.statsFile, statsRecord{
+			Time:    time.Now(),
+			Filter:  describeFilter(cfg),
+			Action:  outcome.Action,
+			Matched: outcome.Matched,
+		})
+		if err != nil && runErr == nil {
+			runErr = fmt.Errorf("record stats: %w", err)
+		}
+	}
+
+	if runErr == nil && cfg.exitCount {
+		return exitStatus(countExitStatus(outcome.Matched))
+	}
+	return runErr
+}
+
+// pickedConfig is cfg narrowed to the window picked with --pick or --menu,
+// which is activated rather than offered again, and never launched.
+func pickedConfig(cfg config, id string) config {
+	cfg.windowID = id
+	cfg.pick = false
+	cfg.commands = nil
+	cfg.launchDesktop = ""
+	cfg.argv = nil
+	return cfg
+}
+
+// pickedParams are the script parameters for activating the picked window.
+func pickedParams(params scriptParams, id string) scriptParams {
+	params.WindowID = id
+	params.Pick = false
+	params.PluginName = scriptPluginName()
+	return params
+}
+
+// scriptRun is what came of one jumpScript: the launch decision, the outcome
+// the script reported, and the first error in acting on them, which still
+// leaves the outcome worth recording.
+type scriptRun struct {
+	decision launchDecision
+	outcome  scriptOutcome
+	err      error
+}
+
+// jumpScript renders params into a KWin script and runs it. When listener is
+// set, it waits for the script to report back, launches cfg's command if no
+// window matched, and records the state the outcome changed. run uses it a
+// second time for the window picked with --pick or --menu, so the picked
+// window is activated on the same connection and listener.
+func jumpScript(conn *dbus.Conn, listener *launchListener, cfg config, params scriptParams) (scriptRun, error) {
+	script, err := renderScript(params)
+	if err != nil {
+		return scriptRun{}, fmt.Errorf("render KWin script: %w", err)
+	}
+	if cfg.templateDebug {
+		script = annotateScript(params, script)
+		fmt.Fprint(os.Stderr, script)
+	}
+
+	scriptFile, err := writeTempScript(cfg.tmpDir, script)
+	if err != nil {
+		return scriptRun{}, err
+	}
 	defer os.Remove(scriptFile)
 
-	scriptPath, err := loadKWinScript(conn, scriptFile)
+	scriptPath, err := loadKWinScript(conn, scriptFile, params.PluginName)
 	if err != nil {
-		return err
+		return scriptRun{}, err
 	}
 
 	scriptObj := conn.Object(kwinService, scriptPath)
+	// Activation retries and --flash run on timers inside the script, so it
+	// has to stay loaded until the last one could have fired.
+	linger := time.Duration(cfg.activateRetries) * activationRetryDelay
+	if cfg.flash {
+		linger = max(linger, flashSteps*flashInterval)
+	}
 	stopped := false
 	defer func() {
 		if stopped {
 			return
 		}
-		if cfg.command == "" {
+		if listener == nil {
+			if linger > 0 {
+				time.Sleep(linger)
+				_ = stopScript(scriptObj)
+				return
+			}
 			go func() {
 				time.Sleep(150 * time.Millisecond)
 				_ = stopScript(scriptObj)
@@ -165,61 +1267,246 @@ func run(cfg config) error {
 		_ = stopScript(scriptObj)
 	}()
 
-	var listener *launchListener
-	if cfg.command != "" {
-		listener = &launchListener{ch: make(chan bool, 1)}
-		if err := conn.Export(listener, listenerObjectPath, listenerInterface); err != nil {
-			return fmt.Errorf("export listener on D-Bus: %w", err)
-		}
-		defer func() {
-			_ = conn.Export(nil, listenerObjectPath, listenerInterface)
-		}()
+	if listener != nil {
+		// A script run before this one on the same listener may have left
+		// a decision nobody waited for.
+		listener.drain()
 	}
-
 	if err := scriptObj.Call(kwinScriptIface+".run", 0).Err; err != nil {
-		return fmt.Errorf("run KWin script: %w", err)
+		return scriptRun{}, fmt.Errorf("run KWin script: %w", err)
 	}
 
-	if cfg.command == "" {
-		return nil
+	if listener == nil {
+		return scriptRun{decision: decisionMatched}, nil
 	}
 
-	shouldLaunch, err := waitForDecision(listener.ch, responseTimeout)
+	decision := decisionMatched
+	if cfg.canLaunch() {
+		decision, err = waitForDecision(listener.ch, responseTimeout)
+		if err != nil {
+			return scriptRun{}, fmt.Errorf("wait for KWin response: %w", err)
+		}
+	}
+
+	// The decision is sent before the script acts on the matching windows,
+	// so a failure after that only shows in the outcome, which is waited for
+	// whenever the script reports back at all.
+	outcome, err := waitForOutcome(listener.outcomes, responseTimeout)
 	if err != nil {
-		return fmt.Errorf("wait for KWin response: %w", err)
+		return scriptRun{}, fmt.Errorf("wait for KWin response: %w", err)
+	}
+	if outcome.Action == "error" {
+		decision = decisionError
+	}
+
+	if decision == decisionMatched {
+		time.Sleep(linger)
+	}
+	// The script stays loaded through the launch to activate and adjust the
+	// new window. With --focus-after-launch jumpkwapp waits for it to do so;
+	// otherwise the script is left loaded and unloads itself.
+	awaitWindow := decision == decisionLaunch && cfg.focusAfterLaunch > 0
+	leaveLoaded := decision == decisionLaunch && cfg.focusAfterLaunch == 0
+	if !awaitWindow && !leaveLoaded {
+		if err := stopScript(scriptObj); err != nil {
+			return scriptRun{}, fmt.Errorf("stop KWin script: %w", err)
+		}
+		stopped = true
 	}
 
-	if err := stopScript(scriptObj); err != nil {
-		return fmt.Errorf("stop KWin script: %w", err)
+	var runErr error
+	switch decision {
+	case decisionError:
+		runErr = errors.New("KWin script failed; check the KWin journal for details")
+	case decisionLaunch:
+		claimed, claimErr := true, error(nil)
+		if cfg.launchDebounce > 0 {
+			launched := firstNonEmpty(cfg.launchDesktop, strings.Join(cfg.commands, "\n"), strings.Join(cfg.argv, "\n"))
+			claimed, claimErr = claimLaunch(launchLockPath(launched), cfg.launchDebounce, time.Now())
+		}
+		if claimErr != nil {
+			runErr = fmt.Errorf("debounce launch: %w", claimErr)
+		} else if !claimed {
+			outcome.Action = "debounced"
+		} else if err := launch(conn, cfg); err != nil {
+			runErr = fmt.Errorf("launch command: %w", err)
+		} else {
+			outcome.Action = "launched"
+			if leaveLoaded {
+				stopped = true // the script unloads itself once done
+			}
+			if awaitWindow {
+				if focused, err := waitForOutcome(listener.outcomes, cfg.focusAfterLaunch); err == nil {
+					outcome.Action = focused.Action
+				} else if !cfg.quiet {
+					fmt.Fprintf(os.Stderr, "WARNING: no matching window appeared within %s\n", cfg.focusAfterLaunch)
+				}
+			}
+		}
+	}
+	if !stopped {
+		if err := stopScript(scriptObj); err != nil && runErr == nil {
+			runErr = fmt.Errorf("stop KWin script: %w", err)
+		}
+		stopped = true
 	}
-	stopped = true
 
-	if shouldLaunch {
-		if err := launchCommand(cfg.command); err != nil {
-			return fmt.Errorf("launch command: %w", err)
+	if outcome.Activated != "" && cfg.cycleOrder == "mru" {
+		if err := recordActivation(activationStatePath(), outcome.Activated, time.Now()); err != nil {
+			runErr = fmt.Errorf("record activation: %w", err)
+		}
+	}
+	if outcome.Demoted != "" && cfg.cycleOrder == "mru" {
+		// The zero time sorts before every window, recorded or not.
+		if err := recordActivation(activationStatePath(), outcome.Demoted, time.Time{}); err != nil {
+			runErr = fmt.Errorf("record activation: %w", err)
+		}
+	}
+	for id, opacity := range outcome.Flashed {
+		now := time.Now()
+		if err := recordFlash(flashStatePath(), id, opacity, now, now.Add(flashSteps*flashInterval)); err != nil {
+			runErr = fmt.Errorf("record flash: %w", err)
+		}
+	}
+	if outcome.Activated != "" && cfg.persistentCycle {
+		if err := recordCyclePosition(cycleStatePath(), describeFilter(cfg), outcome.Activated); err != nil {
+			runErr = fmt.Errorf("record cycle position: %w", err)
+		}
+	}
+	if outcome.Previous != "" {
+		if err := recordPreviousWindow(previousStatePath(), describeFilter(cfg), outcome.Previous); err != nil {
+			runErr = fmt.Errorf("record previous window: %w", err)
+		}
+	}
+	if len(outcome.Geometry) > 0 {
+		if err := updateSavedGeometry(geometryStatePath(), outcome.Geometry); err != nil {
+			runErr = fmt.Errorf("save geometry: %w", err)
+		}
+	}
+	if len(outcome.Windows) > 0 && decision == decisionMatched {
+		if err := exposeWindows(conn, outcome.Windows); err != nil {
+			runErr = fmt.Errorf("open window overview: %w", err)
 		}
 	}
+	return scriptRun{decision: decision, outcome: outcome, err: runErr}, nil
+}
 
-	return nil
+// filterWarnings explains how combined filters are applied when more than one
+// filter type is set, since the filters are alternatives rather than all
+// having to match.
+func filterWarnings(cfg config) []string {
+	hasClass := len(cfg.filterClasses) > 0
+	hasRegex := cfg.filterRegex != ""
+	hasCaption := cfg.captionFilters() > 0
+
+	var warnings []string
+	if cfg.onlyTaskbar {
+		warnings = append(warnings, "--only-taskbar and the only-taskbar profile key are deprecated and do nothing: windows hidden from the task bar are skipped by default")
+	}
+	if cfg.allFilters {
+		return warnings
+	}
+	if hasClass && hasRegex {
+		warnings = append(warnings, "-f and -fr are alternatives: windows matching either class filter are considered (use --all-filters to require both)")
+	}
+	if hasCaption && (hasClass || hasRegex) && !cfg.captionFirst {
+		warnings = append(warnings, "the caption filter (-fa/--title/--caption-exact) is ignored when a class filter (-f/-fr) is set (use --all-filters to require both)")
+	}
+	return warnings
+}
+
+// launch starts the desktop entry given with --launch-desktop, the command
+// given after "--", or else the first of the commands that can be started.
+func launch(conn busConn, cfg config) error {
+	if cfg.launchDesktop != "" {
+		return launchDesktopEntry(conn, cfg.launchDesktop, cfg.detachIO)
+	}
+	if len(cfg.argv) > 0 {
+		return startProcess(exec.Command(cfg.argv[0], cfg.argv[1:]...), cfg.detachIO)
+	}
+	return launchCommand(cfg.commands, cfg.detachIO)
 }
 
-func launchCommand(command string) error {
-	if command == "" {
-		return nil
+// launchCommand starts the first of commands that can be started, without
+// waiting for it. Each command runs through the shell, so a command whose
+// program is not on $PATH is detected up front and skipped in favour of the
+// next one. With detachIO the child's stdio is left unset, which os/exec
+// connects to /dev/null, so GUI apps don't log into the terminal that ran
+// jumpkwapp.
+func launchCommand(commands []string, detachIO bool) error {
+	var errs []error
+	for _, command := range commands {
+		err := startCommand(command, detachIO)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", command, err))
+	}
+	return errors.Join(errs...)
+}
+
+func startCommand(command string, detachIO bool) error {
+	if program := commandProgram(command); program != "" {
+		if _, err := exec.LookPath(program); err != nil {
+			return err
+		}
+	}
+	return startProcess(exec.Command("sh", "-c", command), detachIO)
+}
+
+// startProcess starts cmd without waiting for it, handing it the activation
+// token jumpkwapp was started with. With detachIO the child's stdio is left
+// unset; otherwise it shares jumpkwapp's.
+func startProcess(cmd *exec.Cmd, detachIO bool) error {
+	cmd.Env = activationEnv(os.Environ(), activationToken(os.Getenv))
+	if !detachIO {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Stdin = os.Stdin
 	}
-	cmd := exec.Command("sh", "-c", command)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
 	return cmd.Start()
 }
 
-func waitForDecision(ch <-chan bool, timeout time.Duration) (bool, error) {
+// shellWords are builtins and keywords that commonly start a command but are
+// not programs on $PATH.
+var shellWords = map[string]bool{
+	"exec": true, "cd": true, "export": true, "eval": true, "command": true, "set": true,
+	"source": true, ".": true, "if": true, "for": true, "while": true, "until": true, "case": true, "!": true,
+}
+
+// commandProgram returns the program a simple shell command runs, or "" when
+// the command starts with a shell builtin, a variable assignment, quoting, or
+// other shell syntax and can't be checked up front.
+func commandProgram(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 || shellWords[fields[0]] {
+		return ""
+	}
+	if strings.ContainsAny(fields[0], "=\"'$`(){}<>|&;*?[]~") {
+		return ""
+	}
+	return fields[0]
+}
+
+func waitForDecision(ch <-chan launchDecision, timeout time.Duration) (launchDecision, error) {
 	select {
 	case decision := <-ch:
 		return decision, nil
 	case <-time.After(timeout):
-		return false, errors.New("timeout waiting for response from KWin script")
+		return decisionError, errors.New("timeout waiting for response from KWin script")
+	}
+}
+
+func waitForOutcome(ch <-chan string, timeout time.Duration) (scriptOutcome, error) {
+	select {
+	case payload := <-ch:
+		var outcome scriptOutcome
+		if err := json.Unmarshal([]byte(payload), &outcome); err != nil {
+			return scriptOutcome{}, fmt.Errorf("parse script outcome: %w", err)
+		}
+		return outcome, nil
+	case <-time.After(timeout):
+		return scriptOutcome{}, errors.New("timeout waiting for outcome from KWin script")
 	}
 }
 
@@ -227,8 +1514,8 @@ func stopScript(obj dbus.BusObject) error {
 	return obj.Call(kwinScriptIface+".stop", 0).Err
 }
 
-func writeTempScript(content string) (string, error) {
-	f, err := os.CreateTemp("", "jumpkwapp-*.js")
+func writeTempScript(dir, content string) (string, error) {
+	f, err := os.CreateTemp(dir, "jumpkwapp-*.js")
 	if err != nil {
 		return "", fmt.Errorf("create temp script: %w", err)
 	}
@@ -246,9 +1533,60 @@ func writeTempScript(content string) (string, error) {
 	return path, nil
 }
 
-func loadKWinScript(conn *dbus.Conn, scriptFile string) (dbus.ObjectPath, error) {
+// checkWritableDir verifies that dir exists and that files can be created in
+// it, so a bad --tmp-dir fails before anything is sent to KWin.
+func checkWritableDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("temp dir: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("temp dir %s is not a directory", dir)
+	}
+	probe, err := os.CreateTemp(dir, ".jumpkwapp-probe-*")
+	if err != nil {
+		return fmt.Errorf("temp dir %s is not writable: %w", dir, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// waitForKWin polls the bus until org.kde.KWin has an owner or timeout
+// passes. At session startup the bus can be up before KWin has registered.
+func waitForKWin(conn busConn, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		var hasOwner bool
+		err := conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, kwinService).Store(&hasOwner)
+		if err == nil && hasOwner {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			if err != nil {
+				return fmt.Errorf("wait for %s: %w", kwinService, err)
+			}
+			return fmt.Errorf("%s did not appear on the session bus within %s", kwinService, timeout)
+		}
+		time.Sleep(kwinPollInterval)
+	}
+}
+
+// scriptPluginName returns a name to load a KWin script under. KWin refuses
+// to load a second script under a name that is taken, and a stopped script
+// only goes away once KWin gets to it, so every load gets a new name.
+func scriptPluginName() string {
+	return fmt.Sprintf("jumpkwapp-%d-%d", os.Getpid(), time.Now().UnixNano())
+}
+
+// loadKWinScript loads scriptFile into KWin under pluginName, or under the
+// file's path when pluginName is empty.
+func loadKWinScript(conn *dbus.Conn, scriptFile, pluginName string) (dbus.ObjectPath, error) {
 	scripting := conn.Object(kwinService, dbus.ObjectPath(kwinScriptingPath))
-	call := scripting.Call(kwinScriptingIface+".loadScript", 0, scriptFile)
+	args := []any{scriptFile}
+	if pluginName != "" {
+		args = append(args, pluginName)
+	}
+	call := scripting.Call(kwinScriptingIface+".loadScript", 0, args...)
 	if call.Err != nil {
 		return "", fmt.Errorf("load KWin script: %w", call.Err)
 	}
@@ -267,30 +1605,72 @@ func renderScript(params scriptParams) (string, error) {
 		return "", err
 	}
 
-	data := struct {
-		ClassName          string
-		CaptionPattern     string
-		ClassRegex         string
-		Toggle             bool
-		CurrentDesktopOnly bool
-		DBusAddress        string
-	}{
-		ClassName:          escapeForJS(params.ClassName),
-		CaptionPattern:     escapeForJS(params.CaptionPattern),
-		ClassRegex:         escapeForJS(params.ClassRegex),
-		Toggle:             params.Toggle,
-		CurrentDesktopOnly: params.CurrentDesktopOnly,
-		DBusAddress:        escapeForJS(params.DBusAddress),
-	}
-
 	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
+	if err := tmpl.Execute(&buf, escapeScriptParams(params)); err != nil {
 		return "", err
 	}
 
 	return buf.String(), nil
 }
 
+// escapeScriptParams returns params with every string field escaped for use
+// inside a single-quoted JavaScript string literal.
+func escapeScriptParams(params scriptParams) scriptParams {
+	data := params
+	data.ClassNames = escapeListForJS(params.ClassNames)
+	data.CaptionPattern = escapeForJS(params.CaptionPattern)
+	data.CaptionFlags = escapeForJS(params.CaptionFlags)
+	data.TitleSubstring = escapeForJS(params.TitleSubstring)
+	data.CaptionExact = escapeForJS(params.CaptionExact)
+	data.Fuzzy = escapeForJS(params.Fuzzy)
+	data.ClassRegex = escapeForJS(params.ClassRegex)
+	data.DBusAddress = escapeForJS(params.DBusAddress)
+	data.WindowID = escapeForJS(params.WindowID)
+	data.DesktopFile = escapeForJS(params.DesktopFile)
+	data.ResourceName = escapeForJS(params.ResourceName)
+	data.Role = escapeForJS(params.Role)
+	data.State = escapeForJS(params.State)
+	data.Desktop = escapeForJS(params.Desktop)
+	data.Activity = escapeForJS(params.Activity)
+	data.Screen = escapeForJS(params.Screen)
+	data.SendToScreen = escapeForJS(params.SendToScreen)
+	data.SendToActivity = escapeForJS(params.SendToActivity)
+	data.LastCycled = escapeForJS(params.LastCycled)
+	data.PreviousWindow = escapeForJS(params.PreviousWindow)
+	data.Action = escapeForJS(params.Action)
+	data.SendToDesktop = escapeForJS(params.SendToDesktop)
+	data.Tile = escapeForJS(params.Tile)
+	data.ExcludeClasses = escapeListForJS(params.ExcludeClasses)
+	data.ExcludeCaptions = escapeListForJS(params.ExcludeCaptions)
+	data.ExcludeClassRegexes = escapeListForJS(params.ExcludeClassRegexes)
+	data.Prefer = escapeForJS(params.Prefer)
+	data.ListenerPath = escapeForJS(params.ListenerPath)
+	data.ListenerInterface = escapeForJS(params.ListenerInterface)
+	data.PluginName = escapeForJS(params.PluginName)
+	return data
+}
+
+// annotateScript prepends a comment block to script listing every template
+// parameter with the escaped value that was substituted, for --template-debug.
+func annotateScript(params scriptParams, script string) string {
+	data := reflect.ValueOf(escapeScriptParams(params))
+	fields := data.Type()
+
+	var b strings.Builder
+	b.WriteString("// jumpkwapp template parameters:\n")
+	for i := 0; i < fields.NumField(); i++ {
+		value := data.Field(i)
+		if value.Kind() == reflect.String {
+			fmt.Fprintf(&b, "//   %s = '%s'\n", fields.Field(i).Name, value.String())
+		} else {
+			fmt.Fprintf(&b, "//   %s = %v\n", fields.Field(i).Name, value.Interface())
+		}
+	}
+	b.WriteString("\n")
+	b.WriteString(script)
+	return b.String()
+}
+
 var jsReplacer = strings.NewReplacer(
 	"\\", "\\\\",
 	"'", "\\'",
@@ -299,10 +1679,202 @@ var jsReplacer = strings.NewReplacer(
 	"\t", "\\t",
 )
 
+// validRegexFlags checks that flags only holds JavaScript RegExp flags that
+// make sense for matching a caption, each at most once.
+func validRegexFlags(flags string) error {
+	for i, r := range flags {
+		if !strings.ContainsRune("imsu", r) {
+			return fmt.Errorf("unsupported flag %q (use i, m, s, or u)", r)
+		}
+		if strings.ContainsRune(flags[:i], r) {
+			return fmt.Errorf("flag %q given twice", r)
+		}
+	}
+	return nil
+}
+
+// globToRegex translates a shell-style wildcard pattern into an anchored
+// regular expression: * matches any run of characters, ? any single one, and
+// [...] a character class, negated with a leading !. Everything else is
+// literal, so a glob never needs regex escaping.
+func globToRegex(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// escapeListForJS escapes every element of values for a JS string literal.
+func escapeListForJS(values []string) []string {
+	escaped := make([]string, len(values))
+	for i, value := range values {
+		escaped[i] = escapeForJS(value)
+	}
+	return escaped
+}
+
 func escapeForJS(value string) string {
 	return jsReplacer.Replace(value)
 }
 
+// listenerNames derives the listener object path and interface from a dotted
+// D-Bus name prefix, e.g. "org.jumpkwapp" gives "/org/jumpkwapp/Listener" and
+// "org.jumpkwapp.Listener".
+func listenerNames(prefix string) (dbus.ObjectPath, string, error) {
+	if prefix == "" {
+		prefix = defaultDBusName
+	}
+	iface := prefix + ".Listener"
+	path := dbus.ObjectPath("/" + strings.ReplaceAll(prefix, ".", "/") + "/Listener")
+	if !validInterfaceName(iface) || !path.IsValid() {
+		return "", "", fmt.Errorf("invalid D-Bus name %q: use dot-separated elements of letters, digits, and underscores", prefix)
+	}
+	return path, iface, nil
+}
+
+func validInterfaceName(name string) bool {
+	if len(name) > 255 {
+		return false
+	}
+	elements := strings.Split(name, ".")
+	if len(elements) < 2 {
+		return false
+	}
+	for _, element := range elements {
+		if element == "" || (element[0] >= '0' && element[0] <= '9') {
+			return false
+		}
+		for _, r := range element {
+			if !(r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeatable flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// optionalString is a flag.Value for a flag whose value may be left out, as
+// in --flag or --flag=value. A separate "--flag value" is not supported, as
+// the flag package treats it as a boolean flag followed by an argument.
+type optionalString struct {
+	value string
+	set   bool
+}
+
+func (o *optionalString) String() string {
+	return o.value
+}
+
+func (o *optionalString) Set(value string) error {
+	o.set = true
+	if value != "true" {
+		o.value = value
+	}
+	return nil
+}
+
+func (o *optionalString) IsBoolFlag() bool {
+	return true
+}
+
+// flagGiven reports whether the boolean flag name is turned on in args,
+// without parsing anything else; args after "--" are not looked at.
+func flagGiven(args []string, name string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		flagName, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || flagName != name {
+			continue
+		}
+		if on, err := strconv.ParseBool(value); !hasValue || (err == nil && on) {
+			return true
+		}
+	}
+	return false
+}
+
+// commandArgs returns the command to launch given after a literal "--" in
+// args, the arguments jumpkwapp was started with; rest are the arguments the
+// flag package left over. A leftover argument without "--" before it is
+// rejected rather than run, as it is more likely a mistyped flag value.
+func commandArgs(args, rest []string) ([]string, error) {
+	if len(rest) == 0 {
+		return nil, nil
+	}
+	if i := len(args) - len(rest); i > 0 && args[i-1] == "--" {
+		return rest, nil
+	}
+	return nil, fmt.Errorf("unexpected argument %q; give the command to launch after --", rest[0])
+}
+
+// launchCommands combines the primary command and its fallbacks into the
+// ordered list tried by launchCommand, dropping empty entries.
+func launchCommands(primary string, fallbacks []string) []string {
+	var commands []string
+	for _, command := range append([]string{primary}, fallbacks...) {
+		if command = strings.TrimSpace(command); command != "" {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+// normalizeWindowID brings a window ID into the form KWin reports for
+// internalId, "{uuid}" in lower case, so IDs copied without the braces (or
+// from tools that print UUIDs in upper case) still match.
+func normalizeWindowID(id string) string {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if id == "" {
+		return ""
+	}
+	return "{" + strings.TrimSuffix(strings.TrimPrefix(id, "{"), "}") + "}"
+}
+
+func firstNonZero(values ...int) int {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
+
 func firstNonEmpty(values ...string) string {
 	for _, v := range values {
 		if v != "" {
@@ -313,14 +1885,24 @@ func firstNonEmpty(values ...string) string {
 }
 
 func getUniqueName(conn *dbus.Conn) (string, error) {
-	names := conn.Names()
+	return pickUniqueName(conn.Names())
+}
+
+// pickUniqueName returns the connection's unique name from the names it
+// owns. godbus lists the unique name first, followed by any well-known
+// names, so the first entry is used when it looks unique; the rest are only
+// scanned as a fallback. The KWin script must call back to exactly this name.
+func pickUniqueName(names []string) (string, error) {
 	if len(names) == 0 {
 		return "", errors.New("D-Bus connection does not have a unique name yet")
 	}
-	for _, name := range names {
+	if strings.HasPrefix(names[0], ":") {
+		return names[0], nil
+	}
+	for _, name := range names[1:] {
 		if strings.HasPrefix(name, ":") {
 			return name, nil
 		}
 	}
-	return names[0], nil
+	return "", fmt.Errorf("D-Bus connection has no unique name among %s", strings.Join(names, ", "))
 }
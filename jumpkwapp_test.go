@@ -0,0 +1,1297 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// parseArgs runs parseFlags on args with a fresh flag set, as if jumpkwapp
+// had been started with them.
+func parseArgs(t *testing.T, args ...string) (config, error) {
+	t.Helper()
+	oldArgs, oldFlags := os.Args, flag.CommandLine
+	t.Cleanup(func() {
+		os.Args, flag.CommandLine = oldArgs, oldFlags
+	})
+	os.Args = append([]string{"jumpkwapp"}, args...)
+	flag.CommandLine = flag.NewFlagSet("jumpkwapp", flag.ContinueOnError)
+	flag.CommandLine.SetOutput(io.Discard)
+	return parseFlags()
+}
+
+// testParams returns the parameters of a plain `jumpkwapp -f konsole` run
+// that reports to a listener.
+func testParams() scriptParams {
+	return scriptParams{
+		ClassNames:        []string{"konsole"},
+		CaptionFlags:      "i",
+		Prefer:            "newest",
+		CycleOrder:        "stacking",
+		SavedGeometry:     "{}",
+		ActivationTimes:   "{}",
+		OpenTimes:         "{}",
+		FlashOpacities:    "{}",
+		LaunchTimeout:     10000,
+		PluginName:        "jumpkwapp-test",
+		DBusAddress:       ":1.42",
+		ListenerPath:      "/org/jumpkwapp/Listener",
+		ListenerInterface: "org.jumpkwapp.Listener",
+	}
+}
+
+func render(t *testing.T, params scriptParams) string {
+	t.Helper()
+	script, err := renderScript(params)
+	if err != nil {
+		t.Fatalf("renderScript: %v", err)
+	}
+	return script
+}
+
+func TestParseFlagsTitle(t *testing.T) {
+	cfg, err := parseArgs(t, "--title", "Inbox")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if cfg.filterTitle != "Inbox" {
+		t.Errorf("filterTitle = %q, want %q", cfg.filterTitle, "Inbox")
+	}
+
+	_, err = parseArgs(t, "--title", "Inbox", "-fa", "Mail")
+	if err == nil || !strings.Contains(err.Error(), "--title") {
+		t.Errorf("--title with -fa: got error %v, want one naming --title", err)
+	}
+}
+
+func TestParseFlagsCaptionExact(t *testing.T) {
+	cfg, err := parseArgs(t, "--caption-exact", "Inbox")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if cfg.captionExact != "Inbox" {
+		t.Errorf("captionExact = %q, want %q", cfg.captionExact, "Inbox")
+	}
+
+	for _, other := range [][]string{{"--title", "Mail"}, {"-fa", "Mail"}} {
+		_, err = parseArgs(t, append([]string{"--caption-exact", "Inbox"}, other...)...)
+		if err == nil || !strings.Contains(err.Error(), "--caption-exact") {
+			t.Errorf("--caption-exact with %s: got error %v, want one naming --caption-exact", other[0], err)
+		}
+	}
+}
+
+func TestParseFlagsFuzzy(t *testing.T) {
+	cfg, err := parseArgs(t, "--fuzzy", " ffx ")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if cfg.fuzzy != "ffx" {
+		t.Errorf("fuzzy = %q, want %q", cfg.fuzzy, "ffx")
+	}
+
+	for _, other := range [][]string{{"-f", "firefox"}, {"-fr", "fire"}, {"--title", "Mozilla"}} {
+		_, err = parseArgs(t, append([]string{"--fuzzy", "ffx"}, other...)...)
+		if err == nil || !strings.Contains(err.Error(), "--fuzzy") {
+			t.Errorf("--fuzzy with %s: got error %v, want one naming --fuzzy", other[0], err)
+		}
+	}
+}
+
+func TestParseFlagsExclude(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "thunderbird", "--exclude-caption", "^Write:", "--exclude-class", "kate", "--exclude-caption", "Settings")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if want := []string{"kate"}; !reflect.DeepEqual(cfg.excludeClasses, want) {
+		t.Errorf("excludeClasses = %q, want %q", cfg.excludeClasses, want)
+	}
+	if want := []string{"^Write:", "Settings"}; !reflect.DeepEqual(cfg.excludeCaptions, want) {
+		t.Errorf("excludeCaptions = %q, want %q", cfg.excludeCaptions, want)
+	}
+}
+
+func TestParseFlagsClasses(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "kate", "--filter", "konsole", "-f", "gvim")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if want := []string{"kate", "konsole", "gvim"}; !reflect.DeepEqual(cfg.filterClasses, want) {
+		t.Errorf("filterClasses = %q, want %q", cfg.filterClasses, want)
+	}
+}
+
+func TestParseFlagsScreen(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "konsole", "--screen", " DP-1 ")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if cfg.screen != "DP-1" {
+		t.Errorf("screen = %q, want %q", cfg.screen, "DP-1")
+	}
+
+	_, err = parseArgs(t, "-f", "konsole", "--screen", "DP-1", "--current-screen")
+	if err == nil {
+		t.Error("--screen with --current-screen: want an error")
+	}
+}
+
+func TestParseFlagsActivity(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "konsole", "--activity", " 3f2c-work ")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if cfg.activity != "3f2c-work" {
+		t.Errorf("activity = %q, want %q", cfg.activity, "3f2c-work")
+	}
+
+	_, err = parseArgs(t, "-f", "konsole", "--activity", "3f2c-work", "--current-activity")
+	if err == nil {
+		t.Error("--activity with --current-activity: want an error")
+	}
+}
+
+func TestParseFlagsMinimized(t *testing.T) {
+	for _, other := range []string{"--skip-minimized", "--visible-only"} {
+		_, err := parseArgs(t, "-f", "konsole", "--minimized-only", other)
+		if err == nil {
+			t.Errorf("--minimized-only with %s: want an error", other)
+		}
+	}
+}
+
+func TestParseFlagsState(t *testing.T) {
+	tests := []struct {
+		state   string
+		want    string
+		wantErr bool
+	}{
+		{state: "Maximized", want: "maximized"},
+		{state: " !fullscreen ", want: "!fullscreen"},
+		{state: "normal", want: "normal"},
+		{state: "minimized", wantErr: true},
+		{state: "!", wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseArgs(t, "-f", "mpv", "--state", tt.state)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("--state %q: want an error", tt.state)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("--state %q: %v", tt.state, err)
+			continue
+		}
+		if cfg.state != tt.want {
+			t.Errorf("--state %q: state = %q, want %q", tt.state, cfg.state, tt.want)
+		}
+	}
+}
+
+func TestParseFlagsDesktop(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "konsole", "--desktop", " Two ")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if cfg.desktop != "Two" {
+		t.Errorf("desktop = %q, want %q", cfg.desktop, "Two")
+	}
+
+	_, err = parseArgs(t, "-f", "konsole", "--desktop", "2", "-d")
+	if err == nil {
+		t.Error("--desktop with -d: want an error")
+	}
+}
+
+func TestParseFlagsSummon(t *testing.T) {
+	tests := []struct {
+		args           []string
+		summon         bool
+		summonToScreen bool
+	}{
+		{args: nil},
+		{args: []string{"--summon"}, summon: true},
+		{args: []string{"--summon-to-screen"}, summon: true, summonToScreen: true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseArgs(t, append([]string{"-f", "konsole"}, tt.args...)...)
+		if err != nil {
+			t.Errorf("%v: %v", tt.args, err)
+			continue
+		}
+		if cfg.summon != tt.summon || cfg.summonToScreen != tt.summonToScreen {
+			t.Errorf("%v: summon = %v, summonToScreen = %v, want %v, %v", tt.args, cfg.summon, cfg.summonToScreen, tt.summon, tt.summonToScreen)
+		}
+	}
+}
+
+func TestParseFlagsNoDesktopSwitch(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "konsole", "--no-desktop-switch")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if !cfg.noDesktopSwitch {
+		t.Error("noDesktopSwitch = false, want true")
+	}
+	if _, err := parseArgs(t, "-f", "konsole", "--no-desktop-switch", "--summon"); err == nil {
+		t.Error("--no-desktop-switch with --summon: want an error")
+	}
+}
+
+func TestParseFlagsSendToActivity(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "konsole", "--send-to-activity", " Work ")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if cfg.sendToActivity != "Work" {
+		t.Errorf("sendToActivity = %q, want Work", cfg.sendToActivity)
+	}
+	if _, err := parseArgs(t, "-f", "konsole", "--send-to-activity", "Work", "--summon"); err == nil {
+		t.Error("--send-to-activity with --summon: want an error")
+	}
+}
+
+func TestParseFlagsRememberGeometry(t *testing.T) {
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{args: []string{"--remember-geometry", "--toggle"}},
+		{args: []string{"--remember-geometry", "--scratchpad"}},
+		{args: []string{"--remember-geometry"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseArgs(t, append([]string{"-f", "konsole"}, tt.args...)...)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%v: want an error", tt.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", tt.args, err)
+		} else if !cfg.rememberGeometry {
+			t.Errorf("%v: rememberGeometry = false, want true", tt.args)
+		}
+	}
+}
+
+func TestParseFlagsAction(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{args: nil, want: ""},
+		{args: []string{"--pin", "--close"}, wantErr: true},
+		{args: []string{"--warp-pointer", "--urgent"}, wantErr: true},
+		{args: []string{"--flash", "--close"}, wantErr: true},
+		{args: []string{"--expose", "--close"}, wantErr: true},
+		{args: []string{"--expose", "--raise-all"}, wantErr: true},
+		{args: []string{"--hide"}, want: "hide"},
+		{args: []string{"--hide", "--close"}, wantErr: true},
+		{args: []string{"--raise-only"}, want: "raise"},
+		{args: []string{"--raise-only", "--raise-all"}, wantErr: true},
+		{args: []string{"--urgent"}, want: "urgent"},
+		{args: []string{"--urgent", "--raise-only"}, wantErr: true},
+		{args: []string{"--solo", "--raise-only"}, wantErr: true},
+		{args: []string{"--close"}, want: "close"},
+		{args: []string{"--close-all"}, want: "close-all"},
+		{args: []string{"--close", "--close-all"}, wantErr: true},
+		{args: []string{"--maximize"}, want: ""},
+		{args: []string{"--maximize", "--close"}, wantErr: true},
+		{args: []string{"--fullscreen", "--urgent"}, wantErr: true},
+		{args: []string{"--keep-above", "--close-all"}, wantErr: true},
+		{args: []string{"--shade", "--urgent"}, wantErr: true},
+		{args: []string{"--shade", "--toggle"}, wantErr: true},
+		{args: []string{"--swap"}, want: "swap"},
+		{args: []string{"--swap", "--hide"}, wantErr: true},
+		{args: []string{"--toggle-back", "--toggle"}, wantErr: true},
+		{args: []string{"--toggle-back", "--close"}, wantErr: true},
+		{args: []string{"--pick", "--close"}, wantErr: true},
+		{args: []string{"--pick", "--expose"}, wantErr: true},
+		{args: []string{"--pick", "--index", "2"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseArgs(t, append([]string{"-f", "konsole"}, tt.args...)...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: error = %v, want error %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.action != tt.want {
+			t.Errorf("%v: action = %q, want %q", tt.args, cfg.action, tt.want)
+		}
+	}
+}
+
+func TestParseFlagsSendToDesktop(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "konsole", "--send-to-desktop", " 2 ", "--follow")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if cfg.action != "send-to-desktop" || cfg.sendToDesktop != "2" || !cfg.follow {
+		t.Errorf("action = %q, sendToDesktop = %q, follow = %v", cfg.action, cfg.sendToDesktop, cfg.follow)
+	}
+	if _, err := parseArgs(t, "-f", "konsole", "--follow"); err == nil {
+		t.Error("--follow without --send-to-desktop: want an error")
+	}
+	if _, err := parseArgs(t, "-f", "konsole", "--send-to-desktop", "2", "--close"); err == nil {
+		t.Error("--send-to-desktop with --close: want an error")
+	}
+}
+
+func TestParseFlagsSendToScreen(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{args: []string{"--send-to-screen", " HDMI-1 "}, want: "HDMI-1"},
+		{args: []string{"--to-current-screen"}},
+		{args: []string{"--send-to-screen", "1", "--to-current-screen"}, wantErr: true},
+		{args: []string{"--send-to-screen", "1", "--summon-to-screen"}, wantErr: true},
+		{args: []string{"--to-current-screen", "--summon-to-screen"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseArgs(t, append([]string{"-f", "konsole"}, tt.args...)...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: error = %v, want error %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.sendToScreen != tt.want {
+			t.Errorf("%v: sendToScreen = %q, want %q", tt.args, cfg.sendToScreen, tt.want)
+		}
+	}
+}
+
+func TestParseFlagsOpacity(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    float64
+		wantErr bool
+	}{
+		{args: nil, want: 0},
+		{args: []string{"--opacity", "0.85"}, want: 0.85},
+		{args: []string{"--opacity", "1"}, want: 1},
+		{args: []string{"--opacity", "1.5"}, wantErr: true},
+		{args: []string{"--opacity", "-0.5"}, wantErr: true},
+		{args: []string{"--opacity", "NaN"}, wantErr: true},
+		{args: []string{"--opacity", "inf"}, wantErr: true},
+		{args: []string{"--opacity", "0.5", "--raise-only"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseArgs(t, append([]string{"-f", "konsole"}, tt.args...)...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: error = %v, want error %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.opacity != tt.want {
+			t.Errorf("%v: opacity = %g, want %g", tt.args, cfg.opacity, tt.want)
+		}
+	}
+}
+
+func TestParseFlagsPlace(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "konsole", "--place", "center")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if want := (&placement{X: position{50, true}, Y: position{50, true}}); !reflect.DeepEqual(cfg.place, want) {
+		t.Errorf("place = %+v, want %+v", cfg.place, want)
+	}
+	for _, args := range [][]string{
+		{"--place", "middle"},
+		{"--place", "center", "--maximize"},
+		{"--place", "center", "--tile", "left"},
+		{"--place", "center", "--close"},
+		{"--geometry", "80%"},
+		{"--geometry", "80%x50%", "--maximize"},
+		{"--geometry", "80%x50%", "--urgent"},
+	} {
+		if _, err := parseArgs(t, append([]string{"-f", "konsole"}, args...)...); err == nil {
+			t.Errorf("%v: got no error", args)
+		}
+	}
+}
+
+func TestParseFlagsRaiseAll(t *testing.T) {
+	for _, args := range [][]string{nil, {"--raise-all"}, {"--all"}} {
+		cfg, err := parseArgs(t, append([]string{"-f", "konsole"}, args...)...)
+		if err != nil {
+			t.Errorf("%v: %v", args, err)
+			continue
+		}
+		if want := args != nil; cfg.raiseAll != want {
+			t.Errorf("%v: raiseAll = %v, want %v", args, cfg.raiseAll, want)
+		}
+	}
+	if _, err := parseArgs(t, "-f", "konsole", "--all", "--close"); err == nil {
+		t.Error("--all with --close: want an error")
+	}
+}
+
+func TestParseFlagsScratchpad(t *testing.T) {
+	defaultSize, _ := parseSize(defaultScratchpadSize)
+	defaultPlace, _ := parsePlacement(defaultScratchpadPlace)
+	customSize, _ := parseSize("50%x50%")
+	tests := []struct {
+		name      string
+		args      []string
+		wantSize  *size
+		wantPlace *placement
+		wantErr   bool
+	}{
+		{name: "defaults", args: nil, wantSize: defaultSize, wantPlace: defaultPlace},
+		{name: "own geometry", args: []string{"--geometry", "50%x50%"}, wantSize: customSize},
+		{name: "maximized", args: []string{"--maximize"}},
+		{name: "tiled", args: []string{"--tile", "left"}},
+		{name: "with shade", args: []string{"--shade"}, wantErr: true},
+		{name: "with an action", args: []string{"--close"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := parseArgs(t, append([]string{"-f", "yakuake", "--scratchpad"}, tt.args...)...)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFlags: %v", err)
+			}
+			if !cfg.summon || !cfg.toggle {
+				t.Errorf("summon = %v, toggle = %v, want both set", cfg.summon, cfg.toggle)
+			}
+			if !reflect.DeepEqual(cfg.size, tt.wantSize) || !reflect.DeepEqual(cfg.place, tt.wantPlace) {
+				t.Errorf("size = %+v, place = %+v, want %+v, %+v", cfg.size, cfg.place, tt.wantSize, tt.wantPlace)
+			}
+		})
+	}
+}
+
+func TestParseFlagsOwnDesktop(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		// Without a name the desktop is named once groups are expanded.
+		{args: []string{"-f", "konsole", "--own-desktop"}, want: ""},
+		{args: []string{"-f", "konsole", "--own-desktop=Terminals"}, want: "Terminals"},
+		{args: []string{"-fa", "Inbox", "--own-desktop=Mail"}, want: "Mail"},
+		{args: []string{"-f", "konsole", "--own-desktop", "--follow"}, want: ""},
+		{args: []string{"-f", "konsole", "--own-desktop", "--close"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseArgs(t, tt.args...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: error = %v, want error %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if cfg.action != "own-desktop" || cfg.sendToDesktop != tt.want {
+			t.Errorf("%v: action = %q, desktop = %q, want own-desktop, %q", tt.args, cfg.action, cfg.sendToDesktop, tt.want)
+		}
+	}
+}
+
+func TestParseFlagsCycleMinimized(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    bool
+		wantErr bool
+	}{
+		{args: nil, want: false},
+		{args: []string{"--cycle-include-minimized"}, want: false},
+		{args: []string{"--cycle-skip-minimized"}, want: true},
+		{args: []string{"--cycle-skip-minimized", "--cycle-include-minimized"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseArgs(t, append([]string{"-f", "konsole"}, tt.args...)...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: error = %v, want error %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.cycleSkipMinimized != tt.want {
+			t.Errorf("%v: cycleSkipMinimized = %v, want %v", tt.args, cfg.cycleSkipMinimized, tt.want)
+		}
+	}
+}
+
+func TestParseFlagsReverse(t *testing.T) {
+	for _, args := range [][]string{{"--reverse"}, {"-R"}} {
+		cfg, err := parseArgs(t, append([]string{"-f", "konsole"}, args...)...)
+		if err != nil {
+			t.Errorf("%v: %v", args, err)
+		} else if !cfg.reverse {
+			t.Errorf("%v: reverse = false, want true", args)
+		}
+	}
+}
+
+func TestParseFlagsPersistentCycle(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "konsole", "--persistent-cycle")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if !cfg.persistentCycle {
+		t.Error("persistentCycle = false, want true")
+	}
+	if _, err := parseArgs(t, "-f", "konsole", "--persistent-cycle", "--mru"); err == nil {
+		t.Error("--persistent-cycle with --mru: want an error")
+	}
+}
+
+func TestParseFlagsIndex(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "konsole", "--index", "-2")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if cfg.index != -2 {
+		t.Errorf("index = %d, want -2", cfg.index)
+	}
+	for _, args := range [][]string{{"--persistent-cycle"}, {"--raise-all"}} {
+		if _, err := parseArgs(t, append([]string{"-f", "konsole", "--index", "2"}, args...)...); err == nil {
+			t.Errorf("--index with %v: want an error", args)
+		}
+	}
+}
+
+func TestParseFlagsPreferVisible(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "konsole", "--prefer-visible")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if !cfg.preferVisible {
+		t.Error("preferVisible = false, want true")
+	}
+}
+
+func TestParseFlagsMenu(t *testing.T) {
+	cfg, err := parseArgs(t, "--menu", "rofi")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if cfg.menu != "rofi" || !cfg.pick {
+		t.Errorf("menu = %q, pick = %v, want rofi, true", cfg.menu, cfg.pick)
+	}
+	if _, err := parseArgs(t, "--menu", "rofi", "--expose"); err == nil {
+		t.Error("--menu with --expose: want an error")
+	}
+}
+
+func TestParseFlagsCycleOrder(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{args: nil, want: "stacking"},
+		{args: []string{"--cycle-order", " Caption "}, want: "caption"},
+		{args: []string{"--mru"}, want: "mru"},
+		{args: []string{"--mru", "--cycle-order", "mru"}, want: "mru"},
+		{args: []string{"--mru", "--cycle-order", "caption"}, wantErr: true},
+		{args: []string{"--cycle-order", "random"}, wantErr: true},
+		{args: []string{"--cycle-order", "caption", "--persistent-cycle"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseArgs(t, append([]string{"-f", "konsole"}, tt.args...)...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: error = %v, want error %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.cycleOrder != tt.want {
+			t.Errorf("%v: cycleOrder = %q, want %q", tt.args, cfg.cycleOrder, tt.want)
+		}
+	}
+}
+
+func TestParseFlagsFocusAfterLaunch(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    time.Duration
+		wantErr bool
+	}{
+		{args: nil, want: 0},
+		{args: []string{"--focus-after-launch"}, want: defaultFocusAfterLaunch},
+		{args: []string{"--focus-after-launch=3s"}, want: 3 * time.Second},
+		{args: []string{"--focus-after-launch=0s"}, wantErr: true},
+		{args: []string{"--focus-after-launch=soon"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseArgs(t, append([]string{"-f", "konsole", "-c", "konsole"}, tt.args...)...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: error = %v, want error %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.focusAfterLaunch != tt.want {
+			t.Errorf("%v: focusAfterLaunch = %v, want %v", tt.args, cfg.focusAfterLaunch, tt.want)
+		}
+	}
+}
+
+func TestParseFlagsLaunchDesktop(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "konsole", "--launch-desktop", " org.kde.konsole.desktop ")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if cfg.launchDesktop != "org.kde.konsole.desktop" || !cfg.canLaunch() {
+		t.Errorf("launchDesktop = %q, canLaunch = %v", cfg.launchDesktop, cfg.canLaunch())
+	}
+	if _, err := parseArgs(t, "-f", "konsole", "--launch-desktop", "konsole", "-c", "konsole"); err == nil {
+		t.Error("--launch-desktop with -c: want an error")
+	}
+}
+
+func TestParseFlagsArgv(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{args: []string{"-f", "foo"}, want: nil},
+		{args: []string{"-f", "foo", "--", "/usr/bin/foo", "--flag", "arg with spaces"}, want: []string{"/usr/bin/foo", "--flag", "arg with spaces"}},
+		{args: []string{"-f", "foo", "-c", "foo", "--", "foo"}, wantErr: true},
+		{args: []string{"-f", "foo", "--launch-desktop", "foo", "--", "foo"}, wantErr: true},
+		{args: []string{"-f", "foo", "konsole"}, wantErr: true},
+		{args: []string{"-f", "foo", "-c", "konsole", "dolphin"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseArgs(t, tt.args...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error = %v, want error %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(cfg.argv) != len(tt.want) || len(tt.want) > 0 && !reflect.DeepEqual(cfg.argv, tt.want) {
+			t.Errorf("%q: argv = %q, want %q", tt.args, cfg.argv, tt.want)
+		}
+		if cfg.canLaunch() != (len(tt.want) > 0) {
+			t.Errorf("%q: canLaunch = %v", tt.args, cfg.canLaunch())
+		}
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	tests := []struct {
+		args    []string
+		rest    []string
+		want    []string
+		wantErr bool
+	}{
+		{args: []string{"-f", "foo"}, rest: nil, want: nil},
+		{args: []string{"-f", "foo", "--"}, rest: []string{}, want: nil},
+		{args: []string{"-f", "foo", "--", "bar", "-x"}, rest: []string{"bar", "-x"}, want: []string{"bar", "-x"}},
+		{args: []string{"-f", "foo", "--", "--"}, rest: []string{"--"}, want: []string{"--"}},
+		{args: []string{"-f", "foo", "bar"}, rest: []string{"bar"}, wantErr: true},
+		{args: []string{"bar", "--", "baz"}, rest: []string{"bar", "--", "baz"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := commandArgs(tt.args, tt.rest)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("commandArgs(%q, %q) error = %v, want error %v", tt.args, tt.rest, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("commandArgs(%q, %q) = %q, want %q", tt.args, tt.rest, got, tt.want)
+		}
+	}
+}
+
+func TestLaunchArgv(t *testing.T) {
+	// The argument holds shell syntax that must reach the program untouched.
+	path := filepath.Join(t.TempDir(), "out $HOME; *")
+	if err := launch(nil, config{argv: []string{"touch", path}, detachIO: true}); err != nil {
+		t.Fatalf("launch: %v", err)
+	}
+	waitForFile(t, path)
+}
+
+func TestParseFlagsTile(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{args: nil, want: ""},
+		{args: []string{"--tile", "left"}, want: "left"},
+		{args: []string{"--tile", " TopRight "}, want: "topright"},
+		{args: []string{"--tile", "middle"}, wantErr: true},
+		{args: []string{"--tile", "left", "--maximize"}, wantErr: true},
+		{args: []string{"--tile", "left", "--close"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseArgs(t, append([]string{"-f", "konsole"}, tt.args...)...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: error = %v, want error %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.tile != tt.want {
+			t.Errorf("%v: tile = %q, want %q", tt.args, cfg.tile, tt.want)
+		}
+	}
+}
+
+func TestGlobToRegex(t *testing.T) {
+	tests := []struct {
+		glob    string
+		want    string
+		match   []string
+		noMatch []string
+	}{
+		{glob: "jetbrains-*", want: "^jetbrains-.*$", match: []string{"jetbrains-idea", "jetbrains-"}, noMatch: []string{"my-jetbrains-idea"}},
+		{glob: "fire?ox", want: "^fire.ox$", match: []string{"firefox"}, noMatch: []string{"fireox", "firefoxes"}},
+		{glob: "org.kde.*", want: `^org\.kde\..*$`, match: []string{"org.kde.konsole"}, noMatch: []string{"orgxkde.konsole"}},
+		{glob: "[kK]onsole", want: "^[kK]onsole$", match: []string{"konsole", "Konsole"}, noMatch: []string{"xonsole"}},
+		{glob: "[!a-m]ate", want: "^[^a-m]ate$", match: []string{"pate"}, noMatch: []string{"kate"}},
+		{glob: "[abc", want: `^\[abc$`, match: []string{"[abc"}, noMatch: []string{"a"}},
+		{glob: "c++(beta)", want: `^c\+\+\(beta\)$`, match: []string{"c++(beta)"}, noMatch: []string{"cc(beta)"}},
+	}
+	for _, tt := range tests {
+		got := globToRegex(tt.glob)
+		if got != tt.want {
+			t.Errorf("globToRegex(%q) = %q, want %q", tt.glob, got, tt.want)
+			continue
+		}
+		re := regexp.MustCompile(got)
+		for _, s := range tt.match {
+			if !re.MatchString(s) {
+				t.Errorf("glob %q does not match %q", tt.glob, s)
+			}
+		}
+		for _, s := range tt.noMatch {
+			if re.MatchString(s) {
+				t.Errorf("glob %q matches %q", tt.glob, s)
+			}
+		}
+	}
+}
+
+func TestParseFlagsGlob(t *testing.T) {
+	cfg, err := parseArgs(t, "--glob", " jetbrains-* ")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if want := "^jetbrains-.*$"; cfg.filterRegex != want {
+		t.Errorf("filterRegex = %q, want %q", cfg.filterRegex, want)
+	}
+
+	_, err = parseArgs(t, "--glob", "jetbrains-*", "-fr", "^jetbrains")
+	if err == nil || !strings.Contains(err.Error(), "--glob") {
+		t.Errorf("--glob with -fr: got error %v, want one naming --glob", err)
+	}
+}
+
+func TestValidRegexFlags(t *testing.T) {
+	tests := []struct {
+		flags   string
+		wantErr bool
+	}{
+		{flags: ""},
+		{flags: "i"},
+		{flags: "imsu"},
+		{flags: "g", wantErr: true},
+		{flags: "y", wantErr: true},
+		{flags: "ii", wantErr: true},
+		{flags: "I", wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := validRegexFlags(tt.flags); (err != nil) != tt.wantErr {
+			t.Errorf("validRegexFlags(%q) = %v, want error %v", tt.flags, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseFlagsOnlyTaskbar(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "konsole", "--only-taskbar")
+	if err != nil {
+		t.Fatalf("the deprecated --only-taskbar flag is no longer accepted: %v", err)
+	}
+	if !cfg.onlyTaskbar || cfg.includeSkipTaskbar {
+		t.Errorf("onlyTaskbar = %v, includeSkipTaskbar = %v, want true, false", cfg.onlyTaskbar, cfg.includeSkipTaskbar)
+	}
+}
+
+func TestParseFlagsPWA(t *testing.T) {
+	cfg, err := parseArgs(t, "--pwa", "Element", "--match", "!minimized")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if want := "(" + pwaMatch("Element") + ") && (!minimized)"; cfg.match != want {
+		t.Errorf("match = %q, want %q", cfg.match, want)
+	}
+}
+
+func TestParseFlagsWindowID(t *testing.T) {
+	cfg, err := parseArgs(t, "--window-id", " {0a1b2c3d-0000-4000-8000-000000000001} ")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if want := "{0a1b2c3d-0000-4000-8000-000000000001}"; cfg.windowID != want {
+		t.Errorf("windowID = %q, want %q", cfg.windowID, want)
+	}
+}
+
+func TestNormalizeWindowID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{id: "", want: ""},
+		{id: "   ", want: ""},
+		{id: "{0a1b2c3d-0000-4000-8000-000000000001}", want: "{0a1b2c3d-0000-4000-8000-000000000001}"},
+		{id: "0a1b2c3d-0000-4000-8000-000000000001", want: "{0a1b2c3d-0000-4000-8000-000000000001}"},
+		{id: " {0A1B2C3D-0000-4000-8000-000000000001} ", want: "{0a1b2c3d-0000-4000-8000-000000000001}"},
+		{id: "{0a1b2c3d-0000-4000-8000-000000000001", want: "{0a1b2c3d-0000-4000-8000-000000000001}"},
+	}
+	for _, tt := range tests {
+		if got := normalizeWindowID(tt.id); got != tt.want {
+			t.Errorf("normalizeWindowID(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestListenerNames(t *testing.T) {
+	tests := []struct {
+		prefix    string
+		wantPath  dbus.ObjectPath
+		wantIface string
+		wantErr   bool
+	}{
+		{prefix: "", wantPath: "/org/jumpkwapp/Listener", wantIface: "org.jumpkwapp.Listener"},
+		{prefix: "org.example.hotkeys", wantPath: "/org/example/hotkeys/Listener", wantIface: "org.example.hotkeys.Listener"},
+		{prefix: "org..example", wantErr: true},
+		{prefix: "org.9lives", wantErr: true},
+		{prefix: "org.example-hotkeys", wantErr: true},
+	}
+	for _, tt := range tests {
+		path, iface, err := listenerNames(tt.prefix)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("listenerNames(%q) error = %v, want error %v", tt.prefix, err, tt.wantErr)
+			continue
+		}
+		if path != tt.wantPath || iface != tt.wantIface {
+			t.Errorf("listenerNames(%q) = %q, %q, want %q, %q", tt.prefix, path, iface, tt.wantPath, tt.wantIface)
+		}
+	}
+}
+
+func TestWriteTempScript(t *testing.T) {
+	dir := t.TempDir()
+	path, err := writeTempScript(dir, "print('hi');")
+	if err != nil {
+		t.Fatalf("writeTempScript: %v", err)
+	}
+	if filepath.Dir(path) != dir {
+		t.Errorf("script written to %s, want it in %s", path, dir)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "print('hi');" {
+		t.Errorf("script content = %q", data)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("%d files left in %s after removing the script", len(entries), dir)
+	}
+
+	if _, err := writeTempScript(filepath.Join(dir, "missing"), "x"); err == nil {
+		t.Error("writeTempScript into a missing directory: got no error")
+	}
+}
+
+func TestCheckWritableDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := checkWritableDir(dir); err != nil {
+		t.Errorf("checkWritableDir(%s): %v", dir, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("probe file left in %s", dir)
+	}
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkWritableDir(file); err == nil {
+		t.Error("checkWritableDir on a file: got no error")
+	}
+	if err := checkWritableDir(filepath.Join(dir, "missing")); err == nil {
+		t.Error("checkWritableDir on a missing directory: got no error")
+	}
+}
+
+func TestFilterWarnings(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config
+		want int
+	}{
+		{name: "class only", cfg: config{filterClasses: []string{"konsole"}}, want: 0},
+		{name: "caption only", cfg: config{filterTitle: "Inbox"}, want: 0},
+		{name: "class and regex", cfg: config{filterClasses: []string{"konsole"}, filterRegex: "^kate$"}, want: 1},
+		{name: "class and caption", cfg: config{filterClasses: []string{"konsole"}, filterAlt: "vim"}, want: 1},
+		{name: "caption first", cfg: config{filterClasses: []string{"konsole"}, filterAlt: "vim", captionFirst: true}, want: 0},
+		{name: "regex and title", cfg: config{filterRegex: "^kate$", filterTitle: "notes"}, want: 1},
+		{name: "all three", cfg: config{filterClasses: []string{"konsole"}, filterRegex: "^kate$", filterAlt: "vim"}, want: 2},
+		{name: "all filters", cfg: config{filterClasses: []string{"konsole"}, filterRegex: "^kate$", filterAlt: "vim", allFilters: true}, want: 0},
+		{name: "only taskbar", cfg: config{filterClasses: []string{"konsole"}, onlyTaskbar: true}, want: 1},
+		{name: "only taskbar with all filters", cfg: config{filterClasses: []string{"konsole"}, allFilters: true, onlyTaskbar: true}, want: 1},
+	}
+	for _, tt := range tests {
+		if got := filterWarnings(tt.cfg); len(got) != tt.want {
+			t.Errorf("%s: filterWarnings = %q, want %d warnings", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseDecision(t *testing.T) {
+	tests := []struct {
+		value string
+		want  launchDecision
+	}{
+		{"true", decisionLaunch},
+		{"TRUE", decisionLaunch},
+		{"false", decisionMatched},
+		{"error", decisionError},
+		{"", decisionError},
+	}
+	for _, tt := range tests {
+		if got := parseDecision(tt.value); got != tt.want {
+			t.Errorf("parseDecision(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseFlagsActivateRetries(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{value: "0"},
+		{value: "20"},
+		{value: "21", wantErr: true},
+		{value: "-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		_, err := parseArgs(t, "-f", "konsole", "--activate-retries", tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("--activate-retries %s: error = %v, want error %v", tt.value, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseFlagsPrefer(t *testing.T) {
+	tests := []struct {
+		args       []string
+		want       string
+		wantOpened bool
+		wantErr    bool
+	}{
+		{args: nil, want: "newest"},
+		{args: []string{"--prefer", "Oldest"}, want: "oldest"},
+		{args: []string{"--prefer", "first"}, wantErr: true},
+		{args: []string{"--oldest"}, want: "oldest", wantOpened: true},
+		{args: []string{"--prefer", "oldest", "--newest"}, want: "newest", wantOpened: true},
+		{args: []string{"--newest", "--oldest"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseArgs(t, append([]string{"-f", "konsole"}, tt.args...)...)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%v: got no error", tt.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", tt.args, err)
+			continue
+		}
+		if cfg.prefer != tt.want {
+			t.Errorf("%v: prefer = %q, want %q", tt.args, cfg.prefer, tt.want)
+		}
+		if cfg.preferOpened != tt.wantOpened {
+			t.Errorf("%v: preferOpened = %v, want %v", tt.args, cfg.preferOpened, tt.wantOpened)
+		}
+	}
+}
+
+func TestLaunchCommandDetachIO(t *testing.T) {
+	for _, detach := range []bool{false, true} {
+		dir := t.TempDir()
+		out, err := os.Create(filepath.Join(dir, "stdout"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := filepath.Join(dir, "done")
+		oldStdout := os.Stdout
+		os.Stdout = out
+		err = launchCommand([]string{"echo hello; touch " + done}, detach)
+		os.Stdout = oldStdout
+		if err != nil {
+			t.Fatalf("launchCommand: %v", err)
+		}
+		waitForFile(t, done)
+		out.Close()
+
+		data, err := os.ReadFile(out.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(data), "hello"); got == detach {
+			t.Errorf("detachIO=%v: command output %q", detach, data)
+		}
+	}
+}
+
+// waitForFile waits for a command started in the background to create path.
+func waitForFile(t *testing.T, path string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s was never created", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAnnotateScript(t *testing.T) {
+	params := testParams()
+	params.TitleSubstring = "it's"
+	annotated := annotateScript(params, "script")
+	for _, want := range []string{
+		"// jumpkwapp template parameters:\n",
+		`//   TitleSubstring = 'it\'s'`,
+		"//   RaiseAll = false\n",
+		"\nscript",
+	} {
+		if !strings.Contains(annotated, want) {
+			t.Errorf("annotated script lacks %q", want)
+		}
+	}
+}
+
+func TestPickUniqueName(t *testing.T) {
+	tests := []struct {
+		names   []string
+		want    string
+		wantErr bool
+	}{
+		{names: []string{":1.42"}, want: ":1.42"},
+		{names: []string{":1.42", "org.example.Service"}, want: ":1.42"},
+		{names: []string{"org.example.Service", ":1.42"}, want: ":1.42"},
+		{names: []string{"org.example.A", "org.example.B", ":1.7", ":1.8"}, want: ":1.7"},
+		{names: []string{"org.example.Service"}, wantErr: true},
+		{names: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := pickUniqueName(tt.names)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("pickUniqueName(%v) = %q, want an error", tt.names, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("pickUniqueName(%v) = %q, %v, want %q", tt.names, got, err, tt.want)
+		}
+	}
+}
+
+func TestLaunchCommand(t *testing.T) {
+	const missing = "jumpkwapp-test-no-such-program"
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first"), filepath.Join(dir, "second")
+
+	if err := launchCommand([]string{"touch " + first, "touch " + second}, true); err != nil {
+		t.Fatalf("launchCommand: %v", err)
+	}
+	waitForFile(t, first)
+	if err := launchCommand([]string{missing + " --new-window", "touch " + second}, true); err != nil {
+		t.Fatalf("launchCommand with a missing first program: %v", err)
+	}
+	waitForFile(t, second)
+
+	commands := []string{missing, missing + "-either"}
+	err := launchCommand(commands, true)
+	if err == nil {
+		t.Fatalf("launchCommand(%v): got no error", commands)
+	}
+	for _, command := range commands {
+		if !strings.Contains(err.Error(), command) {
+			t.Errorf("error %q does not name %s", err, command)
+		}
+	}
+}
+
+func TestCommandProgram(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{command: "firefox --new-window", want: "firefox"},
+		{command: "  konsole  ", want: "konsole"},
+		{command: "/usr/bin/kate", want: "/usr/bin/kate"},
+		{command: "exec firefox", want: ""},
+		{command: "GDK_BACKEND=x11 firefox", want: ""},
+		{command: "'my app'", want: ""},
+		{command: "$BROWSER", want: ""},
+		{command: "(cd ~ && konsole)", want: ""},
+		{command: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := commandProgram(tt.command); got != tt.want {
+			t.Errorf("commandProgram(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestParseFlagsCommandFallback(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "firefox", "-c", "firefox", "--command-fallback", "librewolf", "--command-fallback", " ", "--command-fallback", "chromium")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if want := []string{"firefox", "librewolf", "chromium"}; !reflect.DeepEqual(cfg.commands, want) {
+		t.Errorf("commands = %q, want %q", cfg.commands, want)
+	}
+}
+
+func TestCountExitStatus(t *testing.T) {
+	tests := []struct{ matched, want int }{
+		{0, 0},
+		{1, 1},
+		{3, 3},
+		{maxCountExitStatus, maxCountExitStatus},
+		{maxCountExitStatus + 1, maxCountExitStatus},
+		{1000, maxCountExitStatus},
+		{-1, 0},
+	}
+	for _, tt := range tests {
+		if got := countExitStatus(tt.matched); got != tt.want {
+			t.Errorf("countExitStatus(%d) = %d, want %d", tt.matched, got, tt.want)
+		}
+	}
+}
+
+func TestParseFlagsExitCountOnError(t *testing.T) {
+	tests := []struct {
+		args          []string
+		wantExitCount bool
+		wantSyntax    bool
+	}{
+		{args: []string{"--exit-count", "--prefer", "first"}, wantExitCount: true},
+		{args: []string{"--exit-count", "-f", "foo", "stray"}, wantExitCount: true},
+		{args: []string{"--exit-count", "--no-such-flag"}, wantExitCount: true, wantSyntax: true},
+		{args: []string{"--no-such-flag", "--exit-count"}, wantExitCount: true, wantSyntax: true},
+		{args: []string{"--no-such-flag", "-exit-count=true"}, wantExitCount: true, wantSyntax: true},
+		{args: []string{"--no-such-flag", "--exit-count=false"}, wantSyntax: true},
+		{args: []string{"--no-such-flag", "--", "--exit-count"}, wantSyntax: true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseArgs(t, tt.args...)
+		if err == nil {
+			t.Errorf("%q: got no error", tt.args)
+			continue
+		}
+		if cfg.exitCount != tt.wantExitCount {
+			t.Errorf("%q: exitCount = %v, want %v (error %v)", tt.args, cfg.exitCount, tt.wantExitCount, err)
+		}
+		if got := errors.Is(err, errFlagSyntax); got != tt.wantSyntax {
+			t.Errorf("%q: error %v is errFlagSyntax = %v, want %v", tt.args, err, got, tt.wantSyntax)
+		}
+	}
+
+	if _, err := parseArgs(t, "-h"); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("-h: error = %v, want flag.ErrHelp", err)
+	}
+}
+
+func TestParseFlagsPID(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{args: []string{"--pid", "4242"}, want: 4242},
+		{args: []string{"-p", "4242"}, want: 4242},
+		{args: []string{"--pid", "-1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseArgs(t, tt.args...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: error = %v, want error %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.pid != tt.want {
+			t.Errorf("%v: pid = %d, want %d", tt.args, cfg.pid, tt.want)
+		}
+	}
+}
+
+func TestParseFlagsOpenedWithin(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "konsole", "--opened-within", "30s")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if cfg.openedWithin != 30*time.Second {
+		t.Errorf("openedWithin = %s, want 30s", cfg.openedWithin)
+	}
+	if _, err := parseArgs(t, "-f", "konsole", "--opened-within", "-1s"); err == nil {
+		t.Error("negative --opened-within: got no error")
+	}
+}
+
+func TestParseFlagsDesktopFile(t *testing.T) {
+	cfg, err := parseArgs(t, "--desktop-file", " org.mozilla.firefox.desktop ")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if want := "org.mozilla.firefox"; cfg.desktopFile != want {
+		t.Errorf("desktopFile = %q, want %q", cfg.desktopFile, want)
+	}
+}
+
+func TestParseFlagsIgnoreCase(t *testing.T) {
+	for _, flag := range []string{"-i", "--ignore-case"} {
+		cfg, err := parseArgs(t, "-f", "konsole", flag)
+		if err != nil {
+			t.Fatalf("parseFlags: %v", err)
+		}
+		if !cfg.ignoreCase {
+			t.Errorf("%s: ignoreCase not set", flag)
+		}
+	}
+}
+
+func TestParseFlagsAllFilters(t *testing.T) {
+	cfg, err := parseArgs(t, "-f", "firefox", "-fa", "YouTube", "--all-filters")
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if !cfg.allFilters {
+		t.Error("allFilters not set")
+	}
+}
@@ -0,0 +1,26 @@
+/**
+ * Report every window known to KWin back to the jumpkwapp listener, unfiltered.
+ * The list is sent as a single JSON string so it survives the D-Bus type conversion intact.
+ * @param {string} dbusAddr D-Bus address of the listener
+ * @param {string} listenerPath Object path the listener is exported on
+ * @param {string} listenerInterface Interface name the listener is exported with
+ */
+function dumpWindows(dbusAddr, listenerPath, listenerInterface) {
+    var clients = workspace.windowList();
+    var windows = [];
+
+    for (var i = 0; i < clients.length; i++) {
+        var client = clients[i];
+        windows.push({
+            resourceClass: String(client.resourceClass),
+            resourceName: String(client.resourceName),
+            caption: String(client.caption),
+            internalId: String(client.internalId),
+            pid: client.pid
+        });
+    }
+
+    callDBus(dbusAddr, listenerPath, listenerInterface, 'ReportWindows', JSON.stringify(windows));
+}
+
+dumpWindows('{{.DBusAddress}}', '{{.ListenerPath}}', '{{.ListenerInterface}}');
@@ -1,113 +1,2026 @@
+/**
+ * Get a comparable id for a virtual desktop.
+ * KWin 6 hands out desktop objects with an id, while KWin 5 uses plain desktop numbers,
+ * and the same desktop is not guaranteed to be the same wrapper object every time.
+ * @param {KWin::VirtualDesktop|number} desktop Desktop object or number
+ * @return {string} The desktop's id, or the value itself as a string
+ */
+function desktopId(desktop) {
+    if (desktop !== null && typeof desktop === 'object' && desktop.id !== undefined) {
+        return String(desktop.id);
+    }
+    return String(desktop);
+}
+
 /**
  * Checks if given window is on the current virtual desktop.
  * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to inspect
  * @return {boolean} True if window is on the current desktop or on all desktops
  */
 function isOnCurrentDesktop(client) {
+    return isOnDesktop(client, workspace.currentDesktop);
+}
+
+/**
+ * Look up a virtual desktop by its 1-based number or by name.
+ * @param {string} spec Desktop number or name
+ * @return {KWin::VirtualDesktop|number} Desktop object on KWin 6, desktop number on KWin 5
+ * @throws {Error} If no desktop has that number or name
+ */
+function findDesktop(spec) {
+    var isNumber = /^[0-9]+$/.test(spec);
+    var number = parseInt(spec, 10);
+    if (typeof workspace.desktops === 'number') {
+        // KWin 5: desktops is a count and desktops are referred to by number
+        if (isNumber && number >= 1 && number <= workspace.desktops) {
+            return number;
+        }
+        for (var n = 1; n <= workspace.desktops; n++) {
+            if (workspace.desktopName(n) === spec) {
+                return n;
+            }
+        }
+    } else {
+        var desktops = workspace.desktops || [];
+        if (isNumber && number >= 1 && number <= desktops.length) {
+            return desktops[number - 1];
+        }
+        for (var i = 0; i < desktops.length; i++) {
+            if (desktops[i].name === spec) {
+                return desktops[i];
+            }
+        }
+    }
+    throw new Error('no desktop named ' + spec);
+}
+
+/**
+ * Look up a virtual desktop by name, appending a new one with that name if there is none.
+ * Creating desktops needs KWin 6.
+ * @param {string} name Desktop name
+ * @return {KWin::VirtualDesktop|number} Desktop object on KWin 6, desktop number on KWin 5
+ * @throws {Error} If the desktop does not exist and cannot be created
+ */
+function findOrCreateDesktop(name) {
+    try {
+        return findDesktop(name);
+    } catch (e) {
+        if (typeof workspace.createDesktop !== 'function') {
+            throw new Error('no desktop named ' + name + ', and this KWin cannot create one');
+        }
+    }
+    workspace.createDesktop(workspace.desktops.length, name);
+    return findDesktop(name);
+}
+
+/**
+ * Checks if given window is on the given virtual desktop.
+ * Desktops are compared by id rather than object identity so this works across KWin versions.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to inspect
+ * @param {KWin::VirtualDesktop|number} desktop Desktop to look for
+ * @return {boolean} True if window is on the desktop or on all desktops
+ */
+function isOnDesktop(client, desktop) {
     if (client.onAllDesktops) {
         return true;
     }
-    if (workspace.currentDesktop !== undefined && client.desktops !== undefined ){
-        return client.desktops.includes(workspace.currentDesktop);
+    if (desktop === undefined || desktop === null) {
+        return true; // fallback if API mismatch
+    }
+    var wanted = desktopId(desktop);
+    if (client.desktops !== undefined && client.desktops !== null) {
+        for (var i = 0; i < client.desktops.length; i++) {
+            if (desktopId(client.desktops[i]) === wanted) {
+                return true;
+            }
+        }
+        return false;
+    }
+    if (client.desktop !== undefined) {
+        return client.desktop === -1 || desktopId(client.desktop) === wanted;
     }
     return true; // fallback if API mismatch
 }
 
+/**
+ * Checks if given window is on the given KDE activity.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to inspect
+ * @param {string} activity Activity id
+ * @return {boolean} True if window is on the activity or on all activities
+ */
+function isOnActivity(client, activity) {
+    if (client.activities === undefined || client.activities === null) {
+        return true; // fallback if API mismatch
+    }
+    if (client.activities.length === 0) {
+        return true; // an empty list means all activities
+    }
+    for (var i = 0; i < client.activities.length; i++) {
+        if (String(client.activities[i]) === activity) {
+            return true;
+        }
+    }
+    return false;
+}
+
+/**
+ * Checks if given window is shown in the task bar.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to inspect
+ * @return {boolean} True unless the window asks to be skipped by the task bar
+ */
+function isOnTaskbar(client) {
+    if (client.skipTaskbar === undefined) {
+        return true; // fallback if API mismatch
+    }
+    return !client.skipTaskbar;
+}
+
+/**
+ * Checks if given window is a desktop, dock, panel, or other special window rather than
+ * an application window.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to inspect
+ * @return {boolean} True if the window is special
+ */
+function isSpecialWindow(client) {
+    return !!(client.specialWindow || client.desktopWindow || client.dock);
+}
+
+/**
+ * Get the size state of a window.
+ * KWin 5 exposes maximizeMode directly; on KWin 6 a window counts as maximized when it
+ * fills the maximize area of its screen.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to inspect
+ * @return {string} 'fullscreen', 'maximized', or 'normal'
+ */
+function windowState(client) {
+    if (client.fullScreen) {
+        return 'fullscreen';
+    }
+    if (client.maximizeMode !== undefined) {
+        return client.maximizeMode === 3 ? 'maximized' : 'normal';
+    }
+    if (typeof KWin === 'undefined' || !client.frameGeometry) {
+        return 'normal'; // fallback if API mismatch
+    }
+    var area = workspace.clientArea(KWin.MaximizeArea, client);
+    var geometry = client.frameGeometry;
+    if (geometry.x === area.x && geometry.y === area.y &&
+        geometry.width === area.width && geometry.height === area.height) {
+        return 'maximized';
+    }
+    return 'normal';
+}
+
+/**
+ * Checks if one rectangle lies entirely inside another.
+ * @param {QRect} outer Outer rectangle
+ * @param {QRect} inner Inner rectangle
+ * @return {boolean} True if outer contains inner
+ */
+function rectContains(outer, inner) {
+    return inner.x >= outer.x && inner.y >= outer.y &&
+        inner.x + inner.width <= outer.x + outer.width && inner.y + inner.height <= outer.y + outer.height;
+}
+
+/**
+ * Checks if two rectangles overlap.
+ * @param {QRect} a First rectangle
+ * @param {QRect} b Second rectangle
+ * @return {boolean} True if they share any area
+ */
+function rectsIntersect(a, b) {
+    return a.x < b.x + b.width && b.x < a.x + a.width && a.y < b.y + b.height && b.y < a.y + a.height;
+}
+
+/**
+ * Checks if a window can currently be seen: it is not minimized, is on the current desktop and
+ * activity, overlaps some screen, and is not completely covered by a single window stacked above it.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to inspect
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients All windows, to look for covering ones
+ * @return {boolean} True if the window is visible
+ */
+function isVisible(client, clients) {
+    if (client.minimized || !isOnCurrentDesktop(client)) {
+        return false;
+    }
+    if (workspace.currentActivity !== undefined && !isOnActivity(client, String(workspace.currentActivity))) {
+        return false;
+    }
+    var geometry = client.frameGeometry;
+    if (!geometry) {
+        return true; // fallback if API mismatch
+    }
+    var screens = workspace.screens || [];
+    var onScreen = screens.length === 0;
+    for (var i = 0; i < screens.length; i++) {
+        if (!screens[i].geometry || rectsIntersect(geometry, screens[i].geometry)) {
+            onScreen = true;
+            break;
+        }
+    }
+    if (!onScreen) {
+        return false;
+    }
+    for (var j = 0; j < clients.length; j++) {
+        var other = clients[j];
+        if (other === client || other.minimized || !other.frameGeometry || isSpecialWindow(other)) {
+            continue;
+        }
+        if (other.stackingOrder > client.stackingOrder && isOnCurrentDesktop(other) &&
+            rectContains(other.frameGeometry, geometry)) {
+            return false;
+        }
+    }
+    return true;
+}
+
+/**
+ * Checks if two outputs are the same screen, by identity or by connector name.
+ * @param {KWin::Output} a First output
+ * @param {KWin::Output} b Second output
+ * @return {boolean} True if both refer to the same screen
+ */
+function isSameOutput(a, b) {
+    if (a === b) {
+        return true;
+    }
+    return !!a && !!b && a.name !== undefined && a.name === b.name;
+}
+
+/**
+ * Look up a screen by connector name (e.g. DP-1) or by its 0-based index in workspace.screens.
+ * @param {string} spec Screen name or index
+ * @return {KWin::Output} The screen
+ * @throws {Error} If no screen has that name or index
+ */
+function findScreen(spec) {
+    var screens = workspace.screens || [];
+    if (/^[0-9]+$/.test(spec)) {
+        var index = parseInt(spec, 10);
+        if (index < screens.length) {
+            return screens[index];
+        }
+    }
+    for (var i = 0; i < screens.length; i++) {
+        if (screens[i].name === spec) {
+            return screens[i];
+        }
+    }
+    throw new Error('no screen named ' + spec);
+}
+
+/**
+ * Narrow the candidates to windows on the focused screen, which KWin takes from the pointer or the
+ * active window depending on its "active screen follows mouse" setting.
+ * Falls back to all given windows when there is nothing to activate or cycle to on the focused screen,
+ * so matches on other screens are reached once those on it are exhausted.
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients Matching windows
+ * @return {Array<KWin::XdgToplevelWindow|KWin::X11Window>} Windows to activate or cycle through
+ */
+function preferCurrentScreen(clients) {
+    var currentScreen = workspace.activeScreen;
+    if (currentScreen === undefined || currentScreen === null) {
+        return clients; // fallback if API mismatch
+    }
+    var onCurrentScreen = [];
+    var others = 0;
+    for (var i = 0; i < clients.length; i++) {
+        if (clients[i].output === undefined || isSameOutput(clients[i].output, currentScreen)) {
+            onCurrentScreen.push(clients[i]);
+            if (clients[i] !== workspace.activeWindow) {
+                others++;
+            }
+        }
+    }
+    return others > 0 ? onCurrentScreen : clients;
+}
+
+/**
+ * Find the topmost dialog or other transient window that belongs to a window.
+ * Transients of transients count too, so a confirmation dialog opened from a settings
+ * dialog is found from the main window.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Main window
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients All windows
+ * @return {KWin::XdgToplevelWindow|KWin::X11Window} The topmost transient, or client itself if it has none
+ */
+function topmostTransient(client, clients) {
+    var topmost = client;
+    for (var i = 0; i < clients.length; i++) {
+        var parent = clients[i].transientFor;
+        while (parent) {
+            if (parent === client) {
+                if (topmost === client || clients[i].stackingOrder > topmost.stackingOrder) {
+                    topmost = clients[i];
+                }
+                break;
+            }
+            parent = parent.transientFor;
+        }
+    }
+    return topmost;
+}
+
+/**
+ * Replace each window with its topmost transient, so an open modal dialog gets focus
+ * instead of the window behind it.
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients Matching windows
+ * @return {Array<KWin::XdgToplevelWindow|KWin::X11Window>} Windows to activate or cycle through
+ */
+function withDialogs(clients) {
+    var all = workspace.windowList();
+    var result = [];
+    for (var i = 0; i < clients.length; i++) {
+        var target = topmostTransient(clients[i], all);
+        if (result.indexOf(target) === -1) {
+            result.push(target);
+        }
+    }
+    return result;
+}
+
+/**
+ * Score how well text matches query as a fuzzy subsequence, in the spirit of fzf.
+ * Every query character must appear in text in order; matches that are consecutive
+ * or start a word score higher, and gaps between matched characters cost a little.
+ * @param {string} query Characters to look for, compared case-insensitively
+ * @param {string} text Text to search, e.g. a window class or caption
+ * @return {number} The match score, or -1 if text does not contain query as a subsequence
+ */
+function fuzzyScore(query, text) {
+    var q = query.toLowerCase();
+    var t = String(text).toLowerCase();
+    var score = 0;
+    var last = -1;
+    for (var i = 0; i < q.length; i++) {
+        var j = t.indexOf(q.charAt(i), last + 1);
+        if (j === -1) {
+            return -1;
+        }
+        score += 1;
+        if (j === last + 1) {
+            score += 2;
+        }
+        if (j === 0 || !/[a-z0-9]/.test(t.charAt(j - 1))) {
+            score += 3;
+        }
+        score -= Math.min(j - last - 1, 3);
+        last = j;
+    }
+    // Long gaps can push a genuine match below zero; keep it above "no match".
+    return Math.max(score, 0);
+}
+
+/**
+ * Application identifiers that differ between X11 WM_CLASS, Wayland app_id, and desktop file
+ * names, keyed by their normalized form and mapped to a common name.
+ */
+var smartClassAliases = {
+    'google-chrome': 'chrome',
+    'chromium-browser': 'chromium',
+    'code-oss': 'code',
+    'code-url-handler': 'code',
+    'visual studio code': 'code',
+    'gnome-terminal-server': 'gnome-terminal',
+    'telegramdesktop': 'telegram',
+    'telegram-desktop': 'telegram',
+    'libreoffice-startcenter': 'libreoffice',
+    'soffice': 'libreoffice',
+    'thunderbird-esr': 'thunderbird',
+    'firefox-esr': 'firefox'
+};
+
+/**
+ * Normalize an application identifier for --smart-class: lower-case it, drop a .desktop
+ * suffix and any reverse-DNS prefix (org.kde.konsole becomes konsole), and apply the
+ * alias table.
+ * @param {string} id Window class, desktop file name, or class filter
+ * @return {string} Normalized identifier
+ */
+function smartClassKey(id) {
+    var key = String(id).toLowerCase().replace(/\.desktop$/, '');
+    if (smartClassAliases.hasOwnProperty(key)) {
+        return smartClassAliases[key];
+    }
+    var dot = key.lastIndexOf('.');
+    if (dot !== -1) {
+        key = key.substring(dot + 1);
+    }
+    return smartClassAliases.hasOwnProperty(key) ? smartClassAliases[key] : key;
+}
+
+/**
+ * Checks if a window is ruled out by the exclusion filters.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to inspect
+ * @param {Array<string>} excludeClasses Window classes to skip (exact match, lower-cased when ignoring case)
+ * @param {Array<RegExp>} excludeCaptions Caption patterns to skip
+ * @param {boolean} ignoreCase If true, compare the window class case-insensitively
+ * @param {Array<RegExp>} excludeClassRegexes Window class patterns to skip
+ * @return {boolean} True if the window matches any exclusion
+ */
+function isExcluded(client, excludeClasses, excludeCaptions, ignoreCase, excludeClassRegexes) {
+    var clientClass = ignoreCase ? String(client.resourceClass).toLowerCase() : String(client.resourceClass);
+    for (var i = 0; i < excludeClasses.length; i++) {
+        if (clientClass === excludeClasses[i]) {
+            return true;
+        }
+    }
+    for (var j = 0; j < excludeCaptions.length; j++) {
+        if (excludeCaptions[j].exec(client.caption)) {
+            return true;
+        }
+    }
+    for (var k = 0; k < excludeClassRegexes.length; k++) {
+        if (excludeClassRegexes[k].exec(client.resourceClass)) {
+            return true;
+        }
+    }
+    return false;
+}
+
 /**
  * Find all windows matching the specified filters.
- * @param {string} clientClass Window class to match (exact match)
- * @param {string} clientCaption Window caption/title to match (regex, case-insensitive)
- * @param {string} clientClassRegex Window class regex pattern to match
- * @param {boolean} currentDesktopOnly If true, only include windows on current desktop
+ * @param {Object} options Filter settings rendered from the Go side
+ * @param {Array<string>} options.classNames Window classes to match (exact match); windows of earlier classes are preferred
+ * @param {boolean} options.smartClass If true, compare classNames in normalized form against both the window
+ *     class and its desktop file name, so X11 and Wayland identifiers of the same app match
+ * @param {boolean} options.ignoreCase If true, compare the window class (exact and regex) and name case-insensitively
+ * @param {string} options.captionPattern Window caption/title to match (regex)
+ * @param {string} options.captionFlags RegExp flags for captionPattern ('i' by default)
+ * @param {boolean} options.captionFullMatch If true, captionPattern must match the whole caption
+ * @param {string} options.titleSubstring Window caption/title to match (plain substring, case-insensitive)
+ * @param {string} options.captionExact Window caption/title to match (literal full title, case-sensitive)
+ * @param {string} options.classRegex Window class regex pattern to match
+ * @param {boolean} options.allFilters If true, a window must match every class and caption filter that is set
+ * @param {string} options.fuzzy Fuzzy query matched against class and caption; only the best-scoring windows are kept
+ * @param {boolean} options.captionFirst If true, prefer caption matches and use class filters only as a fallback
+ * @param {string} options.windowId KWin internal ID to match exactly; bypasses all other filters when set
+ * @param {number} options.pid Process ID the window must belong to (0 to disable)
+ * @param {boolean} options.restrictPids If true, the window must belong to one of options.pids
+ * @param {Array<number>} options.pids Process IDs resolved on the Go side, e.g. from --cmdline
+ * @param {number} options.openedAfter Time in milliseconds the window must have been opened at or after
+ *     (0 to disable); windows opened before jumpkwapp --track-windows started never match
+ * @param {Object} options.openTimes Time each window was opened in milliseconds, by window internal ID,
+ *     as recorded by jumpkwapp --track-windows
+ * @param {string} options.desktopFile Desktop file name the window must report (exact match, empty to disable)
+ * @param {string} options.resourceName Window resource name the window must have (exact match, empty to disable)
+ * @param {string} options.role Window role the window must have (exact match, empty to disable)
+ * @param {?function(Object): boolean} options.match Predicate compiled from --match, or null
+ * @param {Array<string>} options.excludeClasses Window classes to skip (exact match)
+ * @param {Array<string>} options.excludeCaptions Window captions to skip (regex, case-insensitive)
+ * @param {Array<string>} options.excludeClassRegexes Window classes to skip (regex), from the config file's ignore list
+ * @param {boolean} options.currentDesktopOnly If true, only include windows on current desktop
+ * @param {string} options.desktop Desktop number (1-based) or name the window must be on (empty to disable)
+ * @param {string} options.activity Activity id the window must be on (empty to disable)
+ * @param {boolean} options.currentActivity If true, only include windows on the current activity
+ * @param {string} options.screen Screen name or index the window must be on (empty to disable)
+ * @param {boolean} options.currentScreen If true, only include windows on the focused screen
+ * @param {boolean} options.includeSkipTaskbar If true, keep windows hidden from the task bar, which are skipped by default
+ * @param {boolean} options.other If true, skip the active window so another match is chosen
+ * @param {boolean} options.includeSpecial If true, keep desktop, dock, and other special windows
+ * @param {boolean} options.visibleOnly If true, only include windows that can currently be seen
+ * @param {boolean} options.minimizedOnly If true, only include minimized windows
+ * @param {boolean} options.skipMinimized If true, skip minimized windows
+ * @param {string} options.state Size state the window must be in: fullscreen, maximized, or normal,
+ *     or one of those prefixed with '!' to skip windows in that state (empty to disable)
  * @return {Array<KWin::XdgToplevelWindow|KWin::X11Window>} Array of matching windows
  */
-function findMatchingClients(clientClass, clientCaption, clientClassRegex, currentDesktopOnly) {
+function findMatchingClients(options) {
     var clients = workspace.windowList();
-    var compareToCaption = new RegExp(clientCaption || '', 'i');
-    var compareToClassRegex = clientClassRegex.length > 0 ? new RegExp(clientClassRegex) : null;
-    var compareToClass = clientClass;
-    var isCompareToClass = clientClass.length > 0;
+
+    if (options.windowId.length > 0) {
+        for (var k = 0; k < clients.length; k++) {
+            if (String(clients[k].internalId) === options.windowId) {
+                return [clients[k]];
+            }
+        }
+        return [];
+    }
+
+    var captionSource = options.captionPattern || '';
+    if (options.captionFullMatch && captionSource.length > 0) {
+        captionSource = '^(?:' + captionSource + ')$';
+    }
+    var compareToCaption = new RegExp(captionSource, options.captionFlags);
+    var compareToTitle = options.titleSubstring.toLowerCase();
+    var isCompareToTitle = compareToTitle.length > 0;
+    var isCompareToExact = options.captionExact.length > 0;
+    var compareToClassRegex = options.classRegex.length > 0 ? new RegExp(options.classRegex, options.ignoreCase ? 'i' : '') : null;
+    var compareToClasses = [];
+    for (var c = 0; c < options.classNames.length; c++) {
+        if (options.smartClass) {
+            compareToClasses.push(smartClassKey(options.classNames[c]));
+        } else {
+            compareToClasses.push(options.ignoreCase ? options.classNames[c].toLowerCase() : options.classNames[c]);
+        }
+    }
+    var isCompareToClass = compareToClasses.length > 0;
+    var compareToName = options.ignoreCase ? options.resourceName.toLowerCase() : options.resourceName;
     var isCompareToRegex = compareToClassRegex !== null;
+    var isCompareToCaption = options.captionPattern.length > 0 || isCompareToTitle || isCompareToExact;
+    var captionFirst = options.captionFirst && isCompareToCaption && !options.allFilters;
+    var isFuzzy = options.fuzzy.length > 0;
+    var bestFuzzyScore = -1;
+    var excludeClasses = [];
+    for (var e = 0; e < options.excludeClasses.length; e++) {
+        excludeClasses.push(options.ignoreCase ? options.excludeClasses[e].toLowerCase() : options.excludeClasses[e]);
+    }
+    var excludeCaptions = [];
+    for (var x = 0; x < options.excludeCaptions.length; x++) {
+        excludeCaptions.push(new RegExp(options.excludeCaptions[x], 'i'));
+    }
+    var excludeClassRegexes = [];
+    for (var y = 0; y < options.excludeClassRegexes.length; y++) {
+        excludeClassRegexes.push(new RegExp(options.excludeClassRegexes[y], options.ignoreCase ? 'i' : ''));
+    }
+    var excludeSkipTaskbar = !options.includeSkipTaskbar;
+    var wantState = options.state.replace(/^!/, '');
+    var negateState = options.state.charAt(0) === '!';
+    var onlyDesktop = options.desktop.length > 0 ? findDesktop(options.desktop) : null;
+    var onlyActivity = options.activity;
+    if (options.currentActivity && workspace.currentActivity !== undefined) {
+        onlyActivity = String(workspace.currentActivity);
+    }
+    var onlyScreen = null;
+    if (options.currentScreen) {
+        onlyScreen = workspace.activeScreen;
+    } else if (options.screen.length > 0) {
+        onlyScreen = findScreen(options.screen);
+    }
     var matchingClients = [];
+    var captionClients = [];
+    // Class matches are bucketed by the position of their class in classNames, so that
+    // only the windows of the first listed class that has any are used.
+    var classBuckets = [];
 
     for (var i = 0; i < clients.length; i++) {
         var client = clients[i];
-        var classCompare = (isCompareToClass && client.resourceClass == compareToClass);
+        var captionMatch;
+        if (isCompareToExact) {
+            captionMatch = String(client.caption) === options.captionExact;
+        } else if (isCompareToTitle) {
+            captionMatch = String(client.caption).toLowerCase().indexOf(compareToTitle) !== -1;
+        } else {
+            captionMatch = compareToCaption.exec(client.caption);
+        }
+        var captionFirstCompare = (captionFirst && captionMatch);
+        var clientClass = options.ignoreCase ? String(client.resourceClass).toLowerCase() : String(client.resourceClass);
+        var classRank;
+        if (options.smartClass) {
+            classRank = compareToClasses.indexOf(smartClassKey(client.resourceClass));
+            if (classRank === -1 && client.desktopFileName) {
+                classRank = compareToClasses.indexOf(smartClassKey(client.desktopFileName));
+            }
+        } else {
+            classRank = compareToClasses.indexOf(clientClass);
+        }
+        var classCompare = (isCompareToClass && classRank !== -1);
         var classRegexCompare = (isCompareToRegex && compareToClassRegex && compareToClassRegex.exec(client.resourceClass));
-        var captionCompare = (!isCompareToClass && !isCompareToRegex && compareToCaption.exec(client.caption));
-        if (classCompare || classRegexCompare || captionCompare) {
-            if (currentDesktopOnly && !isOnCurrentDesktop(client)) {
+        var captionCompare = (!isCompareToClass && !isCompareToRegex && !isFuzzy && captionMatch);
+        var fuzzyScoreValue = isFuzzy
+            ? Math.max(fuzzyScore(options.fuzzy, client.resourceClass), fuzzyScore(options.fuzzy, client.caption))
+            : -1;
+        var fuzzyCompare = (isFuzzy && fuzzyScoreValue >= 0);
+        if (options.allFilters && !isFuzzy) {
+            classCompare = (!isCompareToClass || classCompare) &&
+                (!isCompareToRegex || classRegexCompare) &&
+                (!isCompareToCaption || captionMatch);
+            classRegexCompare = false;
+            captionCompare = false;
+        }
+        if (captionFirstCompare || classCompare || classRegexCompare || captionCompare || fuzzyCompare) {
+            if (options.other && client === workspace.activeWindow) {
+                continue;
+            }
+            if (!options.includeSpecial && isSpecialWindow(client)) {
+                continue;
+            }
+            if (options.match && !options.match(client)) {
+                continue;
+            }
+            if (isExcluded(client, excludeClasses, excludeCaptions, options.ignoreCase, excludeClassRegexes)) {
+                continue;
+            }
+            if (options.currentDesktopOnly && !isOnCurrentDesktop(client)) {
+                continue;
+            }
+            if (onlyDesktop !== null && !isOnDesktop(client, onlyDesktop)) {
+                continue;
+            }
+            if (onlyActivity.length > 0 && !isOnActivity(client, onlyActivity)) {
+                continue;
+            }
+            if (onlyScreen && client.output !== undefined && !isSameOutput(client.output, onlyScreen)) {
+                continue;
+            }
+            if (options.visibleOnly && !isVisible(client, clients)) {
+                continue;
+            }
+            if ((options.minimizedOnly && !client.minimized) || (options.skipMinimized && client.minimized)) {
+                continue;
+            }
+            if (wantState.length > 0 && (windowState(client) === wantState) === negateState) {
+                continue;
+            }
+            if (excludeSkipTaskbar && !isOnTaskbar(client)) {
+                continue;
+            }
+            if (options.pid > 0 && client.pid !== options.pid) {
+                continue;
+            }
+            if (options.restrictPids && options.pids.indexOf(client.pid) === -1) {
+                continue;
+            }
+            if (options.openedAfter > 0 && !(options.openTimes[String(client.internalId)] >= options.openedAfter)) {
+                continue;
+            }
+            if (options.desktopFile.length > 0 && String(client.desktopFileName) !== options.desktopFile) {
+                continue;
+            }
+            if (compareToName.length > 0 &&
+                (options.ignoreCase ? String(client.resourceName).toLowerCase() : String(client.resourceName)) !== compareToName) {
+                continue;
+            }
+            if (options.role.length > 0 && String(client.windowRole) !== options.role) {
                 continue;
             }
-            matchingClients.push(client);
+            if (captionFirstCompare) {
+                captionClients.push(client);
+            } else if (classCompare && classRank !== -1) {
+                if (!classBuckets[classRank]) {
+                    classBuckets[classRank] = [];
+                }
+                classBuckets[classRank].push(client);
+            } else if (fuzzyCompare) {
+                // Only the best-scoring windows are kept; ties are cycled as usual.
+                if (fuzzyScoreValue > bestFuzzyScore) {
+                    bestFuzzyScore = fuzzyScoreValue;
+                    matchingClients = [];
+                }
+                if (fuzzyScoreValue === bestFuzzyScore) {
+                    matchingClients.push(client);
+                }
+            } else {
+                matchingClients.push(client);
+            }
         }
     }
 
+    // With captionFirst, caption matches win outright and the class filters
+    // only serve as a fallback, so the two sets are never mixed.
+    if (captionFirst && captionClients.length > 0) {
+        return captionClients;
+    }
+    for (var b = 0; b < classBuckets.length; b++) {
+        if (classBuckets[b]) {
+            return classBuckets[b].concat(matchingClients);
+        }
+    }
     return matchingClients;
 }
 
 /**
- * Set the specified window as the active window.
- * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to activate
+ * Tell the jumpkwapp listener whether it should launch its fallback command.
+ * Does nothing when no listener address was rendered into the script.
+ * @param {Object} options Listener settings rendered from the Go side
+ * @param {string} options.dbusAddr D-Bus address of the listener (empty string to disable)
+ * @param {string} options.listenerPath Object path the listener is exported on
+ * @param {string} options.listenerInterface Interface name the listener is exported with
+ * @param {string} decision 'true' to launch the command, 'false' if a window matched, 'error' if the script failed
  */
-function setActiveClient(client){
-    workspace.activeWindow = client;
+function notifyListener(options, decision) {
+    if (options.dbusAddr) {
+        callDBus(options.dbusAddr, options.listenerPath, options.listenerInterface, 'ShouldLaunch', decision);
+    }
 }
 
 /**
- * Activate a window matching the specified filters, or signal via D-Bus if no match found.
- * When multiple windows match, cycles through them based on current focus state.
- * @param {string} clientClass Window class to match (exact match)
- * @param {string} clientCaption Window caption/title to match (regex, case-insensitive)
- * @param {string} clientClassRegex Window class regex pattern to match
- * @param {boolean} toggle If true, minimize the window if it's already active
- * @param {boolean} currentDesktopOnly If true, only match windows on current desktop
- * @param {string} dbusAddr D-Bus address to signal if no windows found (empty string to disable)
+ * Number of times activation is re-asserted when KWin has not focused the target yet.
+ * Set from the rendered options before any window is activated.
  */
-function kwinActivateClient(clientClass, clientCaption, clientClassRegex, toggle, currentDesktopOnly, dbusAddr) {
-    var matchingClients = findMatchingClients(clientClass, clientCaption, clientClassRegex, currentDesktopOnly);
+var activationRetries = 0;
 
-    if (matchingClients.length === 0) {
-        if (dbusAddr) {
-            callDBus(dbusAddr, '/org/jumpkwapp/Listener', 'org.jumpkwapp.Listener', 'ShouldLaunch', 'true');
-        }
-        return;
-    }
+/**
+ * Delay between activation retries, in milliseconds.
+ */
+var activationRetryDelay = 50;
+
+/**
+ * If true, windows are moved to the current desktop before they are activated.
+ */
+var summonWindows = false;
+
+/**
+ * If true, summoned windows are also moved to the focused screen.
+ */
+var summonToScreen = false;
+
+/**
+ * Screen windows are moved to before they are activated, or null to leave them where they are.
+ */
+var targetScreen = null;
+
+/**
+ * Id of the activity windows are moved to before they are activated, or empty to leave them be.
+ */
+var targetActivity = '';
 
-    if (dbusAddr) {
-        callDBus(dbusAddr, '/org/jumpkwapp/Listener', 'org.jumpkwapp.Listener', 'ShouldLaunch', 'false');
+/**
+ * Move a window to the given screen unless it is already there.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to move
+ * @param {KWin::Output} screen Destination screen
+ */
+function moveToScreen(client, screen) {
+    if (client.output !== undefined && !isSameOutput(client.output, screen)) {
+        workspace.sendClientToScreen(client, screen);
     }
+}
 
-    var activeWindow = workspace.activeWindow;
+/**
+ * Geometry saved by earlier toggles, by window internal ID; see rememberClientGeometry.
+ */
+var savedGeometry = {};
 
-    if (matchingClients.length === 1) {
-        var client = matchingClients[0];
-        if (activeWindow !== client) {
-            setActiveClient(client);
-        } else if (toggle) {
-            client.minimized = !client.minimized;
-        }
-    } else if (matchingClients.length > 1) {
-        var activeIsMatching = false;
-        for (var j = 0; j < matchingClients.length; j++) {
-            if (activeWindow === matchingClients[j]) {
-                activeIsMatching = true;
-                break;
-            }
-        }
+/**
+ * Changes to the saved geometry to send back to the Go side: window IDs mapped to
+ * the geometry to save, or to null to drop what was saved.
+ */
+var geometryUpdates = {};
 
-        matchingClients.sort(function (a, b) {
-            return a.stackingOrder - b.stackingOrder;
-        });
+/**
+ * Set when a window's saved geometry was restored, so the size and placement options
+ * do not move it again.
+ */
+var geometryRestored = false;
 
-        if (activeIsMatching) {
-            var nextClient = matchingClients[0];
-            setActiveClient(nextClient);
-        } else {
-            var newestClient = matchingClients[matchingClients.length - 1];
-            setActiveClient(newestClient);
+/**
+ * Look up a virtual desktop by the id desktopId gives it.
+ * @param {string} id Desktop id
+ * @return {KWin::VirtualDesktop|number|null} The desktop, or null if it no longer exists
+ */
+function findDesktopById(id) {
+    if (typeof workspace.desktops === 'number') {
+        var number = parseInt(id, 10);
+        return number >= 1 && number <= workspace.desktops ? number : null;
+    }
+    var desktops = workspace.desktops || [];
+    for (var i = 0; i < desktops.length; i++) {
+        if (desktopId(desktops[i]) === id) {
+            return desktops[i];
         }
     }
+    return null;
 }
 
-kwinActivateClient('{{.ClassName}}', '{{.CaptionPattern}}', '{{.ClassRegex}}', {{if .Toggle}}true{{else}}false{{end}}, {{if .CurrentDesktopOnly}}true{{else}}false{{end}}, '{{.DBusAddress}}');
+/**
+ * Record where a window is before it is hidden, to be sent back to the Go side.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window about to be hidden
+ */
+function rememberClientGeometry(client) {
+    var geometry = client.frameGeometry;
+    var desktops = [];
+    if (client.desktops !== undefined) {
+        for (var i = 0; i < client.desktops.length; i++) {
+            desktops.push(desktopId(client.desktops[i]));
+        }
+    } else {
+        desktops.push(desktopId(client.desktop));
+    }
+    geometryUpdates[String(client.internalId)] = {
+        x: Math.round(geometry.x),
+        y: Math.round(geometry.y),
+        width: Math.round(geometry.width),
+        height: Math.round(geometry.height),
+        screen: client.output ? String(client.output.name) : '',
+        desktops: desktops,
+        onAllDesktops: !!client.onAllDesktops
+    };
+}
+
+/**
+ * Describe windows for the --pick menu, topmost first.
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients Windows to choose from
+ * @return {Array<Object>} Internal ID, class, caption and desktop of each window
+ */
+function describeChoices(clients) {
+    var sorted = clients.slice().sort(compareCycleOrder).reverse();
+    var choices = [];
+    for (var i = 0; i < sorted.length; i++) {
+        choices.push({
+            internalId: String(sorted[i].internalId),
+            resourceClass: String(sorted[i].resourceClass),
+            resourceName: String(sorted[i].resourceName),
+            caption: String(sorted[i].caption),
+            pid: sorted[i].pid,
+            desktop: desktopLabel(sorted[i])
+        });
+    }
+    return choices;
+}
+
+/**
+ * Name the desktops a window is on, for display.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to describe
+ * @return {string} Desktop names joined by commas, or 'all'
+ */
+function desktopLabel(client) {
+    if (client.onAllDesktops) {
+        return 'all';
+    }
+    if (client.desktops === undefined) {
+        return String(client.desktop); // KWin 5 numbers desktops
+    }
+    var names = [];
+    for (var i = 0; i < client.desktops.length; i++) {
+        names.push(String(client.desktops[i].name));
+    }
+    return names.join(',');
+}
+
+/**
+ * Put a hidden window back where it was when it was last hidden, including its screen and desktops.
+ * A saved geometry is only used once; it is dropped if the window was shown some other way since.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window about to be shown
+ */
+function restoreClientGeometry(client) {
+    var id = String(client.internalId);
+    var saved = savedGeometry[id];
+    if (!saved) {
+        return;
+    }
+    geometryUpdates[id] = null;
+    if (!client.minimized) {
+        return;
+    }
+    if (saved.onAllDesktops) {
+        client.onAllDesktops = true;
+    } else {
+        var desktops = [];
+        for (var i = 0; i < saved.desktops.length; i++) {
+            var desktop = findDesktopById(saved.desktops[i]);
+            if (desktop !== null) {
+                desktops.push(desktop);
+            }
+        }
+        if (desktops.length > 0) {
+            if (client.desktops !== undefined) {
+                client.desktops = desktops;
+            } else {
+                client.desktop = desktops[0];
+            }
+        }
+    }
+    if (saved.screen) {
+        try {
+            moveToScreen(client, findScreen(saved.screen));
+        } catch (e) {
+            // The screen is gone; keep the window where KWin put it.
+        }
+    }
+    client.frameGeometry = { x: saved.x, y: saved.y, width: saved.width, height: saved.height };
+    geometryRestored = true;
+}
+
+/**
+ * Summon a window and move it to the target screen and activity, as requested, before it is shown.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window about to be activated or raised
+ */
+function prepareClient(client) {
+    restoreClientGeometry(client);
+    if (summonWindows) {
+        summonClient(client);
+    }
+    if (targetScreen) {
+        moveToScreen(client, targetScreen);
+    }
+    if (targetActivity) {
+        client.activities = [targetActivity];
+    }
+}
+
+/**
+ * Move a window to a single virtual desktop.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to move
+ * @param {KWin::VirtualDesktop|number} desktop Desktop object on KWin 6, desktop number on KWin 5
+ */
+function moveToDesktop(client, desktop) {
+    if (client.desktops !== undefined) {
+        client.desktops = [desktop];
+    } else {
+        client.desktop = desktop;
+    }
+}
+
+/**
+ * Move a window to the current desktop and activity, and with summonToScreen to the focused
+ * screen, so activating it does not switch away from where the user is.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to bring over
+ */
+function summonClient(client) {
+    if (!client.onAllDesktops && !isOnCurrentDesktop(client)) {
+        moveToDesktop(client, workspace.currentDesktop);
+    }
+    if (workspace.currentActivity !== undefined && !isOnActivity(client, String(workspace.currentActivity))) {
+        client.activities = [workspace.currentActivity];
+    }
+    if (summonToScreen && workspace.activeScreen) {
+        moveToScreen(client, workspace.activeScreen);
+    }
+}
+
+/**
+ * Set the specified window as the active window.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to activate
+ */
+function setActiveClient(client){
+    prepareClient(client);
+    workspace.activeWindow = client;
+    scheduleActivationRetry(client, activationRetries);
+}
+
+/**
+ * Re-assert activation after a short delay if the window still is not active.
+ * On Wayland the first activation after the compositor has been idle is sometimes ignored.
+ * Relies on the QTimer class KWin exposes to scripts; without it no retry is attempted.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window that should become active
+ * @param {number} remaining Retries left
+ */
+function scheduleActivationRetry(client, remaining) {
+    if (remaining <= 0 || typeof QTimer === 'undefined') {
+        return;
+    }
+    var timer = new QTimer();
+    timer.singleShot = true;
+    timer.timeout.connect(function () {
+        if (workspace.activeWindow !== client) {
+            workspace.activeWindow = client;
+            scheduleActivationRetry(client, remaining - 1);
+        }
+    });
+    timer.start(activationRetryDelay);
+}
+
+/**
+ * Compare two windows for cycling order: lowest stacking order first.
+ * Stacking order alone is not a reliable key, as windows can briefly share a value while KWin
+ * restacks and the sort is not guaranteed to be stable, so ties are broken by internal ID
+ * to keep the cycle order the same across invocations.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} a First window
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} b Second window
+ * @return {number} Negative if a sorts first, positive if b sorts first, 0 if equal
+ */
+function compareStackingOrder(a, b) {
+    if (a.stackingOrder !== b.stackingOrder) {
+        return a.stackingOrder - b.stackingOrder;
+    }
+    var idA = String(a.internalId);
+    var idB = String(b.internalId);
+    if (idA < idB) {
+        return -1;
+    }
+    return idA > idB ? 1 : 0;
+}
+
+/**
+ * Build a comparator ordering windows by when jumpkwapp last activated them, least recent first.
+ * Windows it never activated sort before the others, by stacking order, and the active window
+ * counts as the most recent whoever activated it.
+ * @param {Object} times Last activation time in milliseconds, by window internal ID
+ * @return {function} Comparator for Array.prototype.sort
+ */
+function compareRecency(times) {
+    return function (a, b) {
+        var ta = a === workspace.activeWindow ? Infinity : times[String(a.internalId)] || 0;
+        var tb = b === workspace.activeWindow ? Infinity : times[String(b.internalId)] || 0;
+        if (ta !== tb) {
+            return ta - tb;
+        }
+        return compareStackingOrder(a, b);
+    };
+}
+
+/**
+ * Build a comparator ordering windows by when they were opened, oldest first. Windows with no recorded
+ * opening time were already open when jumpkwapp --track-windows started, so they sort before the others,
+ * by stacking order.
+ * @param {Object} times Time each window was opened in milliseconds, by window internal ID
+ * @return {function} Comparator for Array.prototype.sort
+ */
+function compareOpenTime(times) {
+    return function (a, b) {
+        var ta = times[String(a.internalId)] || 0;
+        var tb = times[String(b.internalId)] || 0;
+        if (ta !== tb) {
+            return ta - tb;
+        }
+        return compareStackingOrder(a, b);
+    };
+}
+
+/**
+ * Order windows alphabetically by caption, ignoring case, for --cycle-order caption.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} a First window
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} b Second window
+ * @return {number} Negative if a comes first, positive if b does, 0 if they tie
+ */
+function compareCaption(a, b) {
+    var captionA = String(a.caption).toLowerCase();
+    var captionB = String(b.caption).toLowerCase();
+    if (captionA !== captionB) {
+        return captionA < captionB ? -1 : 1;
+    }
+    return compareStackingOrder(a, b);
+}
+
+/**
+ * Order in which matches are cycled through: compareStackingOrder, least recently used first;
+ * compareRecency with --cycle-order mru; or compareCaption with --cycle-order caption.
+ */
+var compareCycleOrder = compareStackingOrder;
+
+/**
+ * Pick the match to cycle to from the active one.
+ * Going forward the least recently used match is next, which is the bottom-most one in stacking
+ * order; in reverse it is the match right below the active one, and the active one is demoted so
+ * the following press carries on downwards; see demoteClient. Caption order is not changed by
+ * activating windows, so there the match after the active one is next, or the one before it in reverse.
+ * @param {Object} options Settings rendered from the Go side; see activateMatchingClients
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients Matches sorted by compareCycleOrder
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} active The active window, one of the matches
+ * @return {KWin::XdgToplevelWindow|KWin::X11Window|null} Window to activate, or null if there is none
+ */
+function nextInCycle(options, clients, active) {
+    var order = clients;
+    if (options.reverse || options.cycleOrder === 'caption') {
+        var start = clients.indexOf(active);
+        var direction = options.reverse ? -1 : 1;
+        order = [];
+        for (var step = 1; step < clients.length; step++) {
+            order.push(clients[(start + direction * step + clients.length) % clients.length]);
+        }
+    }
+    for (var i = 0; i < order.length; i++) {
+        if (order[i] !== active && !(options.cycleSkipMinimized && order[i].minimized)) {
+            return order[i];
+        }
+    }
+    return null;
+}
+
+/**
+ * Internal ID of the window sent to the back of the --cycle-order mru order when cycling in
+ * reverse, reported so the Go side can record it as least recently used; empty if none.
+ */
+var demotedWindow = '';
+
+/**
+ * Send the active match to the back of the cycle order before cycling backwards from it.
+ * Activating the next window raises it (and makes it the most recent), so without this the next
+ * press would come straight back and reverse cycling would only swap the top two matches.
+ * @param {Object} options Settings rendered from the Go side; see activateMatchingClients
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} active The active window, still focused
+ */
+function demoteClient(options, active) {
+    if (options.cycleOrder === 'mru') {
+        demotedWindow = String(active.internalId);
+    } else {
+        workspace.slotWindowLower(); // acts on the active window
+    }
+}
+
+/**
+ * Pick the match to activate from the one activated last time with the same filters, so repeated
+ * presses walk through every match in a fixed order however KWin restacks them.
+ * @param {Object} options Settings rendered from the Go side; see activateMatchingClients
+ * @param {string} options.lastCycled Internal ID of the match activated last time, or empty
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients Matching windows
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} active The active window
+ * @param {boolean} activeIsMatching Whether the active window is one of the matches
+ * @return {KWin::XdgToplevelWindow|KWin::X11Window|null} Window to activate, or null to fall back
+ *     to the usual choice when nothing is remembered
+ */
+function persistentCycleNext(options, clients, active, activeIsMatching) {
+    var sorted = clients.slice().sort(function (a, b) {
+        var idA = String(a.internalId);
+        var idB = String(b.internalId);
+        return idA < idB ? -1 : (idA > idB ? 1 : 0);
+    });
+    var last = -1;
+    for (var i = 0; i < sorted.length; i++) {
+        if (String(sorted[i].internalId) === options.lastCycled) {
+            last = i;
+        }
+    }
+    if (!activeIsMatching) {
+        return last >= 0 ? sorted[last] : null;
+    }
+    if (last < 0) {
+        last = sorted.indexOf(active);
+    }
+    var step = options.reverse ? sorted.length - 1 : 1;
+    var next = sorted[(last + step) % sorted.length];
+    if (next === active) {
+        // Focus moved to another match since; skip over the one already active.
+        next = sorted[(last + 2 * step) % sorted.length];
+    }
+    return next;
+}
+
+/**
+ * Restore and raise every given window, then focus the topmost one.
+ * Windows are raised from the bottom of the stack upwards so their relative order is kept
+ * and the match that was already highest ends up on top with focus.
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients Windows to raise
+ */
+function raiseAllClients(clients) {
+    clients.sort(compareStackingOrder);
+    for (var i = 0; i < clients.length; i++) {
+        prepareClient(clients[i]);
+        clients[i].minimized = false;
+        workspace.raiseWindow(clients[i]);
+    }
+    setActiveClient(clients[clients.length - 1]);
+}
+
+/**
+ * Pair the name of the action taken with the window it targeted. The target has to be passed on
+ * explicitly: on X11 workspace.activeWindow only changes once KWin sees the focus event, so right
+ * after activating a window it is usually still the one that had focus before.
+ * @param {string} action Name of the action taken, reported back to the listener
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window|null} client Window the action targeted, if any
+ * @return {Object} The action as {action, client}
+ */
+function actionResult(action, client) {
+    return { action: action, client: client };
+}
+
+/**
+ * Activate, toggle, or raise the matching windows according to the options.
+ * When multiple windows match, cycles through them based on current focus state.
+ * @param {Object} options Settings rendered from the Go side
+ * @param {boolean} options.toggle If true, minimize the window if it's already active
+ * @param {boolean} options.shade If true, shade or unshade the window if it's already active
+ * @param {boolean} options.toggleBack If true, activate the window that was active before the last jump to
+ *     the matches if one of them is already active; see toggleBackClient
+ * @param {string} options.previousWindow Internal ID of that window, or empty
+ * @param {string} options.prefer Which match to pick when none is active: 'newest' or 'oldest'; see preferredClient
+ * @param {boolean} options.raiseAll If true, raise all matching windows together instead of cycling
+ * @param {boolean} options.rememberGeometry If true, save where a window is when toggling hides it
+ * @param {boolean} options.reverse If true, cycle down the stacking order instead of up
+ * @param {boolean} options.cycleSkipMinimized If true, cycling passes over minimized matches instead of restoring them
+ * @param {boolean} options.cycleCurrentScreen If true, cycling stays among the matches on the active window's screen
+ * @param {boolean} options.cycleSamePid If true, cycling stays among the matches owned by the active window's process
+ * @param {boolean} options.preferVisible If true, pick among the matches that are not minimized when none is active
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} matchingClients Non-empty list of matching windows
+ * @return {Object} The action taken and the window it targeted; see actionResult
+ */
+function activateMatchingClients(options, matchingClients) {
+    if (options.raiseAll) {
+        raiseAllClients(matchingClients);
+        return actionResult('raised-all', null);
+    }
+
+    var activeWindow = workspace.activeWindow;
+
+    if (options.index !== 0) {
+        var indexed = targetClient(options, matchingClients);
+        if (indexed === activeWindow) {
+            return actionResult('none', indexed);
+        }
+        setActiveClient(indexed);
+        return actionResult('activated', indexed);
+    }
+
+    if (matchingClients.length === 1) {
+        var client = matchingClients[0];
+        if (activeWindow !== client) {
+            setActiveClient(client);
+            return actionResult('activated', client);
+        }
+        if (options.toggleBack) {
+            var back = toggleBackClient(options, matchingClients);
+            if (back === null) {
+                return actionResult('none', client);
+            }
+            setActiveClient(back);
+            return actionResult('toggled-back', back);
+        }
+        if (options.shade) {
+            client.shade = !client.shade;
+            return actionResult(client.shade ? 'shaded' : 'unshaded', client);
+        }
+        if (options.toggle) {
+            if (options.rememberGeometry && !client.minimized) {
+                rememberClientGeometry(client);
+            }
+            client.minimized = !client.minimized;
+            return actionResult(client.minimized ? 'minimized' : 'restored', client);
+        }
+        return actionResult('none', client);
+    }
+
+    var activeIsMatching = false;
+    for (var j = 0; j < matchingClients.length; j++) {
+        if (activeWindow === matchingClients[j]) {
+            activeIsMatching = true;
+            break;
+        }
+    }
+
+    if (activeIsMatching && options.toggleBack) {
+        // With several matches the key still goes back rather than cycling, as long as there is
+        // somewhere to go back to.
+        var before = toggleBackClient(options, matchingClients);
+        if (before !== null) {
+            setActiveClient(before);
+            return actionResult('toggled-back', before);
+        }
+    }
+    if (activeIsMatching && options.cycleCurrentScreen) {
+        matchingClients = onSameScreen(matchingClients, activeWindow);
+    }
+    if (activeIsMatching && options.cycleSamePid) {
+        matchingClients = ofSameProcess(matchingClients, activeWindow);
+    }
+
+    if (options.persistentCycle) {
+        var remembered = persistentCycleNext(options, matchingClients, activeWindow, activeIsMatching);
+        if (remembered !== null) {
+            setActiveClient(remembered);
+            return actionResult(activeIsMatching ? 'cycled' : 'activated', remembered);
+        }
+    }
+
+    matchingClients.sort(compareCycleOrder);
+
+    if (activeIsMatching) {
+        var nextClient = nextInCycle(options, matchingClients, activeWindow);
+        if (nextClient === null) {
+            return actionResult('none', activeWindow);
+        }
+        if (options.reverse && options.cycleOrder !== 'caption') {
+            demoteClient(options, activeWindow);
+        }
+        setActiveClient(nextClient);
+        return actionResult('cycled', nextClient);
+    }
+    var pool = options.preferVisible ? visibleOrAll(matchingClients) : matchingClients;
+    if (options.cycleOrder === 'caption') {
+        // Only the cycling follows captions; the first press goes to the topmost (or bottom-most) match.
+        pool = pool.slice().sort(compareStackingOrder);
+    }
+    var chosen = preferredClient(options, pool);
+    setActiveClient(chosen);
+    return actionResult('activated', chosen);
+}
+
+/**
+ * Keep only the windows on the same screen as a given one.
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients Matching windows
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} active Window whose screen to keep to
+ * @return {Array<KWin::XdgToplevelWindow|KWin::X11Window>} Windows on that screen, in the same order
+ */
+function onSameScreen(clients, active) {
+    var here = [];
+    for (var i = 0; i < clients.length; i++) {
+        if (clients[i] === active || isSameOutput(clients[i].output, active.output)) {
+            here.push(clients[i]);
+        }
+    }
+    return here;
+}
+
+/**
+ * Keep only the windows owned by the same process as a given one.
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients Matching windows
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} active Window whose process to keep to
+ * @return {Array<KWin::XdgToplevelWindow|KWin::X11Window>} Windows of that process, in the same order
+ */
+function ofSameProcess(clients, active) {
+    var same = [];
+    for (var i = 0; i < clients.length; i++) {
+        if (clients[i] === active || clients[i].pid === active.pid) {
+            same.push(clients[i]);
+        }
+    }
+    return same;
+}
+
+/**
+ * Keep only the windows that are not minimized, unless all of them are.
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients Matching windows
+ * @return {Array<KWin::XdgToplevelWindow|KWin::X11Window>} Windows to pick from, in the same order
+ */
+function visibleOrAll(clients) {
+    var visible = [];
+    for (var i = 0; i < clients.length; i++) {
+        if (!clients[i].minimized) {
+            visible.push(clients[i]);
+        }
+    }
+    return visible.length > 0 ? visible : clients;
+}
+
+/**
+ * Find the window --toggle-back returns to from an active match.
+ * @param {Object} options Settings rendered from the Go side
+ * @param {string} options.previousWindow Internal ID of the window active before the last jump, or empty
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} matchingClients Matching windows
+ * @return {KWin::XdgToplevelWindow|KWin::X11Window|null} That window, or null if it no longer exists or
+ *     is one of the matches
+ */
+function toggleBackClient(options, matchingClients) {
+    var back = findWindowById(options.previousWindow);
+    return back !== null && matchingClients.indexOf(back) < 0 ? back : null;
+}
+
+/**
+ * Find a window by its internal ID.
+ * @param {string} id Internal ID as reported by KWin, or empty
+ * @return {KWin::XdgToplevelWindow|KWin::X11Window|null} The window, or null if it no longer exists
+ */
+function findWindowById(id) {
+    if (id === '') {
+        return null;
+    }
+    var all = workspace.windowList();
+    for (var i = 0; i < all.length; i++) {
+        if (String(all[i].internalId) === id) {
+            return all[i];
+        }
+    }
+    return null;
+}
+
+/**
+ * Keep only the windows that can be activated without switching desktops.
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients Matching windows
+ * @return {Array<KWin::XdgToplevelWindow|KWin::X11Window>} Windows on the current desktop
+ */
+function onCurrentDesktopOnly(clients) {
+    var here = [];
+    for (var i = 0; i < clients.length; i++) {
+        if (isOnCurrentDesktop(clients[i])) {
+            here.push(clients[i]);
+        }
+    }
+    return here;
+}
+
+/**
+ * Pick the newest of the matches, or the oldest with prefer set to oldest. They go by their order in
+ * clients, the top of the stack being the newest, or by when they were opened with preferOpened.
+ * @param {Object} options Settings rendered from the Go side
+ * @param {string} options.prefer 'newest' or 'oldest'
+ * @param {boolean} options.preferOpened If true, go by the opening times recorded in options.openTimes
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients Non-empty list of matching windows
+ * @return {KWin::XdgToplevelWindow|KWin::X11Window} The preferred window
+ */
+function preferredClient(options, clients) {
+    if (options.preferOpened) {
+        clients = clients.slice().sort(compareOpenTime(options.openTimes));
+    }
+    return options.prefer === 'oldest' ? clients[0] : clients[clients.length - 1];
+}
+
+/**
+ * Pick the window an action applies to: the newest match, or the oldest with prefer set to oldest.
+ * @param {Object} options Settings rendered from the Go side; see activateMatchingClients
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients Non-empty list of matching windows
+ * @return {KWin::XdgToplevelWindow|KWin::X11Window} Target window
+ */
+function targetClient(options, clients) {
+    clients.sort(compareCycleOrder);
+    if (options.index !== 0) {
+        return indexedClient(options.index, clients);
+    }
+    return preferredClient(options, clients);
+}
+
+/**
+ * Pick a match by its position in cycle order.
+ * @param {number} index 1-based position from the bottom of the stack, or negative to count from the top
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients Matches sorted by compareCycleOrder
+ * @return {KWin::XdgToplevelWindow|KWin::X11Window} The window at that position
+ * @throws {Error} If there are not that many matches
+ */
+function indexedClient(index, clients) {
+    var i = index > 0 ? index - 1 : clients.length + index;
+    if (i < 0 || i >= clients.length) {
+        throw new Error('no match number ' + index + ' among ' + clients.length);
+    }
+    return clients[i];
+}
+
+/**
+ * Flag the window that would have been activated as demanding attention, without focusing it.
+ * @param {Object} options Settings rendered from the Go side; see activateMatchingClients
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients Non-empty list of matching windows
+ */
+function demandAttention(options, clients) {
+    targetClient(options, clients).demandsAttention = true;
+}
+
+/**
+ * Raise a window to the top of the stacking order without giving it keyboard focus.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to raise
+ */
+function raiseClient(client) {
+    prepareClient(client);
+    client.minimized = false;
+    workspace.raiseWindow(client);
+}
+
+/**
+ * Workspace slots that quick-tile the active window, by --tile zone.
+ */
+var quickTileSlots = {
+    left: 'slotWindowQuickTileLeft',
+    right: 'slotWindowQuickTileRight',
+    top: 'slotWindowQuickTileTop',
+    bottom: 'slotWindowQuickTileBottom',
+    topleft: 'slotWindowQuickTileTopLeft',
+    topright: 'slotWindowQuickTileTopRight',
+    bottomleft: 'slotWindowQuickTileBottomLeft',
+    bottomright: 'slotWindowQuickTileBottomRight'
+};
+
+/**
+ * Compute one coordinate of a placement within the work area.
+ * @param {number} start Left or top edge of the work area
+ * @param {number} free Space the window leaves free along this axis
+ * @param {number} value Pixels from the start, or a percentage of the free space
+ * @param {boolean} percent If true, value is a percentage
+ * @return {number} Coordinate of the window's left or top edge
+ */
+function placeCoordinate(start, free, value, percent) {
+    return Math.round(start + (percent ? Math.max(free, 0) * value / 100 : value));
+}
+
+/**
+ * Move a window within the work area of its screen, keeping its size.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to move
+ * @param {Object} place Placement rendered from the Go side; see options.place
+ */
+function placeClient(client, place) {
+    var area = workspace.clientArea(KWin.MaximizeArea, client);
+    var geometry = client.frameGeometry;
+    client.frameGeometry = {
+        x: placeCoordinate(area.x, area.width - geometry.width, place.x, place.xPercent),
+        y: placeCoordinate(area.y, area.height - geometry.height, place.y, place.yPercent),
+        width: geometry.width,
+        height: geometry.height
+    };
+}
+
+/**
+ * Resize a window, keeping its top-left corner where it is.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to resize
+ * @param {Object} size Size rendered from the Go side; see options.size
+ */
+function resizeClient(client, size) {
+    var area = workspace.clientArea(KWin.MaximizeArea, client);
+    var geometry = client.frameGeometry;
+    client.frameGeometry = {
+        x: geometry.x,
+        y: geometry.y,
+        width: Math.round(size.widthPercent ? area.width * size.width / 100 : size.width),
+        height: Math.round(size.heightPercent ? area.height * size.height / 100 : size.height)
+    };
+}
+
+/**
+ * Apply the window adjustments requested alongside activation.
+ * @param {Object} options Settings rendered from the Go side; see activateMatchingClients
+ * @param {boolean} options.maximize If true, maximize the window
+ * @param {boolean} options.fullscreen If true, toggle the window's fullscreen state
+ * @param {boolean} options.pin If true, toggle whether the window is on all desktops
+ * @param {boolean} options.keepAbove If true, toggle whether the window is kept above others
+ * @param {number} options.opacity Opacity to give the window, from 0 to 1, or 0 to leave it unchanged
+ * @param {Object} options.size New size for the window, or null; width and height are pixels, or
+ *     percentages of the work area when widthPercent or heightPercent is set
+ * @param {Object} options.place Where to move the window within its screen's work area, or null;
+ *     x and y are pixels, or percentages of the free space when xPercent or yPercent is set
+ * @param {string} options.tile Quick-tile zone to snap the window into, or empty; see quickTileSlots
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window that was activated; the tile slots
+ *     act on the active window, so tiling waits until KWin has made it active
+ */
+function adjustClient(options, client) {
+    if (options.maximize) {
+        client.setMaximize(true, true);
+    }
+    if (options.fullscreen && client.fullScreenable !== false) {
+        client.fullScreen = !client.fullScreen;
+    }
+    if (options.pin) {
+        client.onAllDesktops = !client.onAllDesktops;
+    }
+    if (options.keepAbove) {
+        client.keepAbove = !client.keepAbove;
+    }
+    if (options.opacity > 0) {
+        client.opacity = options.opacity;
+    }
+    if (options.size && !geometryRestored) {
+        resizeClient(client, options.size);
+    }
+    if (options.place && !geometryRestored) {
+        placeClient(client, options.place);
+    }
+    if (options.tile) {
+        whenActive(client, function () {
+            workspace[quickTileSlots[options.tile]]();
+        });
+    }
+}
+
+/**
+ * Run a function once a window is the active one: right away if it already is, otherwise when
+ * KWin reports it activated, which on X11 only happens once the focus event arrives.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window that is being activated
+ * @param {function} callback Function to run
+ */
+function whenActive(client, callback) {
+    if (workspace.activeWindow === client) {
+        callback();
+        return;
+    }
+    var activated = workspace.windowActivated || workspace.clientActivated; // KWin 5 calls windows clients
+    var handler = function (active) {
+        if (active !== client) {
+            return;
+        }
+        activated.disconnect(handler);
+        callback();
+    };
+    activated.connect(handler);
+}
+
+/**
+ * Pulse a window's opacity a few times so it is easy to spot where focus went.
+ * Like activation retries this relies on QTimer; without it the window is not flashed.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to flash
+ * @param {number} steps Opacity changes left; even steps dim the window, odd ones restore it
+ * @param {number} interval Time between changes, in milliseconds
+ * @param {number} opacity Opacity to restore the window to, which is not its current one when
+ *     an earlier flash is still running
+ */
+function flashClient(client, steps, interval, opacity) {
+    if (steps <= 0 || typeof QTimer === 'undefined') {
+        return;
+    }
+    client.opacity = opacity * 0.4;
+    var timer = new QTimer();
+    timer.singleShot = true;
+    timer.timeout.connect(function () {
+        client.opacity = opacity;
+        var next = new QTimer();
+        next.singleShot = true;
+        next.timeout.connect(function () {
+            flashClient(client, steps - 2, interval, opacity);
+        });
+        next.start(interval);
+    });
+    timer.start(interval);
+}
+
+/**
+ * Minimize every normal window on the current desktop and activity except the given one
+ * and its dialogs.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} keep Window to leave alone
+ */
+function minimizeOthers(keep) {
+    var all = workspace.windowList();
+    for (var i = 0; i < all.length; i++) {
+        var client = all[i];
+        if (client === keep || client.transientFor === keep || client.minimized || !client.minimizable) {
+            continue;
+        }
+        if (isSpecialWindow(client) || !isOnCurrentDesktop(client)) {
+            continue;
+        }
+        if (workspace.currentActivity !== undefined && !isOnActivity(client, String(workspace.currentActivity))) {
+            continue;
+        }
+        client.minimized = true;
+    }
+}
+
+/**
+ * Exchange the positions and sizes of two windows. Frame geometry is in global coordinates,
+ * so windows on different screens trade screens as well.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} a First window
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} b Second window
+ */
+function swapGeometry(a, b) {
+    var ga = a.frameGeometry;
+    var gb = b.frameGeometry;
+    a.frameGeometry = { x: gb.x, y: gb.y, width: gb.width, height: gb.height };
+    b.frameGeometry = { x: ga.x, y: ga.y, width: ga.width, height: ga.height };
+}
+
+/**
+ * Apply an action other than activation to the matching windows.
+ * @param {Object} options Settings rendered from the Go side; see activateMatchingClients
+ * @param {string} options.action Action to apply: raise, urgent, close, close-all, hide, send-to-desktop,
+ *     own-desktop, or swap
+ * @param {string} options.sendToDesktop Desktop number or name for send-to-desktop, desktop name for own-desktop
+ * @param {boolean} options.follow If true, switch to the desktop a window was sent to and activate it
+ * @param {Array<KWin::XdgToplevelWindow|KWin::X11Window>} clients Non-empty list of matching windows
+ * @return {Object} The action taken and the window it targeted; see actionResult
+ */
+function applyAction(options, clients) {
+    var client = targetClient(options, clients);
+    switch (options.action) {
+    case 'raise':
+        raiseClient(client);
+        return actionResult('raised', client);
+    case 'urgent':
+        client.demandsAttention = true;
+        return actionResult('demanded-attention', client);
+    case 'close':
+        client.closeWindow();
+        return actionResult('closed', client);
+    case 'close-all':
+        for (var i = 0; i < clients.length; i++) {
+            clients[i].closeWindow();
+        }
+        return actionResult('closed-all', client);
+    case 'hide':
+        var hidden = false;
+        for (var j = 0; j < clients.length; j++) {
+            if (!clients[j].minimized) {
+                clients[j].minimized = true;
+                hidden = true;
+            }
+        }
+        return actionResult(hidden ? 'minimized' : 'none', null);
+    case 'send-to-desktop':
+        var desktop = findDesktop(options.sendToDesktop);
+        moveToDesktop(client, desktop);
+        if (options.follow) {
+            workspace.currentDesktop = desktop;
+            setActiveClient(client);
+        }
+        return actionResult('sent-to-desktop', client);
+    case 'own-desktop':
+        var own = findOrCreateDesktop(options.sendToDesktop);
+        for (var k = 0; k < clients.length; k++) {
+            moveToDesktop(clients[k], own);
+        }
+        workspace.currentDesktop = own;
+        setActiveClient(client);
+        return actionResult('moved-to-own-desktop', client);
+    case 'swap':
+        var active = workspace.activeWindow;
+        if (!active || active === client) {
+            return actionResult('none', client);
+        }
+        swapGeometry(client, active);
+        setActiveClient(client);
+        return actionResult('swapped', client);
+    }
+    return actionResult('none', null);
+}
+
+/**
+ * Send the action taken and the number of matching windows to the jumpkwapp listener.
+ * Does nothing when no listener address was rendered into the script.
+ * @param {Object} options Listener settings; see notifyListener
+ * @param {string} action Name of the action taken
+ * @param {number} matched Number of matching windows
+ * @param {Object} [extra] Further fields for the Go side: pointer, where the pointer should be warped to
+ *     as {x, y}, and windows, the internal IDs of the windows to show in the overview
+ */
+function reportOutcome(options, action, matched, extra) {
+    if (options.dbusAddr) {
+        var outcome = { action: action, matched: matched, geometry: geometryUpdates };
+        for (var key in extra) {
+            outcome[key] = extra[key];
+        }
+        callDBus(options.dbusAddr, options.listenerPath, options.listenerInterface, 'ReportOutcome',
+            JSON.stringify(outcome));
+    }
+}
+
+/**
+ * Copy the options with the filters that cannot apply to a window that was just launched turned off.
+ * The --cmdline filter was resolved to process IDs before the launch, so the new process is never among
+ * them; the opening time of the new window may not be recorded yet for --opened-within; and --other
+ * would skip the window once KWin has focused it.
+ * @param {Object} options Settings rendered from the Go side; see findMatchingClients
+ * @return {Object} Options to recognise the launched window by
+ */
+function launchedClientOptions(options) {
+    var copy = {};
+    for (var key in options) {
+        if (options.hasOwnProperty(key)) {
+            copy[key] = options[key];
+        }
+    }
+    copy.other = false;
+    copy.restrictPids = false;
+    copy.pids = [];
+    copy.openedAfter = 0;
+    return copy;
+}
+
+/**
+ * Activate and adjust the first matching window to appear, once jumpkwapp has launched the command.
+ * Focus stealing prevention keeps a launched window from taking focus unless the launch carried an
+ * activation token, which jumpkwapp cannot obtain itself, so the script activates the window instead.
+ * With focusAfterLaunch jumpkwapp waits for the report and then stops the script. Otherwise jumpkwapp
+ * exits right after launching, so the script unloads itself after options.launchTimeout; see unloadSelf.
+ * @param {Object} options Settings rendered from the Go side; see findMatchingClients and adjustClient
+ * @param {boolean} options.focusAfterLaunch If true, report the activation to the waiting jumpkwapp
+ * @param {number} options.launchTimeout How long to wait for the window without jumpkwapp, in milliseconds
+ */
+function awaitLaunchedClient(options) {
+    var added = workspace.windowAdded || workspace.clientAdded; // KWin 5 calls windows clients
+    var launchedOptions = launchedClientOptions(options);
+    var timer = null;
+    var waiting = true;
+    var handler = function (client) {
+        if (findMatchingClients(launchedOptions).indexOf(client) < 0) {
+            return;
+        }
+        added.disconnect(handler);
+        waiting = false;
+        setActiveClient(client);
+        adjustClient(options, client);
+        if (options.focusAfterLaunch) {
+            reportOutcome(options, 'launched-focused', 1);
+            return;
+        }
+        if (timer === null) {
+            unloadSelf(options);
+        }
+    };
+    added.connect(handler);
+    if (!options.focusAfterLaunch && typeof QTimer !== 'undefined') {
+        timer = new QTimer();
+        timer.singleShot = true;
+        // Tiling waits for KWin to activate the new window, so the script is left loaded until the
+        // timeout even once the window has appeared.
+        timer.timeout.connect(function () {
+            if (waiting) {
+                added.disconnect(handler);
+            }
+            unloadSelf(options);
+        });
+        timer.start(options.launchTimeout);
+    }
+}
+
+/**
+ * Ask KWin to unload this script, for when it outlives the jumpkwapp run that loaded it.
+ * @param {Object} options Settings rendered from the Go side
+ * @param {string} options.pluginName Name jumpkwapp loaded the script under
+ */
+function unloadSelf(options) {
+    callDBus('org.kde.KWin', '/Scripting', 'org.kde.kwin.Scripting', 'unloadScript', options.pluginName);
+}
+
+/**
+ * Compute the center of a window in global coordinates.
+ * @param {KWin::XdgToplevelWindow|KWin::X11Window} client Window to inspect
+ * @return {Object} Center point as {x, y}
+ */
+function clientCenter(client) {
+    var geometry = client.frameGeometry;
+    return {
+        x: Math.round(geometry.x + geometry.width / 2),
+        y: Math.round(geometry.y + geometry.height / 2)
+    };
+}
+
+/**
+ * Activate a window matching the specified filters, or signal via D-Bus if no match found.
+ * @param {Object} options Settings rendered from the Go side; see findMatchingClients,
+ *     activateMatchingClients and notifyListener
+ * @param {number} options.activateRetries Times to re-assert activation if it does not take effect
+ * @param {number} options.activateRetryDelay Delay between activation retries, in milliseconds
+ * @param {boolean} options.preferCurrentScreen If true, activate and cycle through matches on the focused screen
+ *     before those on other screens; see preferCurrentScreen
+ * @param {boolean} options.includeDialogs If true, act on the topmost dialog of a matched window instead of the window
+ * @param {boolean} options.summon If true, move the window to the current desktop instead of switching to its desktop
+ * @param {boolean} options.summonToScreen If true, also move a summoned window to the focused screen
+ * @param {string} options.sendToScreen Screen name or index to move the window to before activating it
+ * @param {boolean} options.toCurrentScreen If true, move the window to the focused screen before activating it
+ * @param {number} options.index If not 0, activate this match in cycle order instead of cycling; see indexedClient
+ * @param {boolean} options.persistentCycle If true, cycle from the match activated last time with the same filters
+ * @param {string} options.cycleOrder Order to cycle through matches in: 'stacking', 'mru' (by when jumpkwapp last
+ *     activated them) or 'caption'; see compareCycleOrder
+ * @param {Object} options.activationTimes Last activation time in milliseconds, by window internal ID
+ * @param {Object} options.savedGeometry Geometry saved by earlier toggles, by window internal ID
+ * @param {string} options.sendToActivity Id of the activity to move the window to before activating it
+ * @param {boolean} options.noDesktopSwitch If true, never switch desktops: only windows on the current desktop
+ *     are activated, and a match elsewhere is flagged as demanding attention instead
+ * @param {boolean} options.expose If true and several windows match, show them in KWin's window overview instead
+ *     of activating one
+ * @param {boolean} options.awaitLaunched If true and nothing matches, activate and adjust the first matching
+ *     window to appear after the command is launched; see awaitLaunchedClient
+ * @param {boolean} options.pick If true and several windows match, report them so one can be picked in a menu
+ *     instead of activating one
+ * @param {boolean} options.flash If true, briefly pulse the opacity of the activated window
+ * @param {number} options.flashSteps Number of opacity changes when flashing
+ * @param {Object} options.flashOpacities Opacity to restore windows still being flashed by an earlier run to,
+ *     by window internal ID
+ * @param {number} options.flashInterval Time between opacity changes when flashing, in milliseconds
+ * @param {boolean} options.warpPointer If true, report the center of the activated window so the pointer can be
+ *     warped there
+ * @param {boolean} options.solo If true, minimize the other windows on the current desktop once a match is active
+ */
+function kwinActivateClient(options) {
+    activationRetries = options.activateRetries;
+    activationRetryDelay = options.activateRetryDelay;
+    summonWindows = options.summon;
+    summonToScreen = options.summonToScreen;
+    savedGeometry = options.savedGeometry;
+    if (options.cycleOrder === 'mru') {
+        compareCycleOrder = compareRecency(options.activationTimes);
+    } else if (options.cycleOrder === 'caption') {
+        compareCycleOrder = compareCaption;
+    }
+    targetActivity = options.sendToActivity;
+    if (options.sendToScreen) {
+        targetScreen = findScreen(options.sendToScreen);
+    } else if (options.toCurrentScreen) {
+        targetScreen = workspace.activeScreen || null;
+    }
+    var previous = workspace.activeWindow;
+    var matchingClients = findMatchingClients(options);
+
+    if (matchingClients.length === 0) {
+        // There is nothing to close or hide, so there is no point launching anything either.
+        var removing = options.action === 'close' || options.action === 'close-all' || options.action === 'hide';
+        notifyListener(options, removing ? 'false' : 'true');
+        reportOutcome(options, 'no-match', 0);
+        if (options.awaitLaunched && !removing) {
+            awaitLaunchedClient(options);
+        }
+        return;
+    }
+
+    notifyListener(options, 'false');
+    var candidates = options.preferCurrentScreen ? preferCurrentScreen(matchingClients) : matchingClients;
+    if (options.includeDialogs) {
+        candidates = withDialogs(candidates);
+    }
+    if (options.noDesktopSwitch) {
+        var here = onCurrentDesktopOnly(candidates);
+        if (here.length === 0) {
+            demandAttention(options, candidates);
+            reportOutcome(options, 'demanded-attention', matchingClients.length);
+            return;
+        }
+        candidates = here;
+    }
+    if (options.expose && candidates.length > 1) {
+        // The overview is opened from the Go side, which can pass KWin the string list it expects.
+        var ids = [];
+        for (var i = 0; i < candidates.length; i++) {
+            ids.push(String(candidates[i].internalId));
+        }
+        reportOutcome(options, 'exposed', matchingClients.length, { windows: ids });
+        return;
+    }
+    if (options.pick && candidates.length > 1) {
+        // The user picks in a menu, after which jumpkwapp activates the choice by its internal ID.
+        reportOutcome(options, 'picking', matchingClients.length, { choices: describeChoices(candidates) });
+        return;
+    }
+    var result = options.action ? applyAction(options, candidates) : activateMatchingClients(options, candidates);
+    var action = result.action;
+    var target = result.client;
+    var extra = {};
+    if (target && (action === 'activated' || action === 'cycled' || action === 'none')) {
+        adjustClient(options, target);
+        if (options.solo) {
+            minimizeOthers(target);
+        }
+        if (options.flash) {
+            var targetId = String(target.internalId);
+            var opacity = options.flashOpacities.hasOwnProperty(targetId) ? options.flashOpacities[targetId] : target.opacity;
+            flashClient(target, options.flashSteps, options.flashInterval, opacity);
+            extra.flashed = {};
+            extra.flashed[targetId] = opacity;
+        }
+        if (options.cycleOrder === 'mru' || options.persistentCycle) {
+            extra.activated = String(target.internalId);
+        }
+        if (demotedWindow) {
+            extra.demoted = demotedWindow;
+        }
+        if (options.warpPointer) {
+            extra.pointer = clientCenter(target);
+        }
+    }
+    if (options.toggleBack && action === 'activated' && previous && matchingClients.indexOf(previous) < 0) {
+        extra.previous = String(previous.internalId);
+    }
+    reportOutcome(options, action, matchingClients.length, extra);
+}
+
+var options = {
+    classNames: [{{range $i, $c := .ClassNames}}{{if $i}}, {{end}}'{{$c}}'{{end}}],
+    smartClass: {{if .SmartClass}}true{{else}}false{{end}},
+    ignoreCase: {{if .IgnoreCase}}true{{else}}false{{end}},
+    captionPattern: '{{.CaptionPattern}}',
+    captionFlags: '{{.CaptionFlags}}',
+    captionFullMatch: {{if .CaptionFullMatch}}true{{else}}false{{end}},
+    titleSubstring: '{{.TitleSubstring}}',
+    captionExact: '{{.CaptionExact}}',
+    classRegex: '{{.ClassRegex}}',
+    allFilters: {{if .AllFilters}}true{{else}}false{{end}},
+    fuzzy: '{{.Fuzzy}}',
+    captionFirst: {{if .CaptionFirst}}true{{else}}false{{end}},
+    windowId: '{{.WindowID}}',
+    pid: {{.PID}},
+    restrictPids: {{if .RestrictPIDs}}true{{else}}false{{end}},
+    pids: [{{range $i, $p := .PIDs}}{{if $i}}, {{end}}{{$p}}{{end}}],
+    openedAfter: {{.OpenedAfter}},
+    openTimes: {{.OpenTimes}},
+    desktopFile: '{{.DesktopFile}}',
+    resourceName: '{{.ResourceName}}',
+    role: '{{.Role}}',
+    match: {{if .MatchExpr}}function (c) { return {{.MatchExpr}}; }{{else}}null{{end}},
+    excludeClasses: [{{range $i, $c := .ExcludeClasses}}{{if $i}}, {{end}}'{{$c}}'{{end}}],
+    excludeCaptions: [{{range $i, $c := .ExcludeCaptions}}{{if $i}}, {{end}}'{{$c}}'{{end}}],
+    excludeClassRegexes: [{{range $i, $c := .ExcludeClassRegexes}}{{if $i}}, {{end}}'{{$c}}'{{end}}],
+    toggle: {{if .Toggle}}true{{else}}false{{end}},
+    shade: {{if .Shade}}true{{else}}false{{end}},
+    currentDesktopOnly: {{if .CurrentDesktopOnly}}true{{else}}false{{end}},
+    desktop: '{{.Desktop}}',
+    activity: '{{.Activity}}',
+    currentActivity: {{if .CurrentActivity}}true{{else}}false{{end}},
+    screen: '{{.Screen}}',
+    currentScreen: {{if .CurrentScreen}}true{{else}}false{{end}},
+    includeSkipTaskbar: {{if .IncludeSkipTaskbar}}true{{else}}false{{end}},
+    other: {{if .Other}}true{{else}}false{{end}},
+    includeSpecial: {{if .IncludeSpecial}}true{{else}}false{{end}},
+    visibleOnly: {{if .VisibleOnly}}true{{else}}false{{end}},
+    minimizedOnly: {{if .MinimizedOnly}}true{{else}}false{{end}},
+    skipMinimized: {{if .SkipMinimized}}true{{else}}false{{end}},
+    state: '{{.State}}',
+    prefer: '{{.Prefer}}',
+    preferOpened: {{if .PreferOpened}}true{{else}}false{{end}},
+    preferCurrentScreen: {{if .PreferCurrentScreen}}true{{else}}false{{end}},
+    summon: {{if .Summon}}true{{else}}false{{end}},
+    summonToScreen: {{if .SummonToScreen}}true{{else}}false{{end}},
+    sendToScreen: '{{.SendToScreen}}',
+    toCurrentScreen: {{if .ToCurrentScreen}}true{{else}}false{{end}},
+    sendToActivity: '{{.SendToActivity}}',
+    action: '{{.Action}}',
+    sendToDesktop: '{{.SendToDesktop}}',
+    follow: {{if .Follow}}true{{else}}false{{end}},
+    maximize: {{if .Maximize}}true{{else}}false{{end}},
+    fullscreen: {{if .Fullscreen}}true{{else}}false{{end}},
+    pin: {{if .Pin}}true{{else}}false{{end}},
+    keepAbove: {{if .KeepAbove}}true{{else}}false{{end}},
+    opacity: {{.Opacity}},
+    size: {{with .Size}}{width: {{.Width.Value}}, widthPercent: {{if .Width.Percent}}true{{else}}false{{end}}, height: {{.Height.Value}}, heightPercent: {{if .Height.Percent}}true{{else}}false{{end}}}{{else}}null{{end}},
+    place: {{with .Place}}{x: {{.X.Value}}, xPercent: {{if .X.Percent}}true{{else}}false{{end}}, y: {{.Y.Value}}, yPercent: {{if .Y.Percent}}true{{else}}false{{end}}}{{else}}null{{end}},
+    tile: '{{.Tile}}',
+    expose: {{if .Expose}}true{{else}}false{{end}},
+    pick: {{if .Pick}}true{{else}}false{{end}},
+    flash: {{if .Flash}}true{{else}}false{{end}},
+    flashSteps: {{.FlashSteps}},
+    flashInterval: {{.FlashInterval}},
+    flashOpacities: {{.FlashOpacities}},
+    warpPointer: {{if .WarpPointer}}true{{else}}false{{end}},
+    solo: {{if .Solo}}true{{else}}false{{end}},
+    noDesktopSwitch: {{if .NoDesktopSwitch}}true{{else}}false{{end}},
+    includeDialogs: {{if .IncludeDialogs}}true{{else}}false{{end}},
+    raiseAll: {{if .RaiseAll}}true{{else}}false{{end}},
+    rememberGeometry: {{if .RememberGeometry}}true{{else}}false{{end}},
+    savedGeometry: {{.SavedGeometry}},
+    index: {{.Index}},
+    persistentCycle: {{if .PersistentCycle}}true{{else}}false{{end}},
+    lastCycled: '{{.LastCycled}}',
+    toggleBack: {{if .ToggleBack}}true{{else}}false{{end}},
+    focusAfterLaunch: {{if .FocusAfterLaunch}}true{{else}}false{{end}},
+    awaitLaunched: {{if .AwaitLaunched}}true{{else}}false{{end}},
+    launchTimeout: {{.LaunchTimeout}},
+    pluginName: '{{.PluginName}}',
+    previousWindow: '{{.PreviousWindow}}',
+    cycleOrder: '{{.CycleOrder}}',
+    activationTimes: {{.ActivationTimes}},
+    reverse: {{if .Reverse}}true{{else}}false{{end}},
+    cycleSkipMinimized: {{if .CycleSkipMinimized}}true{{else}}false{{end}},
+    cycleCurrentScreen: {{if .CycleCurrentScreen}}true{{else}}false{{end}},
+    cycleSamePid: {{if .CycleSamePID}}true{{else}}false{{end}},
+    preferVisible: {{if .PreferVisible}}true{{else}}false{{end}},
+    activateRetries: {{.ActivateRetries}},
+    activateRetryDelay: {{.ActivateRetryDelay}},
+    dbusAddr: '{{.DBusAddress}}',
+    listenerPath: '{{.ListenerPath}}',
+    listenerInterface: '{{.ListenerInterface}}'
+};
+
+try {
+    kwinActivateClient(options);
+} catch (e) {
+    print('jumpkwapp: ' + e);
+    notifyListener(options, 'error');
+    reportOutcome(options, 'error', 0);
+}
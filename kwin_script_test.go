@@ -0,0 +1,3063 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// fakeWindow describes a window of the fake workspace in testdata/kwin.js.
+// Unset fields take the harness defaults: a normal window on the first
+// desktop and screen, stacked above the windows listed before it.
+type fakeWindow struct {
+	Caption       string   `json:"caption"`
+	ResourceClass string   `json:"resourceClass,omitempty"`
+	ResourceName  string   `json:"resourceName,omitempty"`
+	InternalID    string   `json:"internalId,omitempty"`
+	StackingOrder int      `json:"stackingOrder,omitempty"`
+	PID           int      `json:"pid,omitempty"`
+	DesktopFile   string   `json:"desktopFileName,omitempty"`
+	Role          string   `json:"windowRole,omitempty"`
+	Minimized     bool     `json:"minimized,omitempty"`
+	SkipTaskbar   bool     `json:"skipTaskbar,omitempty"`
+	OnAllDesktops bool     `json:"onAllDesktops,omitempty"`
+	Desktops      []string `json:"desktops,omitempty"`
+	Output        string   `json:"output,omitempty"`
+	Activities    []string `json:"activities,omitempty"`
+	FullScreen    bool     `json:"fullScreen,omitempty"`
+	// FullScreenable is left undefined in the window when nil.
+	FullScreenable *bool `json:"fullScreenable,omitempty"`
+	KeepAbove      bool  `json:"keepAbove,omitempty"`
+	// Opacity is left at KWin's default of 1 when zero.
+	Opacity       float64 `json:"opacity,omitempty"`
+	MaximizeMode  *int    `json:"maximizeMode,omitempty"`
+	FrameGeometry *rect   `json:"frameGeometry,omitempty"`
+	SpecialWindow bool    `json:"specialWindow,omitempty"`
+	DesktopWindow bool    `json:"desktopWindow,omitempty"`
+	Dock          bool    `json:"dock,omitempty"`
+	// TransientFor is the caption of the window this one is a dialog of.
+	TransientFor string `json:"transientFor,omitempty"`
+	// AddedAt is when a window in kwinFixture.Added opens, in milliseconds
+	// on the simulated clock.
+	AddedAt int `json:"addedAt,omitempty"`
+}
+
+// rect is a KWin geometry such as frameGeometry.
+type rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// kwinFixture is the workspace the script runs against.
+type kwinFixture struct {
+	Windows        []fakeWindow `json:"windows"`
+	Active         string       `json:"active,omitempty"`
+	CurrentDesktop string       `json:"currentDesktop,omitempty"`
+	ActiveScreen   string       `json:"activeScreen,omitempty"`
+	// CurrentActivity is left undefined in the workspace when empty, as on
+	// KWin builds without activities.
+	CurrentActivity string `json:"currentActivity,omitempty"`
+	// DesktopCopies gives each window its own copies of the desktop objects.
+	DesktopCopies bool `json:"desktopCopies,omitempty"`
+	// IgnoredActivations makes KWin drop that many activation requests.
+	IgnoredActivations int `json:"ignoredActivations,omitempty"`
+	// DeferredActivation makes activation requests take effect only after
+	// the script returns, as with X11 focus events.
+	DeferredActivation bool `json:"deferredActivation,omitempty"`
+	// Added are windows opened one by one after the script has run.
+	Added []fakeWindow `json:"added,omitempty"`
+	// FocusAdded has KWin focus each added window as it opens.
+	FocusAdded bool `json:"focusAdded,omitempty"`
+}
+
+type dbusCall struct {
+	Service   string   `json:"service"`
+	Path      string   `json:"path"`
+	Interface string   `json:"interface"`
+	Method    string   `json:"method"`
+	Args      []string `json:"args"`
+}
+
+type windowState struct {
+	Minimized     bool     `json:"minimized"`
+	StackingOrder int      `json:"stackingOrder"`
+	Desktops      []string `json:"desktops"`
+	Output        string   `json:"output"`
+	Activities    []string `json:"activities"`
+	FullScreen    bool     `json:"fullScreen"`
+	KeepAbove     bool     `json:"keepAbove"`
+	OnAllDesktops bool     `json:"onAllDesktops"`
+	Opacity       float64  `json:"opacity"`
+	Shade         bool     `json:"shade"`
+	FrameGeometry rect     `json:"frameGeometry"`
+	// DemandsAttention is set when the script flagged the window instead of
+	// activating it.
+	DemandsAttention bool `json:"demandsAttention"`
+}
+
+// kwinResult is what the script did to the fake workspace.
+type kwinResult struct {
+	Log     [][]any                `json:"log"`
+	DBus    []dbusCall             `json:"dbus"`
+	Active  string                 `json:"active"`
+	Windows map[string]windowState `json:"windows"`
+	// CurrentDesktop is the name of the desktop shown when the script ended.
+	CurrentDesktop string `json:"currentDesktop"`
+}
+
+// calls lists the captions passed to the harness calls named op, in order.
+func (r kwinResult) calls(op string) []string {
+	var captions []string
+	for _, entry := range r.Log {
+		if entry[0] == op {
+			captions = append(captions, entry[1].(string))
+		}
+	}
+	return captions
+}
+
+// activated lists the captions of the windows the script activated, in order.
+func (r kwinResult) activated() []string {
+	return r.calls("activate")
+}
+
+// shouldLaunch returns the decision the script sent to the listener, or ""
+// if it sent none.
+func (r kwinResult) shouldLaunch() string {
+	for _, call := range r.DBus {
+		if call.Method == "ShouldLaunch" {
+			return call.Args[0]
+		}
+	}
+	return ""
+}
+
+// outcome returns what the script reported through ReportOutcome.
+func (r kwinResult) outcome(t *testing.T) scriptOutcome {
+	t.Helper()
+	for _, call := range r.DBus {
+		if call.Method == "ReportOutcome" {
+			var outcome scriptOutcome
+			if err := json.Unmarshal([]byte(call.Args[0]), &outcome); err != nil {
+				t.Fatalf("parse outcome: %v", err)
+			}
+			return outcome
+		}
+	}
+	t.Fatal("script reported no outcome")
+	return scriptOutcome{}
+}
+
+// actions lists the actions the script reported through ReportOutcome, in
+// order; scripts that keep running after their first report send more.
+func (r kwinResult) actions(t *testing.T) []string {
+	t.Helper()
+	var actions []string
+	for _, call := range r.DBus {
+		if call.Method == "ReportOutcome" {
+			var outcome scriptOutcome
+			if err := json.Unmarshal([]byte(call.Args[0]), &outcome); err != nil {
+				t.Fatalf("parse outcome: %v", err)
+			}
+			actions = append(actions, outcome.Action)
+		}
+	}
+	return actions
+}
+
+// classNames turns a single -f value into scriptParams.ClassNames, leaving
+// the class filter unset when value is empty.
+func classNames(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
+
+// runKWinScript renders the script for params and runs it against the fake
+// workspace described by fixture.
+func runKWinScript(t *testing.T, params scriptParams, fixture kwinFixture) kwinResult {
+	t.Helper()
+	return runScript(t, render(t, params), fixture)
+}
+
+// runScript runs a rendered script with node against the fake workspace
+// described by fixture.
+func runScript(t *testing.T, script string, fixture kwinFixture) kwinResult {
+	t.Helper()
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	dir := t.TempDir()
+	scriptFile := filepath.Join(dir, "script.js")
+	if err := os.WriteFile(scriptFile, []byte(script), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixtureFile := filepath.Join(dir, "fixture.json")
+	if err := os.WriteFile(fixtureFile, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(node, filepath.Join("testdata", "kwin.js"), scriptFile, fixtureFile).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			t.Fatalf("run script: %v\n%s", err, exitErr.Stderr)
+		}
+		t.Fatalf("run script: %v", err)
+	}
+	var result kwinResult
+	if err := json.Unmarshal(out, &result); err != nil {
+		t.Fatalf("parse harness output: %v\n%s", err, out)
+	}
+	return result
+}
+
+func TestScriptWindowID(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "shell", ResourceClass: "konsole", InternalID: "{1}"},
+			{Caption: "logs", ResourceClass: "konsole", InternalID: "{2}"},
+			{Caption: "mail", ResourceClass: "thunderbird", InternalID: "{3}"},
+		},
+		Active: "logs",
+	}
+	tests := []struct {
+		name       string
+		windowID   string
+		wantActive []string
+		wantLaunch string
+	}{
+		{name: "activates the window with that ID", windowID: "{1}", wantActive: []string{"shell"}, wantLaunch: "false"},
+		{name: "ignores the class filter", windowID: "{3}", wantActive: []string{"mail"}, wantLaunch: "false"},
+		{name: "unknown ID asks for a launch", windowID: "{4}", wantLaunch: "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.WindowID = tt.windowID
+			result := runKWinScript(t, params, fixture)
+			if got := result.activated(); !reflect.DeepEqual(got, tt.wantActive) {
+				t.Errorf("activated %q, want %q", got, tt.wantActive)
+			}
+			if got := result.shouldLaunch(); got != tt.wantLaunch {
+				t.Errorf("ShouldLaunch(%q), want %q", got, tt.wantLaunch)
+			}
+		})
+	}
+}
+
+func TestScriptStackingTies(t *testing.T) {
+	windows := []fakeWindow{
+		{Caption: "second", ResourceClass: "konsole", InternalID: "{2}", StackingOrder: 3},
+		{Caption: "first", ResourceClass: "konsole", InternalID: "{1}", StackingOrder: 3},
+		{Caption: "mail", ResourceClass: "thunderbird", StackingOrder: 4},
+	}
+	tests := []struct {
+		active string
+		want   string
+	}{
+		{active: "mail", want: "second"},
+		{active: "second", want: "first"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.active, func(t *testing.T) {
+			result := runKWinScript(t, testParams(), kwinFixture{Windows: windows, Active: tt.active})
+			if got := result.activated(); !reflect.DeepEqual(got, []string{tt.want}) {
+				t.Errorf("activated %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptListenerNames(t *testing.T) {
+	params := testParams()
+	params.ListenerPath = "/org/example/hotkeys/Listener"
+	params.ListenerInterface = "org.example.hotkeys.Listener"
+	result := runKWinScript(t, params, kwinFixture{})
+	if len(result.DBus) == 0 {
+		t.Fatal("script made no D-Bus calls")
+	}
+	for _, call := range result.DBus {
+		if call.Service != ":1.42" || call.Path != params.ListenerPath || call.Interface != params.ListenerInterface {
+			t.Errorf("%s sent to %s %s %s", call.Method, call.Service, call.Path, call.Interface)
+		}
+	}
+}
+
+func TestScriptRaiseAll(t *testing.T) {
+	params := testParams()
+	params.RaiseAll = true
+	result := runKWinScript(t, params, kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "top", ResourceClass: "konsole", StackingOrder: 5},
+			{Caption: "bottom", ResourceClass: "konsole", StackingOrder: 1, Minimized: true},
+			{Caption: "middle", ResourceClass: "konsole", StackingOrder: 3},
+			{Caption: "mail", ResourceClass: "thunderbird", StackingOrder: 4},
+		},
+		Active: "mail",
+	})
+	if got, want := result.calls("raise"), []string{"bottom", "middle", "top"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("raised %q, want %q", got, want)
+	}
+	if got, want := result.activated(), []string{"top"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("activated %q, want %q", got, want)
+	}
+	if result.Windows["bottom"].Minimized {
+		t.Error("bottom is still minimized")
+	}
+}
+
+func TestScriptTitle(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "Inbox (3) - Mail", ResourceClass: "thunderbird"},
+			{Caption: "C++ notes", ResourceClass: "kate"},
+			{Caption: "shell", ResourceClass: "konsole"},
+		},
+		Active: "shell",
+	}
+	tests := []struct {
+		title string
+		want  []string
+	}{
+		{title: "inbox (3)", want: []string{"Inbox (3) - Mail"}},
+		{title: "c++", want: []string{"C++ notes"}},
+		{title: "inbox.*mail"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			params := testParams()
+			params.ClassNames = nil
+			params.TitleSubstring = tt.title
+			result := runKWinScript(t, params, fixture)
+			if got := result.activated(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("activated %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptCaptionExact(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "Inbox (3) - Mail", ResourceClass: "thunderbird"},
+			{Caption: "Inbox", ResourceClass: "thunderbird"},
+			{Caption: "shell", ResourceClass: "konsole"},
+		},
+		Active: "shell",
+	}
+	tests := []struct {
+		caption string
+		want    []string
+	}{
+		{caption: "Inbox", want: []string{"Inbox"}},
+		{caption: "Inbox (3) - Mail", want: []string{"Inbox (3) - Mail"}},
+		{caption: "inbox"},
+		{caption: "Inbox.*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			params := testParams()
+			params.ClassNames = nil
+			params.CaptionExact = tt.caption
+			result := runKWinScript(t, params, fixture)
+			if got := result.activated(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("activated %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptFuzzy(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "Mozilla Firefox", ResourceClass: "firefox"},
+			{Caption: "Profile editor", ResourceClass: "kate"},
+			{Caption: "shell", ResourceClass: "konsole"},
+		},
+		Active: "shell",
+	}
+	tests := []struct {
+		fuzzy string
+		want  scriptOutcome
+	}{
+		{fuzzy: "fi", want: scriptOutcome{Action: "activated", Matched: 1}},
+		{fuzzy: "FFX", want: scriptOutcome{Action: "activated", Matched: 1}},
+		{fuzzy: "ksl", want: scriptOutcome{Action: "none", Matched: 1}},
+		{fuzzy: "ed", want: scriptOutcome{Action: "activated", Matched: 1}},
+		{fuzzy: "xff", want: scriptOutcome{Action: "no-match"}},
+	}
+	wantActivated := map[string][]string{
+		"fi":  {"Mozilla Firefox"},
+		"FFX": {"Mozilla Firefox"},
+		"ed":  {"Profile editor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fuzzy, func(t *testing.T) {
+			params := testParams()
+			params.ClassNames = nil
+			params.Fuzzy = tt.fuzzy
+			result := runKWinScript(t, params, fixture)
+			if got := result.outcome(t); got.Action != tt.want.Action || got.Matched != tt.want.Matched {
+				t.Errorf("outcome = %+v, want %+v", got, tt.want)
+			}
+			if got := result.activated(); !reflect.DeepEqual(got, wantActivated[tt.fuzzy]) {
+				t.Errorf("activated %q, want %q", got, wantActivated[tt.fuzzy])
+			}
+		})
+	}
+}
+
+func TestScriptExclude(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "Inbox - Mail", ResourceClass: "thunderbird"},
+			{Caption: "Write: (no subject)", ResourceClass: "thunderbird"},
+			{Caption: "Settings", ResourceClass: "Thunderbird-Settings"},
+		},
+	}
+	tests := []struct {
+		name                string
+		excludeClasses      []string
+		excludeCaptions     []string
+		excludeClassRegexes []string
+		ignoreCase          bool
+		want                int
+	}{
+		{name: "no exclusions", want: 3},
+		{name: "caption", excludeCaptions: []string{"^write:"}, want: 2},
+		{name: "escaped caption", excludeCaptions: []string{`\(no subject\)'?`}, want: 2},
+		{name: "two captions", excludeCaptions: []string{"^write:", "inbox"}, want: 1},
+		{name: "class", excludeClasses: []string{"Thunderbird-Settings"}, want: 2},
+		{name: "class is exact", excludeClasses: []string{"thunderbird-settings"}, want: 3},
+		{name: "class ignoring case", excludeClasses: []string{"thunderbird-settings"}, ignoreCase: true, want: 2},
+		{name: "everything", excludeClasses: []string{"Thunderbird-Settings"}, excludeCaptions: []string{"."}, want: 0},
+		{name: "class regex", excludeClassRegexes: []string{"-settings$"}, want: 3},
+		{name: "class regex ignoring case", excludeClassRegexes: []string{"-settings$"}, ignoreCase: true, want: 2},
+		{name: "class regex and caption", excludeClassRegexes: []string{"Settings"}, excludeCaptions: []string{"^write:"}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.ClassNames = nil
+			params.ClassRegex = "^[Tt]hunderbird"
+			params.ExcludeClasses = tt.excludeClasses
+			params.ExcludeCaptions = tt.excludeCaptions
+			params.ExcludeClassRegexes = tt.excludeClassRegexes
+			params.IgnoreCase = tt.ignoreCase
+			result := runKWinScript(t, params, fixture)
+			if got := result.outcome(t).Matched; got != tt.want {
+				t.Errorf("matched %d windows, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptAllFilters(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "YouTube - Mozilla Firefox", ResourceClass: "firefox"},
+			{Caption: "Mail - Mozilla Firefox", ResourceClass: "firefox"},
+			{Caption: "YouTube", ResourceClass: "chromium"},
+			{Caption: "shell", ResourceClass: "konsole"},
+		},
+		Active: "shell",
+	}
+	tests := []struct {
+		name       string
+		modify     func(p *scriptParams)
+		allFilters bool
+		want       int
+	}{
+		{name: "any class or caption", modify: func(p *scriptParams) { p.CaptionPattern = "youtube" }, want: 2},
+		{name: "class and caption", modify: func(p *scriptParams) { p.CaptionPattern = "youtube" }, allFilters: true, want: 1},
+		{name: "class and title", modify: func(p *scriptParams) { p.TitleSubstring = "mail" }, allFilters: true, want: 1},
+		{name: "class and regex", modify: func(p *scriptParams) { p.ClassRegex = "^chrom" }, allFilters: true, want: 0},
+		{name: "class only", allFilters: true, want: 2},
+		{name: "caption first is ignored", modify: func(p *scriptParams) {
+			p.CaptionPattern = "youtube"
+			p.CaptionFirst = true
+		}, allFilters: true, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.ClassNames = []string{"firefox"}
+			if tt.modify != nil {
+				tt.modify(&params)
+			}
+			params.AllFilters = tt.allFilters
+			result := runKWinScript(t, params, fixture)
+			if got := result.outcome(t).Matched; got != tt.want {
+				t.Errorf("matched %d windows, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptClassPreference(t *testing.T) {
+	windows := []fakeWindow{
+		{Caption: "shell", ResourceClass: "konsole"},
+		{Caption: "notes", ResourceClass: "kate"},
+		{Caption: "logs", ResourceClass: "konsole"},
+		{Caption: "mail", ResourceClass: "thunderbird"},
+	}
+	tests := []struct {
+		name    string
+		classes []string
+		active  string
+		want    []string
+		matched int
+	}{
+		{name: "first class wins", classes: []string{"kate", "konsole"}, active: "mail", want: []string{"notes"}, matched: 1},
+		{name: "order matters", classes: []string{"konsole", "kate"}, active: "mail", want: []string{"logs"}, matched: 2},
+		{name: "falls back to later classes", classes: []string{"gvim", "konsole"}, active: "mail", want: []string{"logs"}, matched: 2},
+		{name: "no class present", classes: []string{"gvim", "emacs"}, active: "mail"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.ClassNames = tt.classes
+			result := runKWinScript(t, params, kwinFixture{Windows: windows, Active: tt.active})
+			if got := result.activated(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("activated %q, want %q", got, tt.want)
+			}
+			if got := result.outcome(t).Matched; got != tt.matched {
+				t.Errorf("matched %d windows, want %d", got, tt.matched)
+			}
+		})
+	}
+}
+
+func TestScriptScreen(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "left", ResourceClass: "konsole", Output: "DP-1"},
+			{Caption: "right", ResourceClass: "konsole", Output: "HDMI-1"},
+			{Caption: "mail", ResourceClass: "thunderbird", Output: "HDMI-1"},
+		},
+		Active:       "mail",
+		ActiveScreen: "HDMI-1",
+	}
+	tests := []struct {
+		name          string
+		screen        string
+		currentScreen bool
+		want          []string
+		decision      string
+	}{
+		{name: "by name", screen: "DP-1", want: []string{"left"}, decision: "false"},
+		{name: "by index", screen: "1", want: []string{"right"}, decision: "false"},
+		{name: "current screen", currentScreen: true, want: []string{"right"}, decision: "false"},
+		{name: "unknown screen", screen: "eDP-1", decision: "error"},
+		{name: "index out of range", screen: "2", decision: "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.Screen = tt.screen
+			params.CurrentScreen = tt.currentScreen
+			result := runKWinScript(t, params, fixture)
+			if got := result.activated(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("activated %q, want %q", got, tt.want)
+			}
+			if got := result.shouldLaunch(); got != tt.decision {
+				t.Errorf("ShouldLaunch(%q), want %q", got, tt.decision)
+			}
+		})
+	}
+}
+
+func TestScriptActivity(t *testing.T) {
+	windows := []fakeWindow{
+		{Caption: "work shell", ResourceClass: "konsole", Activities: []string{"work"}},
+		{Caption: "home shell", ResourceClass: "konsole", Activities: []string{"home"}},
+		{Caption: "everywhere", ResourceClass: "konsole"},
+		{Caption: "mail", ResourceClass: "thunderbird", Activities: []string{"work", "home"}},
+	}
+	tests := []struct {
+		name            string
+		activity        string
+		currentActivity bool
+		current         string
+		want            int
+	}{
+		{name: "no activity filter", current: "work", want: 3},
+		{name: "named activity", activity: "home", current: "work", want: 2},
+		{name: "current activity", currentActivity: true, current: "work", want: 2},
+		{name: "unknown activity", activity: "play", current: "work", want: 1},
+		{name: "no activities support", currentActivity: true, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.Activity = tt.activity
+			params.CurrentActivity = tt.currentActivity
+			result := runKWinScript(t, params, kwinFixture{Windows: windows, Active: "mail", CurrentActivity: tt.current})
+			if got := result.outcome(t).Matched; got != tt.want {
+				t.Errorf("matched %d windows, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptMinimized(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "hidden", ResourceClass: "konsole", Minimized: true},
+			{Caption: "shown", ResourceClass: "konsole"},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+		},
+		Active: "mail",
+	}
+	tests := []struct {
+		name          string
+		minimizedOnly bool
+		skipMinimized bool
+		want          []string
+	}{
+		{name: "both", want: []string{"shown"}},
+		{name: "minimized only", minimizedOnly: true, want: []string{"hidden"}},
+		{name: "skip minimized", skipMinimized: true, want: []string{"shown"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.MinimizedOnly = tt.minimizedOnly
+			params.SkipMinimized = tt.skipMinimized
+			result := runKWinScript(t, params, fixture)
+			if got := result.activated(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("activated %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptState(t *testing.T) {
+	maximizedMode, horizontalMode := 3, 2
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "video", ResourceClass: "mpv", FullScreen: true},
+			{Caption: "filled", ResourceClass: "mpv", FrameGeometry: &rect{Width: 1920, Height: 1080}},
+			{Caption: "x11 maximized", ResourceClass: "mpv", MaximizeMode: &maximizedMode},
+			{Caption: "x11 wide", ResourceClass: "mpv", MaximizeMode: &horizontalMode},
+			{Caption: "small", ResourceClass: "mpv"},
+		},
+	}
+	tests := []struct {
+		state string
+		want  int
+	}{
+		{state: "", want: 5},
+		{state: "fullscreen", want: 1},
+		{state: "maximized", want: 2},
+		{state: "normal", want: 2},
+		{state: "!normal", want: 3},
+		{state: "!fullscreen", want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			params := testParams()
+			params.ClassNames = []string{"mpv"}
+			params.State = tt.state
+			result := runKWinScript(t, params, fixture)
+			if got := result.outcome(t).Matched; got != tt.want {
+				t.Errorf("matched %d windows, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptSpecialWindows(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "Desktop @ DP-1", ResourceClass: "plasmashell", DesktopWindow: true},
+			{Caption: "Panel", ResourceClass: "plasmashell", Dock: true},
+			{Caption: "OSD", ResourceClass: "plasmashell", SpecialWindow: true},
+			{Caption: "Plasma settings", ResourceClass: "plasmashell"},
+		},
+	}
+	for _, includeSpecial := range []bool{false, true} {
+		params := testParams()
+		params.ClassNames = []string{"plasmashell"}
+		params.IncludeSpecial = includeSpecial
+		result := runKWinScript(t, params, fixture)
+		want := 1
+		if includeSpecial {
+			want = 4
+		}
+		if got := result.outcome(t).Matched; got != want {
+			t.Errorf("includeSpecial=%v: matched %d windows, want %d", includeSpecial, got, want)
+		}
+	}
+}
+
+func TestScriptDesktop(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "first", ResourceClass: "konsole", Desktops: []string{"One"}},
+			{Caption: "second", ResourceClass: "konsole", Desktops: []string{"Two"}},
+			{Caption: "sticky", ResourceClass: "konsole", OnAllDesktops: true},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+		},
+		Active:        "mail",
+		DesktopCopies: true,
+	}
+	tests := []struct {
+		desktop  string
+		want     int
+		decision string
+	}{
+		{desktop: "1", want: 2, decision: "false"},
+		{desktop: "Two", want: 2, decision: "false"},
+		{desktop: "2", want: 2, decision: "false"},
+		{desktop: "3", decision: "error"},
+		{desktop: "Three", decision: "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desktop, func(t *testing.T) {
+			params := testParams()
+			params.Desktop = tt.desktop
+			result := runKWinScript(t, params, fixture)
+			if got := result.shouldLaunch(); got != tt.decision {
+				t.Fatalf("ShouldLaunch(%q), want %q", got, tt.decision)
+			}
+			if tt.decision == "error" {
+				return
+			}
+			if got := result.outcome(t).Matched; got != tt.want {
+				t.Errorf("matched %d windows, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptSummon(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "shell", ResourceClass: "konsole", Desktops: []string{"Two"}, Output: "HDMI-1", Activities: []string{"home"}},
+			{Caption: "mail", ResourceClass: "thunderbird", Activities: []string{"work"}},
+		},
+		Active:          "mail",
+		CurrentActivity: "work",
+	}
+	tests := []struct {
+		name           string
+		summon         bool
+		summonToScreen bool
+		want           windowState
+	}{
+		{name: "off", want: windowState{Desktops: []string{"Two"}, Output: "HDMI-1", Activities: []string{"home"}}},
+		{name: "summon", summon: true, want: windowState{Desktops: []string{"One"}, Output: "HDMI-1", Activities: []string{"work"}}},
+		{name: "summon to screen", summon: true, summonToScreen: true, want: windowState{Desktops: []string{"One"}, Output: "DP-1", Activities: []string{"work"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.Summon = tt.summon
+			params.SummonToScreen = tt.summonToScreen
+			result := runKWinScript(t, params, fixture)
+			if result.Active != "shell" {
+				t.Fatalf("active = %q, want shell", result.Active)
+			}
+			got := result.Windows["shell"]
+			if !reflect.DeepEqual(got.Desktops, tt.want.Desktops) || got.Output != tt.want.Output || !reflect.DeepEqual(got.Activities, tt.want.Activities) {
+				t.Errorf("shell on %v/%s/%v, want %v/%s/%v", got.Desktops, got.Output, got.Activities, tt.want.Desktops, tt.want.Output, tt.want.Activities)
+			}
+		})
+	}
+}
+
+func TestScriptNoDesktopSwitch(t *testing.T) {
+	tests := []struct {
+		name          string
+		windows       []fakeWindow
+		wantAction    string
+		wantActive    string
+		wantAttention string
+	}{
+		{
+			name: "match here",
+			windows: []fakeWindow{
+				{Caption: "here", ResourceClass: "konsole", StackingOrder: 1},
+				{Caption: "there", ResourceClass: "konsole", Desktops: []string{"Two"}, StackingOrder: 2},
+			},
+			wantAction: "activated",
+			wantActive: "here",
+		},
+		{
+			name: "match elsewhere only",
+			windows: []fakeWindow{
+				{Caption: "older", ResourceClass: "konsole", Desktops: []string{"Two"}, StackingOrder: 1},
+				{Caption: "there", ResourceClass: "konsole", Desktops: []string{"Two"}, StackingOrder: 2},
+			},
+			wantAction:    "demanded-attention",
+			wantActive:    "mail",
+			wantAttention: "there",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windows := append(tt.windows, fakeWindow{Caption: "mail", ResourceClass: "thunderbird", StackingOrder: 3})
+			params := testParams()
+			params.NoDesktopSwitch = true
+			result := runKWinScript(t, params, kwinFixture{Windows: windows, Active: "mail"})
+			if got := result.outcome(t).Action; got != tt.wantAction {
+				t.Errorf("action = %q, want %q", got, tt.wantAction)
+			}
+			if result.Active != tt.wantActive {
+				t.Errorf("active = %q, want %q", result.Active, tt.wantActive)
+			}
+			for caption, state := range result.Windows {
+				if want := caption == tt.wantAttention; state.DemandsAttention != want {
+					t.Errorf("%s demands attention = %v, want %v", caption, state.DemandsAttention, want)
+				}
+			}
+		})
+	}
+}
+
+func TestScriptRaiseOnly(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "old shell", ResourceClass: "konsole", StackingOrder: 1},
+			{Caption: "shell", ResourceClass: "konsole", StackingOrder: 2, Minimized: true},
+			{Caption: "mail", ResourceClass: "thunderbird", StackingOrder: 3},
+		},
+		Active: "mail",
+	}
+	params := testParams()
+	params.Action = "raise"
+	result := runKWinScript(t, params, fixture)
+	if got := result.outcome(t).Action; got != "raised" {
+		t.Errorf("action = %q, want raised", got)
+	}
+	if got := result.calls("raise"); !reflect.DeepEqual(got, []string{"shell"}) {
+		t.Errorf("raised %v, want [shell]", got)
+	}
+	if got := result.activated(); len(got) != 0 {
+		t.Errorf("activated %v, want nothing", got)
+	}
+	if result.Active != "mail" || result.Windows["shell"].Minimized {
+		t.Errorf("active = %q, shell minimized = %v; want mail active and shell shown", result.Active, result.Windows["shell"].Minimized)
+	}
+}
+
+func TestScriptSolo(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "shell", ResourceClass: "konsole"},
+			{Caption: "Settings", ResourceClass: "kdialog", TransientFor: "shell"},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+			{Caption: "editor", ResourceClass: "kate"},
+			{Caption: "elsewhere", ResourceClass: "kate", Desktops: []string{"Two"}},
+			{Caption: "other activity", ResourceClass: "kate", Activities: []string{"home"}},
+			{Caption: "panel", ResourceClass: "plasmashell", SpecialWindow: true},
+		},
+		Active:          "mail",
+		CurrentActivity: "work",
+	}
+	for _, solo := range []bool{false, true} {
+		params := testParams()
+		params.Solo = solo
+		result := runKWinScript(t, params, fixture)
+		if result.Active != "shell" {
+			t.Fatalf("solo=%v: active = %q, want shell", solo, result.Active)
+		}
+		want := map[string]bool{}
+		if solo {
+			want = map[string]bool{"mail": true, "editor": true}
+		}
+		for caption, state := range result.Windows {
+			if state.Minimized != want[caption] {
+				t.Errorf("solo=%v: %s minimized = %v, want %v", solo, caption, state.Minimized, want[caption])
+			}
+		}
+	}
+
+	// Without activity support every window counts as on the current one.
+	params := testParams()
+	params.Solo = true
+	fixture.CurrentActivity = ""
+	result := runKWinScript(t, params, fixture)
+	if !result.Windows["other activity"].Minimized {
+		t.Error("no current activity: window with an activity list was not minimized")
+	}
+}
+
+func TestScriptDeferredActivation(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "shell", ResourceClass: "konsole"},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+		},
+		Active:             "mail",
+		DeferredActivation: true,
+	}
+	params := testParams()
+	params.Solo = true
+	params.Maximize = true
+	params.Tile = "left"
+	result := runKWinScript(t, params, fixture)
+	if result.Active != "shell" {
+		t.Fatalf("active = %q, want shell", result.Active)
+	}
+	if got := result.calls("maximize"); !reflect.DeepEqual(got, []string{"shell"}) {
+		t.Errorf("maximized %v, want [shell]", got)
+	}
+	if result.Windows["shell"].Minimized || !result.Windows["mail"].Minimized {
+		t.Errorf("minimized shell = %v, mail = %v; want only mail minimized",
+			result.Windows["shell"].Minimized, result.Windows["mail"].Minimized)
+	}
+	var tiled [][]any
+	for _, entry := range result.Log {
+		if entry[0] == "quickTile" {
+			tiled = append(tiled, entry)
+		}
+	}
+	if want := [][]any{{"quickTile", "shell", "Left"}}; !reflect.DeepEqual(tiled, want) {
+		t.Errorf("quick-tiled %v, want %v", tiled, want)
+	}
+}
+
+func TestScriptClose(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "old shell", ResourceClass: "konsole", StackingOrder: 1},
+			{Caption: "shell", ResourceClass: "konsole", StackingOrder: 2},
+			{Caption: "mail", ResourceClass: "thunderbird", StackingOrder: 3},
+		},
+		Active: "mail",
+	}
+	tests := []struct {
+		action     string
+		class      string
+		wantAction string
+		wantClosed []string
+		decision   string
+	}{
+		{action: "close", class: "konsole", wantAction: "closed", wantClosed: []string{"shell"}, decision: "false"},
+		{action: "close-all", class: "konsole", wantAction: "closed-all", wantClosed: []string{"old shell", "shell"}, decision: "false"},
+		{action: "close", class: "kate", wantAction: "no-match", decision: "false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action+" "+tt.class, func(t *testing.T) {
+			params := testParams()
+			params.ClassNames = []string{tt.class}
+			params.Action = tt.action
+			result := runKWinScript(t, params, fixture)
+			if got := result.outcome(t).Action; got != tt.wantAction {
+				t.Errorf("action = %q, want %q", got, tt.wantAction)
+			}
+			if got := result.calls("close"); !reflect.DeepEqual(got, tt.wantClosed) {
+				t.Errorf("closed %v, want %v", got, tt.wantClosed)
+			}
+			if got := result.shouldLaunch(); got != tt.decision {
+				t.Errorf("ShouldLaunch(%q), want %q", got, tt.decision)
+			}
+		})
+	}
+}
+
+func TestScriptMaximize(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "shell", ResourceClass: "konsole"},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+		},
+		Active: "mail",
+	}
+	for _, maximize := range []bool{false, true} {
+		params := testParams()
+		params.Maximize = maximize
+		result := runKWinScript(t, params, fixture)
+		var want []string
+		if maximize {
+			want = []string{"shell"}
+		}
+		if got := result.calls("maximize"); !reflect.DeepEqual(got, want) {
+			t.Errorf("maximize=%v: maximized %v, want %v", maximize, got, want)
+		}
+	}
+
+	// A window that is already active is maximized too.
+	params := testParams()
+	params.Maximize = true
+	fixture.Active = "shell"
+	if got := runKWinScript(t, params, fixture).calls("maximize"); !reflect.DeepEqual(got, []string{"shell"}) {
+		t.Errorf("already active: maximized %v, want [shell]", got)
+	}
+}
+
+func TestScriptFullscreen(t *testing.T) {
+	no := false
+	tests := []struct {
+		name   string
+		window fakeWindow
+		want   bool
+	}{
+		{name: "enter", window: fakeWindow{Caption: "player", ResourceClass: "mpv"}, want: true},
+		{name: "leave", window: fakeWindow{Caption: "player", ResourceClass: "mpv", FullScreen: true}, want: false},
+		{name: "not fullscreenable", window: fakeWindow{Caption: "player", ResourceClass: "mpv", FullScreenable: &no}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.ClassNames = []string{"mpv"}
+			params.Fullscreen = true
+			fixture := kwinFixture{
+				Windows: []fakeWindow{tt.window, {Caption: "mail", ResourceClass: "thunderbird"}},
+				Active:  "mail",
+			}
+			result := runKWinScript(t, params, fixture)
+			if got := result.Windows["player"].FullScreen; got != tt.want {
+				t.Errorf("fullScreen = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptTile(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "shell", ResourceClass: "konsole"},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+		},
+		Active: "mail",
+	}
+	tests := []struct {
+		tile string
+		want [][]any
+	}{
+		{tile: ""},
+		{tile: "left", want: [][]any{{"quickTile", "shell", "Left"}}},
+		{tile: "bottomright", want: [][]any{{"quickTile", "shell", "BottomRight"}}},
+	}
+	for _, tt := range tests {
+		params := testParams()
+		params.Tile = tt.tile
+		result := runKWinScript(t, params, fixture)
+		var got [][]any
+		for _, entry := range result.Log {
+			if entry[0] == "quickTile" {
+				got = append(got, entry)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tile %q: quick-tiled %v, want %v", tt.tile, got, tt.want)
+		}
+	}
+}
+
+func TestScriptSendToDesktop(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "shell", ResourceClass: "konsole"},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+		},
+		Active: "mail",
+	}
+	tests := []struct {
+		name        string
+		desktop     string
+		follow      bool
+		wantAction  string
+		wantOn      []string
+		wantActive  string
+		wantCurrent string
+	}{
+		{name: "by number", desktop: "2", wantAction: "sent-to-desktop", wantOn: []string{"Two"}, wantActive: "mail", wantCurrent: "One"},
+		{name: "by name", desktop: "Two", wantAction: "sent-to-desktop", wantOn: []string{"Two"}, wantActive: "mail", wantCurrent: "One"},
+		{name: "follow", desktop: "2", follow: true, wantAction: "sent-to-desktop", wantOn: []string{"Two"}, wantActive: "shell", wantCurrent: "Two"},
+		{name: "unknown desktop", desktop: "9", wantAction: "error", wantOn: []string{"One"}, wantActive: "mail", wantCurrent: "One"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.Action = "send-to-desktop"
+			params.SendToDesktop = tt.desktop
+			params.Follow = tt.follow
+			result := runKWinScript(t, params, fixture)
+			if got := result.outcome(t).Action; got != tt.wantAction {
+				t.Errorf("action = %q, want %q", got, tt.wantAction)
+			}
+			if got := result.Windows["shell"].Desktops; !reflect.DeepEqual(got, tt.wantOn) {
+				t.Errorf("shell on %v, want %v", got, tt.wantOn)
+			}
+			if result.Active != tt.wantActive || result.CurrentDesktop != tt.wantCurrent {
+				t.Errorf("active = %q on %q, want %q on %q", result.Active, result.CurrentDesktop, tt.wantActive, tt.wantCurrent)
+			}
+		})
+	}
+}
+
+func TestScriptSendToScreen(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "shell", ResourceClass: "konsole", Output: "HDMI-1"},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+		},
+		Active:       "mail",
+		ActiveScreen: "DP-1",
+	}
+	tests := []struct {
+		name            string
+		sendToScreen    string
+		toCurrentScreen bool
+		action          string
+		wantOutput      string
+		wantAction      string
+	}{
+		{name: "stay", wantOutput: "HDMI-1", wantAction: "activated"},
+		{name: "by name", sendToScreen: "DP-1", wantOutput: "DP-1", wantAction: "activated"},
+		{name: "by index", sendToScreen: "0", wantOutput: "DP-1", wantAction: "activated"},
+		{name: "current screen", toCurrentScreen: true, wantOutput: "DP-1", wantAction: "activated"},
+		{name: "with raise only", toCurrentScreen: true, action: "raise", wantOutput: "DP-1", wantAction: "raised"},
+		{name: "unknown screen", sendToScreen: "VGA-1", wantOutput: "HDMI-1", wantAction: "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.SendToScreen = tt.sendToScreen
+			params.ToCurrentScreen = tt.toCurrentScreen
+			params.Action = tt.action
+			result := runKWinScript(t, params, fixture)
+			if got := result.outcome(t).Action; got != tt.wantAction {
+				t.Errorf("action = %q, want %q", got, tt.wantAction)
+			}
+			if got := result.Windows["shell"].Output; got != tt.wantOutput {
+				t.Errorf("shell on %q, want %q", got, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestScriptSendToActivity(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "shell", ResourceClass: "konsole", Activities: []string{"work"}},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+		},
+		Active: "mail",
+	}
+	tests := []struct {
+		name           string
+		sendToActivity string
+		want           []string
+	}{
+		{name: "stay", want: []string{"work"}},
+		{name: "move", sendToActivity: "play", want: []string{"play"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.SendToActivity = tt.sendToActivity
+			result := runKWinScript(t, params, fixture)
+			if result.Active != "shell" {
+				t.Errorf("active = %q, want shell", result.Active)
+			}
+			if got := result.Windows["shell"].Activities; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("shell on activities %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptPin(t *testing.T) {
+	for _, pinned := range []bool{false, true} {
+		params := testParams()
+		params.Pin = true
+		fixture := kwinFixture{
+			Windows: []fakeWindow{
+				{Caption: "shell", ResourceClass: "konsole", OnAllDesktops: pinned},
+				{Caption: "mail", ResourceClass: "thunderbird"},
+			},
+			Active: "mail",
+		}
+		result := runKWinScript(t, params, fixture)
+		if got := result.Windows["shell"].OnAllDesktops; got == pinned {
+			t.Errorf("onAllDesktops was %v: still %v after toggling", pinned, got)
+		}
+	}
+}
+
+func TestScriptKeepAbove(t *testing.T) {
+	for _, above := range []bool{false, true} {
+		params := testParams()
+		params.KeepAbove = true
+		fixture := kwinFixture{
+			Windows: []fakeWindow{
+				{Caption: "shell", ResourceClass: "konsole", KeepAbove: above},
+				{Caption: "mail", ResourceClass: "thunderbird"},
+			},
+			Active: "mail",
+		}
+		result := runKWinScript(t, params, fixture)
+		if got := result.Windows["shell"].KeepAbove; got == above {
+			t.Errorf("keepAbove was %v: still %v after toggling", above, got)
+		}
+	}
+}
+
+func TestScriptOpacity(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "shell", ResourceClass: "konsole"},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+		},
+		Active: "mail",
+	}
+	for _, tt := range []struct{ opacity, want float64 }{{0, 1}, {0.85, 0.85}, {1, 1}} {
+		params := testParams()
+		params.Opacity = tt.opacity
+		result := runKWinScript(t, params, fixture)
+		if got := result.Windows["shell"].Opacity; got != tt.want {
+			t.Errorf("--opacity %g: opacity = %g, want %g", tt.opacity, got, tt.want)
+		}
+	}
+}
+
+func TestScriptShade(t *testing.T) {
+	windows := []fakeWindow{
+		{Caption: "shell", ResourceClass: "konsole"},
+		{Caption: "mail", ResourceClass: "thunderbird"},
+	}
+	tests := []struct {
+		active     string
+		wantAction string
+		wantShade  bool
+	}{
+		{active: "mail", wantAction: "activated"},
+		{active: "shell", wantAction: "shaded", wantShade: true},
+	}
+	for _, tt := range tests {
+		params := testParams()
+		params.Shade = true
+		result := runKWinScript(t, params, kwinFixture{Windows: windows, Active: tt.active})
+		if got := result.outcome(t).Action; got != tt.wantAction {
+			t.Errorf("%s active: action = %q, want %q", tt.active, got, tt.wantAction)
+		}
+		if got := result.Windows["shell"]; got.Shade != tt.wantShade || got.Minimized {
+			t.Errorf("%s active: shade = %v, minimized = %v; want shade %v", tt.active, got.Shade, got.Minimized, tt.wantShade)
+		}
+	}
+}
+
+func TestScriptPlace(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "shell", ResourceClass: "konsole", FrameGeometry: &rect{X: 10, Y: 10, Width: 800, Height: 600}},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+		},
+		Active: "mail",
+	}
+	tests := []struct {
+		place string
+		want  rect
+	}{
+		{place: "", want: rect{X: 10, Y: 10, Width: 800, Height: 600}},
+		{place: "center", want: rect{X: 560, Y: 240, Width: 800, Height: 600}},
+		{place: "bottom-right", want: rect{X: 1120, Y: 480, Width: 800, Height: 600}},
+		{place: "100,50%", want: rect{X: 100, Y: 240, Width: 800, Height: 600}},
+	}
+	for _, tt := range tests {
+		place, err := parsePlacement(tt.place)
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := testParams()
+		params.Place = place
+		result := runKWinScript(t, params, fixture)
+		if got := result.Windows["shell"].FrameGeometry; got != tt.want {
+			t.Errorf("--place %q: geometry = %+v, want %+v", tt.place, got, tt.want)
+		}
+	}
+}
+
+func TestScriptGeometry(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "shell", ResourceClass: "konsole", FrameGeometry: &rect{X: 10, Y: 20, Width: 800, Height: 600}},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+		},
+		Active: "mail",
+	}
+	tests := []struct {
+		geometry string
+		place    string
+		want     rect
+	}{
+		{geometry: "1200x800", want: rect{X: 10, Y: 20, Width: 1200, Height: 800}},
+		{geometry: "50%x50%", want: rect{X: 10, Y: 20, Width: 960, Height: 540}},
+		{geometry: "50%x50%", place: "center", want: rect{X: 480, Y: 270, Width: 960, Height: 540}},
+	}
+	for _, tt := range tests {
+		size, err := parseSize(tt.geometry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		place, err := parsePlacement(tt.place)
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := testParams()
+		params.Size = size
+		params.Place = place
+		result := runKWinScript(t, params, fixture)
+		if got := result.Windows["shell"].FrameGeometry; got != tt.want {
+			t.Errorf("--geometry %q --place %q: geometry = %+v, want %+v", tt.geometry, tt.place, got, tt.want)
+		}
+	}
+}
+
+func TestScriptWarpPointer(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "shell", ResourceClass: "konsole", FrameGeometry: &rect{X: 100, Y: 200, Width: 801, Height: 600}},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+		},
+		Active: "mail",
+	}
+	tests := []struct {
+		name  string
+		warp  bool
+		class string
+		want  *point
+	}{
+		{name: "off", class: "konsole"},
+		{name: "activated", warp: true, class: "konsole", want: &point{X: 501, Y: 500}},
+		{name: "no match", warp: true, class: "kate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.ClassNames = []string{tt.class}
+			params.WarpPointer = tt.warp
+			got := runKWinScript(t, params, fixture).outcome(t).Pointer
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pointer = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptHide(t *testing.T) {
+	tests := []struct {
+		name          string
+		windows       []fakeWindow
+		wantAction    string
+		wantMinimized []string
+		decision      string
+	}{
+		{
+			name: "shown",
+			windows: []fakeWindow{
+				{Caption: "shell", ResourceClass: "konsole"},
+				{Caption: "other shell", ResourceClass: "konsole", Minimized: true},
+			},
+			wantAction:    "minimized",
+			wantMinimized: []string{"other shell", "shell"},
+			decision:      "false",
+		},
+		{
+			name:          "already hidden",
+			windows:       []fakeWindow{{Caption: "shell", ResourceClass: "konsole", Minimized: true}},
+			wantAction:    "none",
+			wantMinimized: []string{"shell"},
+			decision:      "false",
+		},
+		{name: "no match", wantAction: "no-match", decision: "false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windows := append(tt.windows, fakeWindow{Caption: "mail", ResourceClass: "thunderbird"})
+			params := testParams()
+			params.Action = "hide"
+			result := runKWinScript(t, params, kwinFixture{Windows: windows, Active: "mail"})
+			if got := result.outcome(t).Action; got != tt.wantAction {
+				t.Errorf("action = %q, want %q", got, tt.wantAction)
+			}
+			if got := result.shouldLaunch(); got != tt.decision {
+				t.Errorf("ShouldLaunch(%q), want %q", got, tt.decision)
+			}
+			var minimized []string
+			for caption, state := range result.Windows {
+				if state.Minimized {
+					minimized = append(minimized, caption)
+				}
+			}
+			sort.Strings(minimized)
+			if !reflect.DeepEqual(minimized, tt.wantMinimized) {
+				t.Errorf("minimized %v, want %v", minimized, tt.wantMinimized)
+			}
+			if result.Active != "mail" {
+				t.Errorf("active = %q, want mail", result.Active)
+			}
+		})
+	}
+}
+
+func TestScriptOwnDesktop(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "old shell", ResourceClass: "konsole", StackingOrder: 1},
+			{Caption: "shell", ResourceClass: "konsole", StackingOrder: 2},
+			{Caption: "mail", ResourceClass: "thunderbird", StackingOrder: 3},
+		},
+		Active: "mail",
+	}
+	tests := []struct {
+		desktop     string
+		wantCreated []string
+	}{
+		{desktop: "Two"},
+		{desktop: "konsole", wantCreated: []string{"konsole"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desktop, func(t *testing.T) {
+			params := testParams()
+			params.Action = "own-desktop"
+			params.SendToDesktop = tt.desktop
+			result := runKWinScript(t, params, fixture)
+			if got := result.outcome(t).Action; got != "moved-to-own-desktop" {
+				t.Errorf("action = %q, want moved-to-own-desktop", got)
+			}
+			if got := result.calls("createDesktop"); !reflect.DeepEqual(got, tt.wantCreated) {
+				t.Errorf("created desktops %v, want %v", got, tt.wantCreated)
+			}
+			for _, caption := range []string{"old shell", "shell"} {
+				if got := result.Windows[caption].Desktops; !reflect.DeepEqual(got, []string{tt.desktop}) {
+					t.Errorf("%s on %v, want [%s]", caption, got, tt.desktop)
+				}
+			}
+			if got := result.Windows["mail"].Desktops; !reflect.DeepEqual(got, []string{"One"}) {
+				t.Errorf("mail on %v, want [One]", got)
+			}
+			if result.CurrentDesktop != tt.desktop || result.Active != "shell" {
+				t.Errorf("active = %q on %q, want shell on %q", result.Active, result.CurrentDesktop, tt.desktop)
+			}
+		})
+	}
+}
+
+func TestScriptFlash(t *testing.T) {
+	tests := []struct {
+		name        string
+		flash       bool
+		opacity     float64
+		running     string
+		want        []float64
+		wantFlashed map[string]float64
+	}{
+		{name: "off"},
+		{name: "opaque window", flash: true, want: []float64{0.4, 1, 0.4, 1}, wantFlashed: map[string]float64{"{shell}": 1}},
+		{name: "translucent window", flash: true, opacity: 0.5, want: []float64{0.2, 0.5, 0.2, 0.5}, wantFlashed: map[string]float64{"{shell}": 0.5}},
+		{
+			name:        "dimmed by a running flash",
+			flash:       true,
+			opacity:     0.4,
+			running:     `{"{shell}": 1}`,
+			want:        []float64{0.4, 1, 0.4, 1},
+			wantFlashed: map[string]float64{"{shell}": 1},
+		},
+		{
+			name:        "another window's flash",
+			flash:       true,
+			opacity:     0.5,
+			running:     `{"{mail}": 1}`,
+			want:        []float64{0.2, 0.5, 0.2, 0.5},
+			wantFlashed: map[string]float64{"{shell}": 0.5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixture := kwinFixture{
+				Windows: []fakeWindow{
+					{Caption: "shell", ResourceClass: "konsole", Opacity: tt.opacity},
+					{Caption: "mail", ResourceClass: "thunderbird"},
+				},
+				Active: "mail",
+			}
+			params := testParams()
+			params.Flash = tt.flash
+			params.FlashSteps = 4
+			params.FlashInterval = 150
+			if tt.running != "" {
+				params.FlashOpacities = tt.running
+			}
+			result := runKWinScript(t, params, fixture)
+			var got []float64
+			for _, entry := range result.Log {
+				if entry[0] == "opacity" && entry[1] == "shell" {
+					got = append(got, entry[2].(float64))
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("opacity went %v, want %v", got, tt.want)
+			}
+			if flashed := result.outcome(t).Flashed; !reflect.DeepEqual(flashed, tt.wantFlashed) {
+				t.Errorf("flashed = %v, want %v", flashed, tt.wantFlashed)
+			}
+		})
+	}
+}
+
+func TestScriptExpose(t *testing.T) {
+	tests := []struct {
+		name        string
+		windows     []fakeWindow
+		wantAction  string
+		wantWindows []string
+		wantActive  string
+	}{
+		{
+			name: "several matches",
+			windows: []fakeWindow{
+				{Caption: "one", ResourceClass: "konsole", InternalID: "{1}"},
+				{Caption: "two", ResourceClass: "konsole", InternalID: "{2}"},
+			},
+			wantAction:  "exposed",
+			wantWindows: []string{"{1}", "{2}"},
+			wantActive:  "mail",
+		},
+		{
+			name:       "one match",
+			windows:    []fakeWindow{{Caption: "one", ResourceClass: "konsole", InternalID: "{1}"}},
+			wantAction: "activated",
+			wantActive: "one",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windows := append(tt.windows, fakeWindow{Caption: "mail", ResourceClass: "thunderbird"})
+			params := testParams()
+			params.Expose = true
+			result := runKWinScript(t, params, kwinFixture{Windows: windows, Active: "mail"})
+			outcome := result.outcome(t)
+			if outcome.Action != tt.wantAction {
+				t.Errorf("action = %q, want %q", outcome.Action, tt.wantAction)
+			}
+			if !reflect.DeepEqual(outcome.Windows, tt.wantWindows) {
+				t.Errorf("windows = %q, want %q", outcome.Windows, tt.wantWindows)
+			}
+			if result.Active != tt.wantActive {
+				t.Errorf("active = %q, want %q", result.Active, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestScriptCycleSkipMinimized(t *testing.T) {
+	tests := []struct {
+		name       string
+		skip       bool
+		bMinimized bool
+		wantAction string
+		wantActive string
+	}{
+		{name: "include minimized", wantAction: "cycled", wantActive: "a"},
+		{name: "skip minimized", skip: true, wantAction: "cycled", wantActive: "b"},
+		{name: "nothing left to cycle to", skip: true, bMinimized: true, wantAction: "none", wantActive: "c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixture := kwinFixture{
+				Windows: []fakeWindow{
+					{Caption: "a", ResourceClass: "konsole", StackingOrder: 1, Minimized: true},
+					{Caption: "b", ResourceClass: "konsole", StackingOrder: 2, Minimized: tt.bMinimized},
+					{Caption: "c", ResourceClass: "konsole", StackingOrder: 3},
+				},
+				Active: "c",
+			}
+			params := testParams()
+			params.CycleSkipMinimized = tt.skip
+			result := runKWinScript(t, params, fixture)
+			if got := result.outcome(t).Action; got != tt.wantAction {
+				t.Errorf("action = %q, want %q", got, tt.wantAction)
+			}
+			if result.Active != tt.wantActive {
+				t.Errorf("active = %q, want %q", result.Active, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestScriptRememberGeometry(t *testing.T) {
+	where := rect{X: 2000, Y: 50, Width: 640, Height: 480}
+	t.Run("hiding saves the geometry", func(t *testing.T) {
+		fixture := kwinFixture{
+			Windows: []fakeWindow{
+				{Caption: "shell", ResourceClass: "konsole", InternalID: "{1}", Desktops: []string{"Two"}, Output: "HDMI-1", FrameGeometry: &where},
+			},
+			Active:         "shell",
+			CurrentDesktop: "Two",
+		}
+		params := testParams()
+		params.Toggle = true
+		params.RememberGeometry = true
+		outcome := runKWinScript(t, params, fixture).outcome(t)
+		if outcome.Action != "minimized" {
+			t.Errorf("action = %q, want minimized", outcome.Action)
+		}
+		want := map[string]*savedGeometry{"{1}": {X: 2000, Y: 50, Width: 640, Height: 480, Screen: "HDMI-1", Desktops: []string{"desktop-2"}}}
+		if !reflect.DeepEqual(outcome.Geometry, want) {
+			t.Errorf("geometry = %+v, want %+v", outcome.Geometry, want)
+		}
+	})
+	t.Run("showing restores and drops it", func(t *testing.T) {
+		fixture := kwinFixture{
+			Windows: []fakeWindow{
+				{Caption: "shell", ResourceClass: "konsole", InternalID: "{1}", Minimized: true},
+				{Caption: "mail", ResourceClass: "thunderbird"},
+			},
+			Active: "mail",
+		}
+		params := testParams()
+		params.Toggle = true
+		params.RememberGeometry = true
+		params.SavedGeometry = `{"{1}": {"x": 2000, "y": 50, "width": 640, "height": 480, "screen": "HDMI-1", "desktops": ["desktop-2"]}}`
+		size, err := parseSize("50%x50%")
+		if err != nil {
+			t.Fatal(err)
+		}
+		params.Size = size
+		result := runKWinScript(t, params, fixture)
+		if result.Active != "shell" {
+			t.Errorf("active = %q, want shell", result.Active)
+		}
+		state := result.Windows["shell"]
+		if state.FrameGeometry != where || state.Output != "HDMI-1" || !reflect.DeepEqual(state.Desktops, []string{"Two"}) {
+			t.Errorf("shell at %+v on %s %q, want %+v on HDMI-1 [Two]", state.FrameGeometry, state.Output, state.Desktops, where)
+		}
+		if got := result.outcome(t).Geometry; !reflect.DeepEqual(got, map[string]*savedGeometry{"{1}": nil}) {
+			t.Errorf("geometry = %+v, want {1} dropped", got)
+		}
+	})
+}
+
+func TestScriptSwap(t *testing.T) {
+	left := rect{X: 0, Y: 0, Width: 960, Height: 1080}
+	right := rect{X: 960, Y: 0, Width: 960, Height: 1080}
+	tests := []struct {
+		name       string
+		active     string
+		wantAction string
+		wantShell  rect
+	}{
+		{name: "trades places with the active window", active: "mail", wantAction: "swapped", wantShell: right},
+		{name: "already active", active: "shell", wantAction: "none", wantShell: left},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixture := kwinFixture{
+				Windows: []fakeWindow{
+					{Caption: "shell", ResourceClass: "konsole", FrameGeometry: &left},
+					{Caption: "mail", ResourceClass: "thunderbird", FrameGeometry: &right},
+				},
+				Active: tt.active,
+			}
+			params := testParams()
+			params.Action = "swap"
+			result := runKWinScript(t, params, fixture)
+			if got := result.outcome(t).Action; got != tt.wantAction {
+				t.Errorf("action = %q, want %q", got, tt.wantAction)
+			}
+			if result.Active != "shell" {
+				t.Errorf("active = %q, want shell", result.Active)
+			}
+			if got := result.Windows["shell"].FrameGeometry; got != tt.wantShell {
+				t.Errorf("shell at %+v, want %+v", got, tt.wantShell)
+			}
+			wantMail := right
+			if tt.wantShell == right {
+				wantMail = left
+			}
+			if got := result.Windows["mail"].FrameGeometry; got != wantMail {
+				t.Errorf("mail at %+v, want %+v", got, wantMail)
+			}
+		})
+	}
+}
+
+func TestScriptReverse(t *testing.T) {
+	tests := []struct {
+		name       string
+		reverse    bool
+		skip       bool
+		bMinimized bool
+		wantActive string
+	}{
+		{name: "forward goes to the bottom match", wantActive: "a"},
+		{name: "reverse goes to the match below", reverse: true, wantActive: "b"},
+		{name: "reverse skips minimized", reverse: true, skip: true, bMinimized: true, wantActive: "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixture := kwinFixture{
+				Windows: []fakeWindow{
+					{Caption: "a", ResourceClass: "konsole", StackingOrder: 1},
+					{Caption: "b", ResourceClass: "konsole", StackingOrder: 2, Minimized: tt.bMinimized},
+					{Caption: "c", ResourceClass: "konsole", StackingOrder: 3},
+				},
+				Active: "c",
+			}
+			params := testParams()
+			params.Reverse = tt.reverse
+			params.CycleSkipMinimized = tt.skip
+			result := runKWinScript(t, params, fixture)
+			if got := result.outcome(t).Action; got != "cycled" {
+				t.Errorf("action = %q, want cycled", got)
+			}
+			if result.Active != tt.wantActive {
+				t.Errorf("active = %q, want %q", result.Active, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestScriptReverseDemotes(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "a", ResourceClass: "konsole", InternalID: "{a}", StackingOrder: 1},
+			{Caption: "b", ResourceClass: "konsole", InternalID: "{b}", StackingOrder: 2},
+			{Caption: "c", ResourceClass: "konsole", InternalID: "{c}", StackingOrder: 3},
+		},
+		Active: "c",
+	}
+	tests := []struct {
+		order       string
+		reverse     bool
+		wantLowered []string
+		wantDemoted string
+	}{
+		{order: "stacking", reverse: false},
+		{order: "stacking", reverse: true, wantLowered: []string{"c"}},
+		{order: "mru", reverse: true, wantDemoted: "{c}"},
+		{order: "caption", reverse: true},
+	}
+	for _, tt := range tests {
+		params := testParams()
+		params.CycleOrder = tt.order
+		params.Reverse = tt.reverse
+		result := runKWinScript(t, params, fixture)
+		if got := result.calls("lower"); !reflect.DeepEqual(got, tt.wantLowered) {
+			t.Errorf("%s reverse=%v: lowered %v, want %v", tt.order, tt.reverse, got, tt.wantLowered)
+		}
+		if got := result.outcome(t).Demoted; got != tt.wantDemoted {
+			t.Errorf("%s reverse=%v: demoted %q, want %q", tt.order, tt.reverse, got, tt.wantDemoted)
+		}
+	}
+
+	// With c lowered, the next reverse press goes on to a rather than back to c.
+	params := testParams()
+	params.Reverse = true
+	result := runKWinScript(t, params, kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "c", ResourceClass: "konsole", StackingOrder: 1},
+			{Caption: "a", ResourceClass: "konsole", StackingOrder: 2},
+			{Caption: "b", ResourceClass: "konsole", StackingOrder: 3},
+		},
+		Active: "b",
+	})
+	if result.Active != "a" {
+		t.Errorf("second reverse press: active = %q, want a", result.Active)
+	}
+}
+
+func TestScriptMRU(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "a", ResourceClass: "konsole", InternalID: "{a}", StackingOrder: 1},
+			{Caption: "b", ResourceClass: "konsole", InternalID: "{b}", StackingOrder: 2},
+			{Caption: "c", ResourceClass: "konsole", InternalID: "{c}", StackingOrder: 3},
+		},
+		Active: "c",
+	}
+	tests := []struct {
+		name          string
+		order         string
+		times         string
+		wantActive    string
+		wantActivated string
+	}{
+		{name: "stacking order", order: "stacking", times: `{"{a}": 200, "{b}": 100}`, wantActive: "a"},
+		{name: "least recently activated", order: "mru", times: `{"{a}": 200, "{b}": 100}`, wantActive: "b", wantActivated: "{b}"},
+		{name: "never activated first", order: "mru", times: `{"{b}": 100}`, wantActive: "a", wantActivated: "{a}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.CycleOrder = tt.order
+			params.ActivationTimes = tt.times
+			result := runKWinScript(t, params, fixture)
+			if result.Active != tt.wantActive {
+				t.Errorf("active = %q, want %q", result.Active, tt.wantActive)
+			}
+			if got := result.outcome(t).Activated; got != tt.wantActivated {
+				t.Errorf("reported activated = %q, want %q", got, tt.wantActivated)
+			}
+		})
+	}
+}
+
+func TestScriptCycleOrderCaption(t *testing.T) {
+	windows := []fakeWindow{
+		{Caption: "gamma", ResourceClass: "konsole", StackingOrder: 1},
+		{Caption: "Alpha", ResourceClass: "konsole", StackingOrder: 2},
+		{Caption: "beta", ResourceClass: "konsole", StackingOrder: 3},
+		{Caption: "mail", ResourceClass: "thunderbird", StackingOrder: 4},
+	}
+	tests := []struct {
+		name    string
+		order   string
+		active  string
+		reverse bool
+		want    string
+	}{
+		{name: "stacking", order: "stacking", active: "Alpha", want: "gamma"},
+		{name: "next caption", order: "caption", active: "Alpha", want: "beta"},
+		{name: "wraps around", order: "caption", active: "gamma", want: "Alpha"},
+		{name: "reverse", order: "caption", active: "Alpha", reverse: true, want: "gamma"},
+		{name: "first press goes to the top", order: "caption", active: "mail", want: "beta"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.CycleOrder = tt.order
+			params.Reverse = tt.reverse
+			result := runKWinScript(t, params, kwinFixture{Windows: windows, Active: tt.active})
+			if result.Active != tt.want {
+				t.Errorf("active = %q, want %q", result.Active, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptPersistentCycle(t *testing.T) {
+	windows := []fakeWindow{
+		{Caption: "a", ResourceClass: "konsole", InternalID: "{a}", StackingOrder: 1},
+		{Caption: "b", ResourceClass: "konsole", InternalID: "{b}", StackingOrder: 2},
+		{Caption: "c", ResourceClass: "konsole", InternalID: "{c}", StackingOrder: 3},
+		{Caption: "mail", ResourceClass: "thunderbird", StackingOrder: 4},
+	}
+	tests := []struct {
+		name       string
+		active     string
+		lastCycled string
+		reverse    bool
+		wantAction string
+		wantActive string
+	}{
+		{name: "nothing remembered", active: "c", wantAction: "cycled", wantActive: "a"},
+		{name: "on from the remembered match", active: "c", lastCycled: "{a}", wantAction: "cycled", wantActive: "b"},
+		{name: "skips the active match", active: "c", lastCycled: "{b}", wantAction: "cycled", wantActive: "a"},
+		{name: "reverse", active: "c", lastCycled: "{b}", reverse: true, wantAction: "cycled", wantActive: "a"},
+		{name: "back to the remembered match", active: "mail", lastCycled: "{b}", wantAction: "activated", wantActive: "b"},
+		{name: "remembered match gone", active: "mail", lastCycled: "{x}", wantAction: "activated", wantActive: "c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.PersistentCycle = true
+			params.LastCycled = tt.lastCycled
+			params.Reverse = tt.reverse
+			result := runKWinScript(t, params, kwinFixture{Windows: windows, Active: tt.active})
+			outcome := result.outcome(t)
+			if outcome.Action != tt.wantAction {
+				t.Errorf("action = %q, want %q", outcome.Action, tt.wantAction)
+			}
+			if result.Active != tt.wantActive {
+				t.Errorf("active = %q, want %q", result.Active, tt.wantActive)
+			}
+			if want := "{" + tt.wantActive + "}"; outcome.Activated != want {
+				t.Errorf("reported activated = %q, want %q", outcome.Activated, want)
+			}
+		})
+	}
+}
+
+func TestScriptIndex(t *testing.T) {
+	windows := []fakeWindow{
+		{Caption: "a", ResourceClass: "konsole", StackingOrder: 1},
+		{Caption: "b", ResourceClass: "konsole", StackingOrder: 2},
+		{Caption: "c", ResourceClass: "konsole", StackingOrder: 3},
+		{Caption: "mail", ResourceClass: "thunderbird", StackingOrder: 4},
+	}
+	tests := []struct {
+		index      int
+		active     string
+		wantAction string
+		wantActive string
+	}{
+		{index: 1, active: "mail", wantAction: "activated", wantActive: "a"},
+		{index: 2, active: "c", wantAction: "activated", wantActive: "b"},
+		{index: -1, active: "mail", wantAction: "activated", wantActive: "c"},
+		{index: 3, active: "c", wantAction: "none", wantActive: "c"},
+		{index: 4, active: "mail", wantAction: "error", wantActive: "mail"},
+		{index: -4, active: "mail", wantAction: "error", wantActive: "mail"},
+	}
+	for _, tt := range tests {
+		params := testParams()
+		params.Index = tt.index
+		result := runKWinScript(t, params, kwinFixture{Windows: windows, Active: tt.active})
+		if got := result.outcome(t).Action; got != tt.wantAction {
+			t.Errorf("--index %d: action = %q, want %q", tt.index, got, tt.wantAction)
+		}
+		if result.Active != tt.wantActive {
+			t.Errorf("--index %d: active = %q, want %q", tt.index, result.Active, tt.wantActive)
+		}
+	}
+}
+
+func TestScriptPreferVisible(t *testing.T) {
+	tests := []struct {
+		name          string
+		preferVisible bool
+		prefer        string
+		allMinimized  bool
+		want          string
+	}{
+		{name: "off", prefer: "newest", want: "c"},
+		{name: "off, oldest", prefer: "oldest", want: "a"},
+		{name: "newest visible", preferVisible: true, prefer: "newest", want: "b"},
+		{name: "oldest visible", preferVisible: true, prefer: "oldest", want: "b"},
+		{name: "all minimized", preferVisible: true, prefer: "newest", allMinimized: true, want: "c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.PreferVisible = tt.preferVisible
+			params.Prefer = tt.prefer
+			result := runKWinScript(t, params, kwinFixture{
+				Windows: []fakeWindow{
+					{Caption: "a", ResourceClass: "konsole", StackingOrder: 1, Minimized: true},
+					{Caption: "b", ResourceClass: "konsole", StackingOrder: 2, Minimized: tt.allMinimized},
+					{Caption: "c", ResourceClass: "konsole", StackingOrder: 3, Minimized: true},
+					{Caption: "mail", ResourceClass: "thunderbird", StackingOrder: 4},
+				},
+				Active: "mail",
+			})
+			if result.Active != tt.want {
+				t.Errorf("active = %q, want %q", result.Active, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptPick(t *testing.T) {
+	tests := []struct {
+		name        string
+		windows     []fakeWindow
+		wantAction  string
+		wantChoices []windowInfo
+	}{
+		{
+			name: "several matches",
+			windows: []fakeWindow{
+				{Caption: "shell", ResourceClass: "konsole", InternalID: "{1}", PID: 10, Desktops: []string{"Two"}},
+				{Caption: "logs", ResourceClass: "konsole", InternalID: "{2}", PID: 11, OnAllDesktops: true},
+			},
+			wantAction: "picking",
+			wantChoices: []windowInfo{
+				{ResourceClass: "konsole", ResourceName: "konsole", Caption: "logs", InternalID: "{2}", PID: 11, Desktop: "all"},
+				{ResourceClass: "konsole", ResourceName: "konsole", Caption: "shell", InternalID: "{1}", PID: 10, Desktop: "Two"},
+			},
+		},
+		{
+			name: "single match",
+			windows: []fakeWindow{
+				{Caption: "shell", ResourceClass: "konsole"},
+			},
+			wantAction: "activated",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.Pick = true
+			result := runKWinScript(t, params, kwinFixture{Windows: tt.windows})
+			outcome := result.outcome(t)
+			if outcome.Action != tt.wantAction {
+				t.Errorf("action = %q, want %q", outcome.Action, tt.wantAction)
+			}
+			if !reflect.DeepEqual(outcome.Choices, tt.wantChoices) {
+				t.Errorf("choices = %+v, want %+v", outcome.Choices, tt.wantChoices)
+			}
+		})
+	}
+}
+
+func TestScriptPicked(t *testing.T) {
+	windows := []fakeWindow{
+		{Caption: "shell", ResourceClass: "konsole", InternalID: "{1}", StackingOrder: 1},
+		{Caption: "logs", ResourceClass: "konsole", InternalID: "{2}", StackingOrder: 2},
+		{Caption: "mail", ResourceClass: "thunderbird", InternalID: "{3}", StackingOrder: 3},
+	}
+	tests := []struct {
+		id         string
+		wantAction string
+		wantActive string
+	}{
+		{id: "{1}", wantAction: "activated", wantActive: "shell"},
+		{id: "{2}", wantAction: "activated", wantActive: "logs"},
+		{id: "{4}", wantAction: "no-match", wantActive: "mail"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			params := testParams()
+			params.Pick = true
+			params = pickedParams(params, tt.id)
+			result := runKWinScript(t, params, kwinFixture{Windows: windows, Active: "mail"})
+			if got := result.outcome(t).Action; got != tt.wantAction {
+				t.Errorf("action = %q, want %q", got, tt.wantAction)
+			}
+			if result.Active != tt.wantActive {
+				t.Errorf("active = %q, want %q", result.Active, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestScriptCycleCurrentScreen(t *testing.T) {
+	tests := []struct {
+		name       string
+		on         bool
+		bOutput    string
+		wantAction string
+		wantActive string
+	}{
+		{name: "off", bOutput: "DP-1", wantAction: "cycled", wantActive: "a"},
+		{name: "stays on the screen", on: true, bOutput: "DP-1", wantAction: "cycled", wantActive: "b"},
+		{name: "alone on the screen", on: true, bOutput: "HDMI-1", wantAction: "none", wantActive: "c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.CycleCurrentScreen = tt.on
+			result := runKWinScript(t, params, kwinFixture{
+				Windows: []fakeWindow{
+					{Caption: "a", ResourceClass: "konsole", StackingOrder: 1, Output: "HDMI-1"},
+					{Caption: "b", ResourceClass: "konsole", StackingOrder: 2, Output: tt.bOutput},
+					{Caption: "c", ResourceClass: "konsole", StackingOrder: 3, Output: "DP-1"},
+				},
+				Active: "c",
+			})
+			if got := result.outcome(t).Action; got != tt.wantAction {
+				t.Errorf("action = %q, want %q", got, tt.wantAction)
+			}
+			if result.Active != tt.wantActive {
+				t.Errorf("active = %q, want %q", result.Active, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestScriptCycleSamePID(t *testing.T) {
+	tests := []struct {
+		name       string
+		on         bool
+		bPID       int
+		wantAction string
+		wantActive string
+	}{
+		{name: "off", bPID: 20, wantAction: "cycled", wantActive: "a"},
+		{name: "stays with the process", on: true, bPID: 20, wantAction: "cycled", wantActive: "b"},
+		{name: "only window of the process", on: true, bPID: 30, wantAction: "none", wantActive: "c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.CycleSamePID = tt.on
+			result := runKWinScript(t, params, kwinFixture{
+				Windows: []fakeWindow{
+					{Caption: "a", ResourceClass: "konsole", StackingOrder: 1, PID: 10},
+					{Caption: "b", ResourceClass: "konsole", StackingOrder: 2, PID: tt.bPID},
+					{Caption: "c", ResourceClass: "konsole", StackingOrder: 3, PID: 20},
+				},
+				Active: "c",
+			})
+			if got := result.outcome(t).Action; got != tt.wantAction {
+				t.Errorf("action = %q, want %q", got, tt.wantAction)
+			}
+			if result.Active != tt.wantActive {
+				t.Errorf("active = %q, want %q", result.Active, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestScriptToggleBack(t *testing.T) {
+	windows := []fakeWindow{
+		{Caption: "mail", ResourceClass: "thunderbird", InternalID: "{mail}"},
+		{Caption: "browser", ResourceClass: "firefox", InternalID: "{browser}"},
+		{Caption: "shell", ResourceClass: "konsole", InternalID: "{shell}"},
+	}
+	tests := []struct {
+		name         string
+		several      bool // a second konsole window matches too
+		active       string
+		previous     string
+		wantAction   string
+		wantActive   string
+		wantPrevious string
+	}{
+		{name: "jump records where it came from", active: "mail", wantAction: "activated", wantActive: "shell", wantPrevious: "{mail}"},
+		{name: "second press goes back", active: "shell", previous: "{mail}", wantAction: "toggled-back", wantActive: "mail"},
+		{name: "nothing to go back to", active: "shell", wantAction: "none", wantActive: "shell"},
+		{name: "previous window closed", active: "shell", previous: "{gone}", wantAction: "none", wantActive: "shell"},
+		{name: "several matches, jump", several: true, active: "mail", wantAction: "activated", wantActive: "logs", wantPrevious: "{mail}"},
+		{name: "several matches, second press goes back", several: true, active: "shell", previous: "{mail}", wantAction: "toggled-back", wantActive: "mail"},
+		{name: "several matches, back from the other one", several: true, active: "logs", previous: "{mail}", wantAction: "toggled-back", wantActive: "mail"},
+		{name: "several matches, nothing to go back to", several: true, active: "shell", wantAction: "cycled", wantActive: "logs"},
+		{name: "several matches, previous is a match", several: true, active: "shell", previous: "{logs}", wantAction: "cycled", wantActive: "logs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.ToggleBack = true
+			params.PreviousWindow = tt.previous
+			fixture := kwinFixture{Windows: windows, Active: tt.active}
+			if tt.several {
+				fixture.Windows = append(windows[:len(windows):len(windows)], fakeWindow{Caption: "logs", ResourceClass: "konsole", InternalID: "{logs}"})
+			}
+			result := runKWinScript(t, params, fixture)
+			outcome := result.outcome(t)
+			if outcome.Action != tt.wantAction {
+				t.Errorf("action = %q, want %q", outcome.Action, tt.wantAction)
+			}
+			if result.Active != tt.wantActive {
+				t.Errorf("active = %q, want %q", result.Active, tt.wantActive)
+			}
+			if outcome.Previous != tt.wantPrevious {
+				t.Errorf("reported previous = %q, want %q", outcome.Previous, tt.wantPrevious)
+			}
+		})
+	}
+}
+
+func TestScriptFocusAfterLaunch(t *testing.T) {
+	added := []fakeWindow{
+		{Caption: "browser", ResourceClass: "firefox"},
+		{Caption: "shell", ResourceClass: "konsole"},
+		{Caption: "second shell", ResourceClass: "konsole"},
+	}
+	fixture := kwinFixture{
+		Windows: []fakeWindow{{Caption: "mail", ResourceClass: "thunderbird"}},
+		Active:  "mail",
+		Added:   added,
+	}
+	tests := []struct {
+		name        string
+		await       bool
+		focus       bool
+		adjust      bool
+		pids        bool // --cmdline, resolved before the launch
+		openedAfter bool // --opened-within, before the window was recorded
+		other       bool
+		late        bool // the windows open after the launch timeout
+		action      string
+		wantActions []string
+		wantActive  string
+		wantMax     []string
+		wantUnload  bool
+	}{
+		{name: "nothing launched", wantActions: []string{"no-match"}, wantActive: "mail"},
+		// jumpkwapp does not wait without --focus-after-launch, so the script unloads itself.
+		{name: "activated", await: true, wantActions: []string{"no-match"}, wantActive: "shell", wantUnload: true},
+		{name: "activated and adjusted", await: true, adjust: true, wantActions: []string{"no-match"}, wantActive: "shell", wantMax: []string{"shell"}, wantUnload: true},
+		{name: "too late", await: true, adjust: true, late: true, wantActions: []string{"no-match"}, wantActive: "mail", wantUnload: true},
+		{name: "first matching window", await: true, focus: true, wantActions: []string{"no-match", "launched-focused"}, wantActive: "shell"},
+		{name: "not when nothing is launched", await: true, focus: true, action: "close", wantActions: []string{"no-match"}, wantActive: "mail"},
+		{name: "focused and adjusted", await: true, focus: true, adjust: true, wantActions: []string{"no-match", "launched-focused"}, wantActive: "shell", wantMax: []string{"shell"}},
+		{name: "process filters", await: true, focus: true, pids: true, wantActions: []string{"no-match", "launched-focused"}, wantActive: "shell"},
+		{name: "opened within", await: true, focus: true, openedAfter: true, wantActions: []string{"no-match", "launched-focused"}, wantActive: "shell"},
+		// KWin focuses each new window, so the second shell ends up active.
+		{name: "other", await: true, focus: true, other: true, wantActions: []string{"no-match", "launched-focused"}, wantActive: "second shell"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.FocusAfterLaunch = tt.focus
+			params.AwaitLaunched = tt.await
+			params.Maximize = tt.adjust
+			params.Other = tt.other
+			if tt.pids {
+				params.RestrictPIDs = true
+				params.PIDs = []int{4242}
+			}
+			if tt.openedAfter {
+				params.OpenedAfter = 1
+			}
+			params.Action = tt.action
+			fixture := fixture
+			fixture.FocusAdded = tt.other
+			if tt.late {
+				fixture.Added = nil
+				for _, window := range added {
+					window.AddedAt = int(params.LaunchTimeout) + 1000
+					fixture.Added = append(fixture.Added, window)
+				}
+			}
+			result := runKWinScript(t, params, fixture)
+			if got := result.actions(t); !reflect.DeepEqual(got, tt.wantActions) {
+				t.Errorf("reported %q, want %q", got, tt.wantActions)
+			}
+			if result.Active != tt.wantActive {
+				t.Errorf("active = %q, want %q", result.Active, tt.wantActive)
+			}
+			if got := result.calls("maximize"); !reflect.DeepEqual(got, tt.wantMax) {
+				t.Errorf("maximized %v, want %v", got, tt.wantMax)
+			}
+			var unloaded []string
+			for _, call := range result.DBus {
+				if call.Method == "unloadScript" {
+					unloaded = append(unloaded, call.Args...)
+				}
+			}
+			if want := []string{params.PluginName}; (unloaded != nil) != tt.wantUnload || tt.wantUnload && !reflect.DeepEqual(unloaded, want) {
+				t.Errorf("unloaded %q, want unloaded %v", unloaded, tt.wantUnload)
+			}
+		})
+	}
+}
+
+func TestScriptOpenedWithin(t *testing.T) {
+	windows := []fakeWindow{
+		{Caption: "old shell", ResourceClass: "konsole", InternalID: "{1}", StackingOrder: 1},
+		{Caption: "new shell", ResourceClass: "konsole", InternalID: "{2}", StackingOrder: 2},
+		{Caption: "untracked shell", ResourceClass: "konsole", InternalID: "{3}", StackingOrder: 3},
+		{Caption: "mail", ResourceClass: "thunderbird", InternalID: "{4}", StackingOrder: 4},
+	}
+	times := `{"{1}": 1000, "{2}": 5000, "{4}": 6000}`
+	tests := []struct {
+		name        string
+		openedAfter int64
+		wantActive  string
+		wantMatched int
+	}{
+		{name: "off", wantActive: "untracked shell", wantMatched: 3},
+		{name: "recent", openedAfter: 4000, wantActive: "new shell", wantMatched: 1},
+		{name: "all tracked", openedAfter: 1000, wantActive: "new shell", wantMatched: 2},
+		{name: "none", openedAfter: 5001, wantActive: "mail", wantMatched: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.OpenTimes = times
+			params.OpenedAfter = tt.openedAfter
+			result := runKWinScript(t, params, kwinFixture{Windows: windows, Active: "mail"})
+			if got := result.outcome(t).Matched; got != tt.wantMatched {
+				t.Errorf("matched %d, want %d", got, tt.wantMatched)
+			}
+			if result.Active != tt.wantActive {
+				t.Errorf("active = %q, want %q", result.Active, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestScriptPreferOpened(t *testing.T) {
+	// The stacking order and the opening times disagree: the shell opened
+	// last is at the bottom of the stack.
+	windows := []fakeWindow{
+		{Caption: "new shell", ResourceClass: "konsole", InternalID: "{1}", StackingOrder: 1},
+		{Caption: "old shell", ResourceClass: "konsole", InternalID: "{2}", StackingOrder: 2},
+		{Caption: "untracked shell", ResourceClass: "konsole", InternalID: "{3}", StackingOrder: 3},
+		{Caption: "mail", ResourceClass: "thunderbird", InternalID: "{4}", StackingOrder: 4},
+	}
+	times := `{"{1}": 5000, "{2}": 1000}`
+	tests := []struct {
+		name       string
+		prefer     string
+		opened     bool
+		action     string
+		wantActive string
+	}{
+		{name: "newest in the stack", prefer: "newest", wantActive: "untracked shell"},
+		{name: "oldest in the stack", prefer: "oldest", wantActive: "new shell"},
+		{name: "newest opened", prefer: "newest", opened: true, wantActive: "new shell"},
+		// Windows open before the tracker started count as the oldest.
+		{name: "oldest opened", prefer: "oldest", opened: true, wantActive: "untracked shell"},
+		{name: "action on the newest opened", prefer: "newest", opened: true, action: "close", wantActive: "mail"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.Prefer = tt.prefer
+			params.PreferOpened = tt.opened
+			params.OpenTimes = times
+			params.Action = tt.action
+			result := runKWinScript(t, params, kwinFixture{Windows: windows, Active: "mail"})
+			if result.Active != tt.wantActive {
+				t.Errorf("active = %q, want %q", result.Active, tt.wantActive)
+			}
+			if tt.action == "close" {
+				if got, want := result.calls("close"), []string{"new shell"}; !reflect.DeepEqual(got, want) {
+					t.Errorf("closed %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestScriptUrgent(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "old shell", ResourceClass: "konsole", StackingOrder: 1},
+			{Caption: "shell", ResourceClass: "konsole", StackingOrder: 2},
+			{Caption: "mail", ResourceClass: "thunderbird", StackingOrder: 3},
+		},
+		Active: "mail",
+	}
+	for _, prefer := range []string{"newest", "oldest"} {
+		t.Run(prefer, func(t *testing.T) {
+			params := testParams()
+			params.Action = "urgent"
+			params.Prefer = prefer
+			result := runKWinScript(t, params, fixture)
+			if got := result.outcome(t).Action; got != "demanded-attention" {
+				t.Errorf("action = %q, want demanded-attention", got)
+			}
+			if result.Active != "mail" {
+				t.Errorf("active = %q, want mail", result.Active)
+			}
+			want := map[string]string{"newest": "shell", "oldest": "old shell"}[prefer]
+			for caption, state := range result.Windows {
+				if state.DemandsAttention != (caption == want) {
+					t.Errorf("%s demands attention = %v", caption, state.DemandsAttention)
+				}
+			}
+		})
+	}
+}
+
+func TestScriptIncludeDialogs(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "document", ResourceClass: "libreoffice"},
+			{Caption: "Options", ResourceClass: "libreoffice-dialog", TransientFor: "document"},
+			{Caption: "Discard changes?", ResourceClass: "libreoffice-dialog", TransientFor: "Options"},
+			{Caption: "Find", ResourceClass: "libreoffice-dialog", TransientFor: "document", StackingOrder: 1},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+		},
+		Active: "mail",
+	}
+	tests := []struct {
+		name           string
+		includeDialogs bool
+		want           []string
+	}{
+		{name: "main window", want: []string{"document"}},
+		{name: "topmost nested dialog", includeDialogs: true, want: []string{"Discard changes?"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.ClassNames = []string{"libreoffice"}
+			params.IncludeDialogs = tt.includeDialogs
+			result := runKWinScript(t, params, fixture)
+			if got := result.activated(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("activated %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptCaptionRegex(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "YouTube - Mozilla Firefox", ResourceClass: "firefox"},
+			{Caption: "YouTube", ResourceClass: "chromium"},
+			{Caption: "shell", ResourceClass: "konsole"},
+		},
+		Active: "shell",
+	}
+	tests := []struct {
+		pattern   string
+		flags     string
+		fullMatch bool
+		want      int
+	}{
+		{pattern: "youtube", flags: "i", want: 2},
+		{pattern: "youtube", flags: "", want: 0},
+		{pattern: "YouTube", flags: "", want: 2},
+		{pattern: "youtube", flags: "i", fullMatch: true, want: 1},
+		{pattern: "YouTube|shell", flags: "", fullMatch: true, want: 2},
+	}
+	for _, tt := range tests {
+		params := testParams()
+		params.ClassNames = nil
+		params.CaptionPattern = tt.pattern
+		params.CaptionFlags = tt.flags
+		params.CaptionFullMatch = tt.fullMatch
+		result := runKWinScript(t, params, fixture)
+		if got := result.outcome(t).Matched; got != tt.want {
+			t.Errorf("-fa %q --caption-flags %q fullMatch=%v: matched %d windows, want %d", tt.pattern, tt.flags, tt.fullMatch, got, tt.want)
+		}
+	}
+}
+
+func TestScriptOther(t *testing.T) {
+	windows := []fakeWindow{
+		{Caption: "shell", ResourceClass: "konsole"},
+		{Caption: "logs", ResourceClass: "konsole"},
+	}
+	tests := []struct {
+		name   string
+		active string
+		other  bool
+		want   scriptOutcome
+	}{
+		{name: "cycles past the active match", active: "logs", want: scriptOutcome{Action: "cycled", Matched: 2}},
+		{name: "other skips the active match", active: "logs", other: true, want: scriptOutcome{Action: "activated", Matched: 1}},
+		{name: "no other match", active: "shell", other: true, want: scriptOutcome{Action: "activated", Matched: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.Other = tt.other
+			result := runKWinScript(t, params, kwinFixture{Windows: windows, Active: tt.active})
+			if got := result.outcome(t); got.Action != tt.want.Action || got.Matched != tt.want.Matched {
+				t.Errorf("outcome = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	params := testParams()
+	params.Other = true
+	result := runKWinScript(t, params, kwinFixture{Windows: windows[:1], Active: "shell"})
+	if got := result.shouldLaunch(); got != "true" {
+		t.Errorf("only the active window matches: ShouldLaunch(%q), want %q", got, "true")
+	}
+}
+
+func TestScriptSmartClass(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "terminal", ResourceClass: "org.kde.konsole"},
+			{Caption: "browser", ResourceClass: "Google-chrome"},
+			{Caption: "editor", ResourceClass: "Code", DesktopFile: "code-oss"},
+			{Caption: "chat", ResourceClass: "telegram-desktop"},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+		},
+		Active: "mail",
+	}
+	tests := []struct {
+		class      string
+		smartClass bool
+		want       []string
+	}{
+		{class: "konsole", smartClass: false},
+		{class: "konsole", smartClass: true, want: []string{"terminal"}},
+		{class: "org.kde.Konsole.desktop", smartClass: true, want: []string{"terminal"}},
+		{class: "chrome", smartClass: true, want: []string{"browser"}},
+		{class: "code-url-handler", smartClass: true, want: []string{"editor"}},
+		{class: "TelegramDesktop", smartClass: true, want: []string{"chat"}},
+		{class: "gvim", smartClass: true},
+	}
+	for _, tt := range tests {
+		params := testParams()
+		params.ClassNames = []string{tt.class}
+		params.SmartClass = tt.smartClass
+		result := runKWinScript(t, params, fixture)
+		if got := result.activated(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("-f %q smartClass=%v: activated %q, want %q", tt.class, tt.smartClass, got, tt.want)
+		}
+	}
+}
+
+func TestScriptRestrictPIDs(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "work", ResourceClass: "firefox", PID: 100},
+			{Caption: "personal", ResourceClass: "firefox", PID: 200},
+			{Caption: "shell", ResourceClass: "konsole", PID: 300},
+		},
+		Active: "shell",
+	}
+	tests := []struct {
+		name     string
+		restrict bool
+		pids     []int
+		want     int
+	}{
+		{name: "no restriction", want: 2},
+		{name: "one process", restrict: true, pids: []int{200}, want: 1},
+		{name: "several processes", restrict: true, pids: []int{100, 200, 300}, want: 2},
+		{name: "no process matched", restrict: true, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.ClassNames = []string{"firefox"}
+			params.RestrictPIDs = tt.restrict
+			params.PIDs = tt.pids
+			result := runKWinScript(t, params, fixture)
+			if got := result.outcome(t).Matched; got != tt.want {
+				t.Errorf("matched %d windows, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptMatch(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "Gmail - Mozilla Firefox", ResourceClass: "firefox"},
+			{Caption: "Inbox - Mozilla Firefox", ResourceClass: "firefox", Minimized: true},
+			{Caption: "it's \"quoted\"", ResourceClass: "kate"},
+			{Caption: "shell", ResourceClass: "konsole", PID: 42},
+		},
+		Active: "shell",
+	}
+	tests := []struct {
+		match string
+		want  int
+	}{
+		{match: "class=firefox", want: 2},
+		{match: `class=firefox && caption~"gmail"`, want: 1},
+		{match: "class=firefox && !minimized", want: 1},
+		{match: "minimized || active", want: 2},
+		{match: `caption="it's \"quoted\""`, want: 1},
+		{match: "pid=42", want: 1},
+		{match: "class!=firefox && !special", want: 2},
+	}
+	for _, tt := range tests {
+		match, err := compileMatch(tt.match)
+		if err != nil {
+			t.Fatalf("compileMatch(%q): %v", tt.match, err)
+		}
+		params := testParams()
+		params.ClassNames = nil
+		params.MatchExpr = match
+		result := runKWinScript(t, params, fixture)
+		if got := result.outcome(t).Matched; got != tt.want {
+			t.Errorf("--match %q: matched %d windows, want %d", tt.match, got, tt.want)
+		}
+	}
+}
+
+func TestScriptPWA(t *testing.T) {
+	const chromiumID = "agimnkijcaahngcdmfeangaknmldooml"
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "YouTube", ResourceClass: "Google-chrome", ResourceName: "crx_" + chromiumID},
+			{Caption: "YouTube", ResourceClass: "chrome-" + chromiumID + "-Default", DesktopFile: "chrome-" + chromiumID + "-Default"},
+			{Caption: "Element", ResourceClass: "FFPWA-01HQ8ZK4M7X9V2B3C4D5E6F7G8"},
+			{Caption: "YouTube - Mozilla Firefox", ResourceClass: "firefox"},
+			{Caption: "shell", ResourceClass: "konsole"},
+		},
+		Active: "shell",
+	}
+	tests := []struct {
+		app  string
+		want int
+	}{
+		{app: chromiumID, want: 2},
+		{app: "01HQ8ZK4M7X9V2B3C4D5E6F7G8", want: 1},
+		{app: "youtube", want: 2},
+		{app: "Element", want: 1},
+		{app: "Mozilla", want: 0},
+	}
+	for _, tt := range tests {
+		match, err := compileMatch(pwaMatch(tt.app))
+		if err != nil {
+			t.Fatalf("compileMatch: %v", err)
+		}
+		params := testParams()
+		params.ClassNames = nil
+		params.MatchExpr = match
+		result := runKWinScript(t, params, fixture)
+		if got := result.outcome(t).Matched; got != tt.want {
+			t.Errorf("--pwa %q: matched %d windows, want %d", tt.app, got, tt.want)
+		}
+	}
+}
+
+func TestScriptVisibleOnly(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "covered", ResourceClass: "konsole", StackingOrder: 1, FrameGeometry: &rect{X: 200, Y: 200, Width: 400, Height: 300}},
+			{Caption: "peeking", ResourceClass: "konsole", StackingOrder: 2, FrameGeometry: &rect{X: 900, Y: 700, Width: 400, Height: 300}},
+			{Caption: "cover", ResourceClass: "thunderbird", StackingOrder: 3, FrameGeometry: &rect{Width: 1000, Height: 800}},
+			{Caption: "front", ResourceClass: "konsole", StackingOrder: 4, FrameGeometry: &rect{X: 1100, Y: 100, Width: 600, Height: 600}},
+			{Caption: "minimized", ResourceClass: "konsole", Minimized: true},
+			{Caption: "off screen", ResourceClass: "konsole", FrameGeometry: &rect{X: 5000, Y: 100, Width: 600, Height: 600}},
+			{Caption: "other desktop", ResourceClass: "konsole", Desktops: []string{"Two"}},
+			{Caption: "other activity", ResourceClass: "konsole", Activities: []string{"home"}},
+		},
+		Active:          "cover",
+		CurrentActivity: "work",
+	}
+	for _, visibleOnly := range []bool{false, true} {
+		params := testParams()
+		params.VisibleOnly = visibleOnly
+		result := runKWinScript(t, params, fixture)
+		want := 7
+		if visibleOnly {
+			want = 2
+		}
+		if got := result.outcome(t).Matched; got != want {
+			t.Errorf("visibleOnly=%v: matched %d windows, want %d", visibleOnly, got, want)
+		}
+	}
+}
+
+func TestScriptTaskbarGuards(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "shell", ResourceClass: "konsole"},
+			{Caption: "dropdown", ResourceClass: "konsole", SkipTaskbar: true},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+		},
+		Active: "mail",
+	}
+	tests := []struct {
+		name               string
+		includeSkipTaskbar bool
+		want               string
+	}{
+		{name: "default", want: "shell"},
+		{name: "include skip taskbar", includeSkipTaskbar: true, want: "dropdown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.IncludeSkipTaskbar = tt.includeSkipTaskbar
+			result := runKWinScript(t, params, fixture)
+			if got := result.activated(); !reflect.DeepEqual(got, []string{tt.want}) {
+				t.Errorf("activated %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptReportsErrors(t *testing.T) {
+	params := testParams()
+	params.ClassNames = nil
+	params.ClassRegex = "konsole("
+	result := runKWinScript(t, params, kwinFixture{
+		Windows: []fakeWindow{{Caption: "shell", ResourceClass: "konsole"}},
+	})
+	if got := result.shouldLaunch(); got != "error" {
+		t.Errorf("ShouldLaunch(%q), want %q", got, "error")
+	}
+	if got := result.activated(); len(got) != 0 {
+		t.Errorf("activated %q after an error", got)
+	}
+}
+
+func TestScriptActivateRetries(t *testing.T) {
+	windows := []fakeWindow{
+		{Caption: "shell", ResourceClass: "konsole"},
+		{Caption: "mail", ResourceClass: "thunderbird"},
+	}
+	tests := []struct {
+		name       string
+		retries    int
+		ignored    int
+		wantTries  int
+		wantActive string
+	}{
+		{name: "no retries", retries: 0, ignored: 1, wantTries: 1, wantActive: "mail"},
+		{name: "retries until focused", retries: 3, ignored: 2, wantTries: 3, wantActive: "shell"},
+		{name: "gives up", retries: 1, ignored: 5, wantTries: 2, wantActive: "mail"},
+		{name: "focused at once", retries: 3, ignored: 0, wantTries: 1, wantActive: "shell"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.ActivateRetries = tt.retries
+			params.ActivateRetryDelay = 50
+			result := runKWinScript(t, params, kwinFixture{Windows: windows, Active: "mail", IgnoredActivations: tt.ignored})
+			if got := len(result.activated()); got != tt.wantTries {
+				t.Errorf("activation requested %d times, want %d", got, tt.wantTries)
+			}
+			if result.Active != tt.wantActive {
+				t.Errorf("active window %q, want %q", result.Active, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestScriptPrefer(t *testing.T) {
+	windows := []fakeWindow{
+		{Caption: "middle", ResourceClass: "konsole", StackingOrder: 2},
+		{Caption: "bottom", ResourceClass: "konsole", StackingOrder: 1},
+		{Caption: "top", ResourceClass: "konsole", StackingOrder: 3},
+		{Caption: "mail", ResourceClass: "thunderbird", StackingOrder: 4},
+	}
+	tests := []struct {
+		prefer string
+		active string
+		want   string
+	}{
+		{prefer: "newest", active: "mail", want: "top"},
+		{prefer: "oldest", active: "mail", want: "bottom"},
+		// Once a match is active, cycling is the same either way.
+		{prefer: "oldest", active: "top", want: "bottom"},
+		{prefer: "newest", active: "top", want: "bottom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefer+" from "+tt.active, func(t *testing.T) {
+			params := testParams()
+			params.Prefer = tt.prefer
+			result := runKWinScript(t, params, kwinFixture{Windows: windows, Active: tt.active})
+			if got := result.activated(); !reflect.DeepEqual(got, []string{tt.want}) {
+				t.Errorf("activated %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptCaptionFirst(t *testing.T) {
+	fixture := kwinFixture{
+		Windows: []fakeWindow{
+			{Caption: "vim notes", ResourceClass: "kate"},
+			{Caption: "shell", ResourceClass: "konsole"},
+			{Caption: "mail", ResourceClass: "thunderbird"},
+		},
+		Active: "mail",
+	}
+	tests := []struct {
+		name         string
+		title        string
+		captionFirst bool
+		want         string
+	}{
+		{name: "class filter wins by default", title: "vim", want: "shell"},
+		{name: "caption match wins", title: "vim", captionFirst: true, want: "vim notes"},
+		{name: "falls back to the class filter", title: "emacs", captionFirst: true, want: "shell"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.TitleSubstring = tt.title
+			params.CaptionFirst = tt.captionFirst
+			result := runKWinScript(t, params, fixture)
+			if got := result.activated(); !reflect.DeepEqual(got, []string{tt.want}) {
+				t.Errorf("activated %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptOutcome(t *testing.T) {
+	konsoles := []fakeWindow{
+		{Caption: "shell", ResourceClass: "konsole"},
+		{Caption: "logs", ResourceClass: "konsole"},
+		{Caption: "mail", ResourceClass: "thunderbird"},
+	}
+	tests := []struct {
+		name    string
+		modify  func(p *scriptParams)
+		fixture kwinFixture
+		want    scriptOutcome
+	}{
+		{
+			name:    "no match",
+			modify:  func(p *scriptParams) { p.ClassNames = []string{"kate"} },
+			fixture: kwinFixture{Windows: konsoles},
+			want:    scriptOutcome{Action: "no-match"},
+		},
+		{
+			name:    "activated",
+			fixture: kwinFixture{Windows: konsoles, Active: "mail"},
+			want:    scriptOutcome{Action: "activated", Matched: 2},
+		},
+		{
+			name:    "cycled",
+			fixture: kwinFixture{Windows: konsoles, Active: "logs"},
+			want:    scriptOutcome{Action: "cycled", Matched: 2},
+		},
+		{
+			name:    "raised all",
+			modify:  func(p *scriptParams) { p.RaiseAll = true },
+			fixture: kwinFixture{Windows: konsoles},
+			want:    scriptOutcome{Action: "raised-all", Matched: 2},
+		},
+		{
+			name:    "already active",
+			modify:  func(p *scriptParams) { p.ClassNames = []string{"thunderbird"} },
+			fixture: kwinFixture{Windows: konsoles, Active: "mail"},
+			want:    scriptOutcome{Action: "none", Matched: 1},
+		},
+		{
+			name: "minimized",
+			modify: func(p *scriptParams) {
+				p.ClassNames = []string{"thunderbird"}
+				p.Toggle = true
+			},
+			fixture: kwinFixture{Windows: konsoles, Active: "mail"},
+			want:    scriptOutcome{Action: "minimized", Matched: 1},
+		},
+		{
+			name:    "error",
+			modify:  func(p *scriptParams) { p.ClassNames, p.ClassRegex = nil, "(" },
+			fixture: kwinFixture{Windows: konsoles},
+			want:    scriptOutcome{Action: "error"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			if tt.modify != nil {
+				tt.modify(&params)
+			}
+			if got := runKWinScript(t, params, tt.fixture).outcome(t); got.Action != tt.want.Action || got.Matched != tt.want.Matched {
+				t.Errorf("outcome = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptPreferCurrentScreen(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefer bool
+		screen string
+		want   string
+	}{
+		{name: "off", screen: "HDMI-1", want: "left"},
+		{name: "match on the focused screen", prefer: true, screen: "HDMI-1", want: "right"},
+		{name: "top match is on the focused screen", prefer: true, screen: "DP-1", want: "left"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.PreferCurrentScreen = tt.prefer
+			result := runKWinScript(t, params, kwinFixture{
+				Windows: []fakeWindow{
+					{Caption: "right", ResourceClass: "konsole", Output: "HDMI-1"},
+					{Caption: "left", ResourceClass: "konsole", Output: "DP-1"},
+				},
+				ActiveScreen: tt.screen,
+			})
+			if got := result.activated(); !reflect.DeepEqual(got, []string{tt.want}) {
+				t.Errorf("activated %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("no match on the focused screen", func(t *testing.T) {
+		params := testParams()
+		params.PreferCurrentScreen = true
+		result := runKWinScript(t, params, kwinFixture{
+			Windows: []fakeWindow{
+				{Caption: "left", ResourceClass: "konsole", Output: "DP-1"},
+				{Caption: "right", ResourceClass: "thunderbird", Output: "HDMI-1"},
+			},
+			ActiveScreen: "HDMI-1",
+		})
+		if got := result.activated(); !reflect.DeepEqual(got, []string{"left"}) {
+			t.Errorf("activated %q, want [left]", got)
+		}
+	})
+
+	t.Run("falls back once the focused screen is exhausted", func(t *testing.T) {
+		params := testParams()
+		params.PreferCurrentScreen = true
+		result := runKWinScript(t, params, kwinFixture{
+			Windows: []fakeWindow{
+				{Caption: "left", ResourceClass: "konsole", Output: "DP-1"},
+				{Caption: "right", ResourceClass: "konsole", Output: "HDMI-1"},
+			},
+			Active:       "right",
+			ActiveScreen: "HDMI-1",
+		})
+		if got := result.outcome(t).Action; got != "cycled" {
+			t.Errorf("action = %q, want cycled", got)
+		}
+		if result.Active != "left" {
+			t.Errorf("active = %q, want left", result.Active)
+		}
+	})
+}
+
+func TestScriptAnnotated(t *testing.T) {
+	params := testParams()
+	params.TitleSubstring = "it's\nhere"
+	script := annotateScript(params, render(t, params))
+	result := runScript(t, script, kwinFixture{Windows: []fakeWindow{{Caption: "shell", ResourceClass: "konsole"}}})
+	if got := result.activated(); !reflect.DeepEqual(got, []string{"shell"}) {
+		t.Errorf("annotated script activated %q, want [shell]", got)
+	}
+}
+
+func TestScriptIgnoreCase(t *testing.T) {
+	fixture := kwinFixture{Windows: []fakeWindow{
+		{Caption: "shell", ResourceClass: "org.kde.Konsole"},
+		{Caption: "mail", ResourceClass: "thunderbird"},
+	}}
+	tests := []struct {
+		className  string
+		classRegex string
+		ignoreCase bool
+		want       []string
+	}{
+		{className: "org.kde.konsole", want: nil},
+		{className: "org.kde.konsole", ignoreCase: true, want: []string{"shell"}},
+		{className: "ORG.KDE.KONSOLE", ignoreCase: true, want: []string{"shell"}},
+		{className: "konsole", ignoreCase: true, want: nil},
+		{classRegex: "konsole$", want: nil},
+		{classRegex: "konsole$", ignoreCase: true, want: []string{"shell"}},
+	}
+	for _, tt := range tests {
+		params := testParams()
+		params.ClassNames = classNames(tt.className)
+		params.ClassRegex = tt.classRegex
+		params.IgnoreCase = tt.ignoreCase
+		result := runKWinScript(t, params, fixture)
+		if got := result.activated(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("-f %q -fr %q, ignoreCase=%v: activated %q, want %q", tt.className, tt.classRegex, tt.ignoreCase, got, tt.want)
+		}
+	}
+}
+
+func TestScriptCurrentDesktop(t *testing.T) {
+	windows := []fakeWindow{
+		{Caption: "here", ResourceClass: "konsole", Desktops: []string{"One"}},
+		{Caption: "sticky", ResourceClass: "konsole", Desktops: []string{"Two"}, OnAllDesktops: true},
+		{Caption: "there", ResourceClass: "konsole", Desktops: []string{"Two"}},
+	}
+	tests := []struct {
+		name    string
+		current string
+		copies  bool
+		want    []string
+	}{
+		{name: "same objects", current: "One", want: []string{"here", "sticky"}},
+		{name: "copied desktop objects", current: "One", copies: true, want: []string{"here", "sticky"}},
+		{name: "other desktop", current: "Two", copies: true, want: []string{"sticky", "there"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.CurrentDesktopOnly = true
+			params.RaiseAll = true
+			result := runKWinScript(t, params, kwinFixture{Windows: windows, CurrentDesktop: tt.current, DesktopCopies: tt.copies})
+			if got := result.calls("raise"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matched %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptPID(t *testing.T) {
+	fixture := kwinFixture{Windows: []fakeWindow{
+		{Caption: "shell", ResourceClass: "konsole", PID: 100},
+		{Caption: "logs", ResourceClass: "konsole", PID: 200},
+		{Caption: "mail", ResourceClass: "thunderbird", PID: 300},
+	}}
+	tests := []struct {
+		name      string
+		className string
+		pid       int
+		want      []string
+	}{
+		{name: "pid alone", pid: 300, want: []string{"mail"}},
+		{name: "pid narrows the class filter", className: "konsole", pid: 100, want: []string{"shell"}},
+		{name: "pid of another class", className: "konsole", pid: 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams()
+			params.ClassNames = classNames(tt.className)
+			params.PID = tt.pid
+			result := runKWinScript(t, params, fixture)
+			if got := result.activated(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("activated %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptDesktopFile(t *testing.T) {
+	fixture := kwinFixture{Windows: []fakeWindow{
+		{Caption: "browser", ResourceClass: "firefox", DesktopFile: "org.mozilla.firefox"},
+		{Caption: "nightly", ResourceClass: "firefox", DesktopFile: "firefox-nightly"},
+		{Caption: "mail", ResourceClass: "thunderbird"},
+	}}
+	tests := []struct {
+		desktopFile string
+		want        []string
+	}{
+		{desktopFile: "org.mozilla.firefox", want: []string{"browser"}},
+		{desktopFile: "firefox-nightly", want: []string{"nightly"}},
+		{desktopFile: "org.mozilla"},
+	}
+	for _, tt := range tests {
+		params := testParams()
+		params.ClassNames = nil
+		params.DesktopFile = tt.desktopFile
+		result := runKWinScript(t, params, fixture)
+		if got := result.activated(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("--desktop-file %s: activated %q, want %q", tt.desktopFile, got, tt.want)
+		}
+	}
+}
+
+func TestScriptRole(t *testing.T) {
+	fixture := kwinFixture{Windows: []fakeWindow{
+		{Caption: "browser", ResourceClass: "firefox", Role: "browser"},
+		{Caption: "downloads", ResourceClass: "firefox", Role: "Organizer"},
+		{Caption: "mail", ResourceClass: "thunderbird"},
+	}}
+	tests := []struct {
+		className string
+		role      string
+		want      []string
+	}{
+		{className: "firefox", role: "browser", want: []string{"browser"}},
+		{role: "Organizer", want: []string{"downloads"}},
+		{className: "firefox", role: "organizer"},
+	}
+	for _, tt := range tests {
+		params := testParams()
+		params.ClassNames = classNames(tt.className)
+		params.Role = tt.role
+		result := runKWinScript(t, params, fixture)
+		if got := result.activated(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("-f %q --role %q: activated %q, want %q", tt.className, tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestScriptResourceName(t *testing.T) {
+	fixture := kwinFixture{Windows: []fakeWindow{
+		{Caption: "browser", ResourceClass: "firefox", ResourceName: "Navigator"},
+		{Caption: "picture in picture", ResourceClass: "firefox", ResourceName: "Toolkit"},
+		{Caption: "mail", ResourceClass: "thunderbird"},
+	}}
+	tests := []struct {
+		className  string
+		name       string
+		ignoreCase bool
+		want       []string
+	}{
+		{className: "firefox", name: "Navigator", want: []string{"browser"}},
+		{name: "Toolkit", want: []string{"picture in picture"}},
+		{name: "navigator"},
+		{name: "navigator", ignoreCase: true, want: []string{"browser"}},
+	}
+	for _, tt := range tests {
+		params := testParams()
+		params.ClassNames = classNames(tt.className)
+		params.ResourceName = tt.name
+		params.IgnoreCase = tt.ignoreCase
+		result := runKWinScript(t, params, fixture)
+		if got := result.activated(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("-f %q -n %q ignoreCase=%v: activated %q, want %q", tt.className, tt.name, tt.ignoreCase, got, tt.want)
+		}
+	}
+}
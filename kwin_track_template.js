@@ -0,0 +1,20 @@
+/**
+ * Report every window that opens or closes to the jumpkwapp --track-windows process, which records
+ * when each window was opened. The script stays loaded until that process unloads it.
+ * @param {string} dbusAddr D-Bus address of the tracker
+ * @param {string} listenerPath Object path the tracker is exported on
+ * @param {string} listenerInterface Interface name the tracker is exported with
+ */
+function trackWindows(dbusAddr, listenerPath, listenerInterface) {
+    var added = workspace.windowAdded || workspace.clientAdded; // KWin 5 calls windows clients
+    var removed = workspace.windowRemoved || workspace.clientRemoved;
+
+    added.connect(function (client) {
+        callDBus(dbusAddr, listenerPath, listenerInterface, 'WindowOpened', String(client.internalId));
+    });
+    removed.connect(function (client) {
+        callDBus(dbusAddr, listenerPath, listenerInterface, 'WindowClosed', String(client.internalId));
+    });
+}
+
+trackWindows('{{.DBusAddress}}', '{{.ListenerPath}}', '{{.ListenerInterface}}');
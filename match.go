@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// The --match language is a small boolean expression over window properties,
+// compiled into a JavaScript predicate for the KWin script:
+//
+//	expr    = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | primary
+//	primary = "(" expr ")" | field op value | flag
+//	op      = "=" | "!=" | "~" | "!~"
+//
+// "=" compares exactly and "~" is a case-insensitive JavaScript regex. Values
+// are bare words or double-quoted strings with backslash escapes.
+
+// matchFields maps the string fields usable in --match to the JS expression
+// reading them from the window c.
+var matchFields = map[string]string{
+	"class":        "String(c.resourceClass)",
+	"name":         "String(c.resourceName)",
+	"caption":      "String(c.caption)",
+	"title":        "String(c.caption)",
+	"role":         "String(c.windowRole)",
+	"desktop-file": "String(c.desktopFileName)",
+	"id":           "String(c.internalId)",
+	"pid":          "String(c.pid)",
+}
+
+// matchFlags maps the boolean window properties usable in --match to JS.
+var matchFlags = map[string]string{
+	"minimized":       "!!c.minimized",
+	"fullscreen":      "!!c.fullScreen",
+	"maximized":       "(windowState(c) === 'maximized')",
+	"active":          "(c === workspace.activeWindow)",
+	"dialog":          "!!c.dialog",
+	"transient":       "!!c.transient",
+	"special":         "isSpecialWindow(c)",
+	"skip-taskbar":    "!isOnTaskbar(c)",
+	"on-all-desktops": "!!c.onAllDesktops",
+	"current-desktop": "isOnCurrentDesktop(c)",
+	"keep-above":      "!!c.keepAbove",
+}
+
+type matchToken struct {
+	kind  string // "op", "word", "string", or "eof"
+	value string
+	pos   int
+}
+
+// compileMatch compiles a --match expression into a JavaScript expression
+// over the window c. An empty expression compiles to an empty string.
+func compileMatch(src string) (string, error) {
+	if strings.TrimSpace(src) == "" {
+		return "", nil
+	}
+	tokens, err := lexMatch(src)
+	if err != nil {
+		return "", err
+	}
+	p := &matchParser{tokens: tokens}
+	js, err := p.parseOr()
+	if err != nil {
+		return "", err
+	}
+	if tok := p.peek(); tok.kind != "eof" {
+		return "", fmt.Errorf("unexpected %q at offset %d", tok.value, tok.pos)
+	}
+	return js, nil
+}
+
+func lexMatch(src string) ([]matchToken, error) {
+	var tokens []matchToken
+	for i := 0; i < len(src); {
+		r := rune(src[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.HasPrefix(src[i:], "&&"), strings.HasPrefix(src[i:], "||"),
+			strings.HasPrefix(src[i:], "!="), strings.HasPrefix(src[i:], "!~"):
+			tokens = append(tokens, matchToken{kind: "op", value: src[i : i+2], pos: i})
+			i += 2
+		case strings.ContainsRune("()!=~", r):
+			tokens = append(tokens, matchToken{kind: "op", value: string(r), pos: i})
+			i++
+		case r == '"':
+			value, n, err := lexMatchString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%v at offset %d", err, i)
+			}
+			tokens = append(tokens, matchToken{kind: "string", value: value, pos: i})
+			i += n
+		default:
+			start := i
+			for i < len(src) && !unicode.IsSpace(rune(src[i])) && !strings.ContainsRune("()!=~&|\"", rune(src[i])) {
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("unexpected %q at offset %d", src[i], i)
+			}
+			tokens = append(tokens, matchToken{kind: "word", value: src[start:i], pos: start})
+		}
+	}
+	return append(tokens, matchToken{kind: "eof", value: "end of expression", pos: len(src)}), nil
+}
+
+// lexMatchString reads the double-quoted string at the start of src and
+// returns its value and the number of bytes consumed.
+func lexMatchString(src string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			if i+1 == len(src) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			i++
+			b.WriteByte(src[i])
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(src[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+type matchParser struct {
+	tokens []matchToken
+	pos    int
+}
+
+func (p *matchParser) peek() matchToken {
+	return p.tokens[p.pos]
+}
+
+func (p *matchParser) next() matchToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != "eof" {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *matchParser) accept(op string) bool {
+	if tok := p.peek(); tok.kind == "op" && tok.value == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *matchParser) parseOr() (string, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return "", err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " || " + right + ")"
+	}
+	return left, nil
+}
+
+func (p *matchParser) parseAnd() (string, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return "", err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " && " + right + ")"
+	}
+	return left, nil
+}
+
+func (p *matchParser) parseUnary() (string, error) {
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return "", err
+		}
+		return "!(" + operand + ")", nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *matchParser) parsePrimary() (string, error) {
+	if p.accept("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return "", err
+		}
+		if !p.accept(")") {
+			tok := p.peek()
+			return "", fmt.Errorf("expected ) at offset %d, got %q", tok.pos, tok.value)
+		}
+		return "(" + inner + ")", nil
+	}
+
+	tok := p.next()
+	if tok.kind != "word" {
+		return "", fmt.Errorf("expected a field or flag at offset %d, got %q", tok.pos, tok.value)
+	}
+	name := strings.ToLower(tok.value)
+	if js, ok := matchFlags[name]; ok {
+		return js, nil
+	}
+	field, ok := matchFields[name]
+	if !ok {
+		return "", fmt.Errorf("unknown field %q at offset %d", tok.value, tok.pos)
+	}
+
+	op := p.next()
+	if op.kind != "op" || op.value == "!" || op.value == "(" || op.value == ")" || op.value == "&&" || op.value == "||" {
+		return "", fmt.Errorf("expected =, !=, ~, or !~ after %s at offset %d", tok.value, op.pos)
+	}
+	value := p.next()
+	if value.kind != "word" && value.kind != "string" {
+		return "", fmt.Errorf("expected a value after %s%s at offset %d", tok.value, op.value, value.pos)
+	}
+	literal := "'" + escapeForJS(value.value) + "'"
+
+	switch op.value {
+	case "=":
+		return "(" + field + " === " + literal + ")", nil
+	case "!=":
+		return "(" + field + " !== " + literal + ")", nil
+	case "~":
+		return "new RegExp(" + literal + ", 'i').test(" + field + ")", nil
+	default:
+		return "!new RegExp(" + literal + ", 'i').test(" + field + ")", nil
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompileMatch(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{src: "", want: ""},
+		{src: "  ", want: ""},
+		{src: "class=firefox", want: "(String(c.resourceClass) === 'firefox')"},
+		{src: "CLASS != firefox", want: "(String(c.resourceClass) !== 'firefox')"},
+		{src: `caption~"Gmail"`, want: "new RegExp('Gmail', 'i').test(String(c.caption))"},
+		{src: `title!~"^Inbox"`, want: "!new RegExp('^Inbox', 'i').test(String(c.caption))"},
+		{src: "minimized", want: "!!c.minimized"},
+		{src: "!minimized", want: "!(!!c.minimized)"},
+		{src: "pid=42", want: "(String(c.pid) === '42')"},
+		{
+			src:  `class=firefox && caption~"Gmail" && !minimized`,
+			want: "(((String(c.resourceClass) === 'firefox') && new RegExp('Gmail', 'i').test(String(c.caption))) && !(!!c.minimized))",
+		},
+		{
+			src:  "class=kate || class=konsole && active",
+			want: "((String(c.resourceClass) === 'kate') || ((String(c.resourceClass) === 'konsole') && (c === workspace.activeWindow)))",
+		},
+		{
+			src:  "(class=kate || class=konsole) && active",
+			want: "((((String(c.resourceClass) === 'kate') || (String(c.resourceClass) === 'konsole'))) && (c === workspace.activeWindow))",
+		},
+		{src: `caption="it's \"quoted\""`, want: `(String(c.caption) === 'it\'s "quoted"')`},
+		{src: `caption="</script>\\"`, want: `(String(c.caption) === '</script>\\')`},
+	}
+	for _, tt := range tests {
+		got, err := compileMatch(tt.src)
+		if err != nil {
+			t.Errorf("compileMatch(%q): %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("compileMatch(%q) =\n%s\nwant\n%s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestCompileMatchErrors(t *testing.T) {
+	tests := []struct {
+		src     string
+		wantErr string
+	}{
+		{src: "colour=red", wantErr: `unknown field "colour"`},
+		{src: "class", wantErr: "expected =, !=, ~, or !~ after class"},
+		{src: "class=", wantErr: "expected a value after class="},
+		{src: "class=firefox &&", wantErr: "expected a field or flag"},
+		{src: "class=firefox minimized", wantErr: `unexpected "minimized"`},
+		{src: "(class=firefox", wantErr: "expected )"},
+		{src: `caption="open`, wantErr: "unterminated string at offset 8"},
+		{src: `caption="open\`, wantErr: "unterminated string"},
+		{src: "class=a & b", wantErr: `unexpected '&'`},
+		{src: "class == firefox", wantErr: "expected a value after class="},
+	}
+	for _, tt := range tests {
+		_, err := compileMatch(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("compileMatch(%q) error = %v, want one containing %q", tt.src, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import "time"
+
+// activationStatePath returns the file recording when jumpkwapp last
+// activated each window, keyed by KWin internal window ID, for --mru.
+func activationStatePath() string {
+	return statePath("activations")
+}
+
+// recordActivation notes that the window with the given ID was activated at
+// now. The file is normally cleared with the rest of $XDG_RUNTIME_DIR at
+// logout, so records of closed windows are not pruned.
+func recordActivation(path, id string, now time.Time) error {
+	return updateState(path, func(times map[string]int64) {
+		times[id] = now.UnixMilli()
+	})
+}
+
+// cycleStatePath returns the file recording, for --persistent-cycle, the
+// internal ID of the window last activated for each set of filters.
+func cycleStatePath() string {
+	return statePath("cycle")
+}
+
+// recordCyclePosition notes that id was the window activated for the filters
+// described by filter.
+func recordCyclePosition(path, filter, id string) error {
+	return updateState(path, func(cycles map[string]string) {
+		cycles[filter] = id
+	})
+}
+
+// previousStatePath returns the file recording, for --toggle-back, the
+// internal ID of the window that was active before the last jump with each
+// set of filters.
+func previousStatePath() string {
+	return statePath("previous")
+}
+
+// recordPreviousWindow notes that id was the active window when the filters
+// described by filter last activated a match.
+func recordPreviousWindow(path, filter, id string) error {
+	return updateState(path, func(previous map[string]string) {
+		previous[filter] = id
+	})
+}
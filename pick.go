@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// errNothingPicked is returned when the --pick or --menu menu is dismissed
+// without a choice.
+var errNothingPicked = errors.New("no window picked")
+
+// menuCommands are the arguments that make known launchers read a menu from
+// stdin and print the chosen line, for --menu.
+var menuCommands = map[string][]string{
+	"rofi":   {"rofi", "-dmenu", "-i", "-p", "window"},
+	"dmenu":  {"dmenu", "-i", "-l", "20", "-p", "window"},
+	"fuzzel": {"fuzzel", "--dmenu", "--prompt=window> "},
+	"wofi":   {"wofi", "--dmenu", "--prompt=window"},
+}
+
+// pickWindow asks which of choices to activate and returns its internal ID.
+// With a menu program the choices are piped to it; otherwise fzf is used
+// when it is installed, and failing that the choices are numbered on out and
+// the number is read from in.
+func pickWindow(menu string, choices []windowInfo, in io.Reader, out io.Writer) (string, error) {
+	if menu != "" {
+		return pickWithMenu(menu, choices)
+	}
+	if _, err := exec.LookPath("fzf"); err == nil {
+		return pickWithFzf(choices)
+	}
+	return pickByNumber(choices, in, out)
+}
+
+// choiceLine formats a choice as the tab-separated line given to fzf.
+func choiceLine(choice windowInfo) string {
+	return fmt.Sprintf("%s\t%s\t%s\t%s", choice.InternalID, choice.ResourceClass, choice.Desktop, choice.Caption)
+}
+
+// menuLine formats the choice at index i for menus that show whole lines.
+// The number keeps lines for windows with the same caption apart.
+func menuLine(i int, choice windowInfo) string {
+	return fmt.Sprintf("%2d) %s  [%s, desktop %s]", i+1, choice.Caption, choice.ResourceClass, choice.Desktop)
+}
+
+// runMenu runs a menu program on lines and returns the line it printed.
+// Launchers exit with status 1 (fzf with 130) when dismissed.
+func runMenu(cmd *exec.Cmd, lines []string) (string, error) {
+	cmd.Stdin = strings.NewReader(strings.Join(lines, "\n") + "\n")
+	cmd.Stderr = os.Stderr
+	selected, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && (exitErr.ExitCode() == 1 || exitErr.ExitCode() == 130) {
+		return "", errNothingPicked
+	}
+	if err != nil {
+		return "", fmt.Errorf("run %s: %w", cmd.Args[0], err)
+	}
+	line := strings.TrimRight(string(selected), "\r\n")
+	if line == "" {
+		return "", errNothingPicked
+	}
+	return line, nil
+}
+
+func pickWithMenu(menu string, choices []windowInfo) (string, error) {
+	cmd := exec.Command("sh", "-c", menu)
+	if args, ok := menuCommands[menu]; ok {
+		cmd = exec.Command(args[0], args[1:]...)
+	}
+	lines := make([]string, len(choices))
+	for i, choice := range choices {
+		lines[i] = menuLine(i, choice)
+	}
+	selected, err := runMenu(cmd, lines)
+	if err != nil {
+		return "", err
+	}
+	for i, line := range lines {
+		if line == selected {
+			return choices[i].InternalID, nil
+		}
+	}
+	return "", fmt.Errorf("%s printed %q, which is not one of the windows", menu, selected)
+}
+
+func pickWithFzf(choices []windowInfo) (string, error) {
+	lines := make([]string, len(choices))
+	for i, choice := range choices {
+		lines[i] = choiceLine(choice)
+	}
+	// The ID is kept on each line so the choice can be told apart from
+	// windows with the same caption, but only the rest is shown.
+	selected, err := runMenu(exec.Command("fzf", "--delimiter=\t", "--with-nth=2..", "--prompt=window> "), lines)
+	if err != nil {
+		return "", err
+	}
+	id, _, _ := strings.Cut(selected, "\t")
+	return id, nil
+}
+
+func pickByNumber(choices []windowInfo, in io.Reader, out io.Writer) (string, error) {
+	for i, choice := range choices {
+		fmt.Fprintln(out, menuLine(i, choice))
+	}
+	fmt.Fprint(out, "window> ")
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && line == "" {
+		if errors.Is(err, io.EOF) {
+			return "", errNothingPicked
+		}
+		return "", err
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", errNothingPicked
+	}
+	n, err := strconv.Atoi(line)
+	if err != nil || n < 1 || n > len(choices) {
+		return "", fmt.Errorf("pick a number from 1 to %d, not %q", len(choices), line)
+	}
+	return choices[n-1].InternalID, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testChoices = []windowInfo{
+	{InternalID: "{1}", ResourceClass: "konsole", Caption: "shell", Desktop: "One"},
+	{InternalID: "{2}", ResourceClass: "konsole", Caption: "shell", Desktop: "Two"},
+	{InternalID: "{3}", ResourceClass: "konsole", Caption: "logs", Desktop: "all"},
+}
+
+func TestPickByNumber(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr error
+	}{
+		{input: "2\n", want: "{2}"},
+		{input: " 3 ", want: "{3}"},
+		{input: "\n", wantErr: errNothingPicked},
+		{input: "", wantErr: errNothingPicked},
+		{input: "4\n"},
+		{input: "shell\n"},
+	}
+	for _, tt := range tests {
+		// Without fzf on PATH the choices are numbered on the terminal.
+		t.Setenv("PATH", t.TempDir())
+		var out bytes.Buffer
+		got, err := pickWindow("", testChoices, strings.NewReader(tt.input), &out)
+		if tt.want == "" {
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("input %q: got %q, %v, want error %v", tt.input, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("input %q: got %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+		if !strings.Contains(out.String(), " 2) shell  [konsole, desktop Two]\n") {
+			t.Errorf("menu does not list the second choice:\n%s", out.String())
+		}
+	}
+}
+
+func TestPickWithFzf(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		want    string
+		wantErr error
+	}{
+		{name: "second line", script: "read line; read line; echo \"$line\"", want: "{2}"},
+		{name: "dismissed", script: "exit 130", wantErr: errNothingPicked},
+		{name: "no match", script: "exit 1", wantErr: errNothingPicked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "fzf"), []byte("#!/bin/sh\n"+tt.script+"\n"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("PATH", dir)
+			got, err := pickWindow("", testChoices, strings.NewReader(""), &bytes.Buffer{})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("got %q, %v, want error %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickWithMenu(t *testing.T) {
+	// A stand-in for rofi picks the second line when called in dmenu mode.
+	dir := t.TempDir()
+	rofi := "#!/bin/sh\n[ \"$1\" = -dmenu ] || exit 2\nIFS= read -r line; IFS= read -r line; echo \"$line\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "rofi"), []byte(rofi), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	tests := []struct {
+		menu    string
+		want    string
+		wantErr error
+	}{
+		{menu: "rofi", want: "{2}"},
+		{menu: `sed -n 3p`, want: "{3}"},
+		{menu: "exit 1", wantErr: errNothingPicked},
+		{menu: "true", wantErr: errNothingPicked},
+		{menu: "echo something else"},
+	}
+	for _, tt := range tests {
+		got, err := pickWindow(tt.menu, testChoices, strings.NewReader(""), &bytes.Buffer{})
+		if tt.want == "" {
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("--menu %q: got %q, %v, want error %v", tt.menu, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("--menu %q: got %q, %v, want %q", tt.menu, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// point is a position in global screen coordinates, as reported by the KWin
+// script.
+type point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// warpPointer moves the mouse pointer to p. KWin scripts cannot move the
+// pointer themselves, so this runs xdotool on X11 and ydotool on Wayland,
+// whichever the session is.
+func warpPointer(p point, getenv func(string) string) error {
+	x, y := strconv.Itoa(p.X), strconv.Itoa(p.Y)
+	var cmd *exec.Cmd
+	if getenv("XDG_SESSION_TYPE") == "wayland" {
+		cmd = exec.Command("ydotool", "mousemove", "--absolute", "-x", x, "-y", y)
+	} else {
+		cmd = exec.Command("xdotool", "mousemove", x, y)
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", cmd.Path, err)
+	}
+	return nil
+}
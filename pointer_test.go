@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWarpPointer(t *testing.T) {
+	tests := []struct {
+		session string
+		want    string
+	}{
+		{session: "x11", want: "xdotool mousemove 960 540"},
+		{session: "", want: "xdotool mousemove 960 540"},
+		{session: "wayland", want: "ydotool mousemove --absolute -x 960 -y 540"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.session, func(t *testing.T) {
+			// Stand-ins for xdotool and ydotool record how they were called.
+			dir := t.TempDir()
+			out := filepath.Join(dir, "out")
+			for _, tool := range []string{"xdotool", "ydotool"} {
+				script := "#!/bin/sh\necho " + tool + ` "$@" > ` + out + "\n"
+				if err := os.WriteFile(filepath.Join(dir, tool), []byte(script), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("PATH", dir)
+
+			getenv := func(key string) string {
+				if key == "XDG_SESSION_TYPE" {
+					return tt.session
+				}
+				return ""
+			}
+			if err := warpPointer(point{X: 960, Y: 540}, getenv); err != nil {
+				t.Fatalf("warpPointer: %v", err)
+			}
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.TrimSpace(string(got)) != tt.want {
+				t.Errorf("ran %q, want %q", strings.TrimSpace(string(got)), tt.want)
+			}
+		})
+	}
+}
+
+func TestWarpPointerMissingTool(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if err := warpPointer(point{}, func(string) string { return "" }); err == nil {
+		t.Error("warpPointer without xdotool: want an error")
+	}
+}
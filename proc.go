@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// processCmdline returns the command line of process pid as one
+// space-separated string.
+func processCmdline(pid int) (string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(bytes.ReplaceAll(data, []byte{0}, []byte{' '}))), nil
+}
+
+// windowPIDs returns the distinct owning processes of windows.
+func windowPIDs(windows []windowInfo) []int {
+	var pids []int
+	seen := make(map[int]bool)
+	for _, win := range windows {
+		if win.PID > 0 && !seen[win.PID] {
+			seen[win.PID] = true
+			pids = append(pids, win.PID)
+		}
+	}
+	return pids
+}
+
+// filterPIDs returns the pids for which keep reports true.
+func filterPIDs(pids []int, keep func(pid int) bool) []int {
+	var kept []int
+	for _, pid := range pids {
+		if keep(pid) {
+			kept = append(kept, pid)
+		}
+	}
+	return kept
+}
+
+// cmdlineContains returns a filterPIDs predicate keeping processes whose
+// command line contains substr. Processes that have exited or cannot be read
+// are dropped.
+func cmdlineContains(substr string, cmdline func(int) (string, error)) func(int) bool {
+	return func(pid int) bool {
+		line, err := cmdline(pid)
+		return err == nil && strings.Contains(line, substr)
+	}
+}
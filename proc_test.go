@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProcessCmdline(t *testing.T) {
+	line, err := processCmdline(os.Getpid())
+	if err != nil {
+		t.Skipf("no /proc: %v", err)
+	}
+	if want := strings.Join(os.Args, " "); line != want {
+		t.Errorf("processCmdline = %q, want %q", line, want)
+	}
+
+	if _, err := processCmdline(-1); err == nil {
+		t.Error("processCmdline(-1): want an error")
+	}
+}
+
+func TestWindowPIDs(t *testing.T) {
+	windows := []windowInfo{
+		{Caption: "work 1", PID: 100},
+		{Caption: "work 2", PID: 100},
+		{Caption: "personal", PID: 200},
+		{Caption: "no pid"},
+		{Caption: "shell", PID: 300},
+	}
+	if got, want := windowPIDs(windows), []int{100, 200, 300}; !reflect.DeepEqual(got, want) {
+		t.Errorf("windowPIDs = %v, want %v", got, want)
+	}
+}
+
+func TestCmdlineContains(t *testing.T) {
+	cmdlines := map[int]string{
+		100: "/usr/lib/firefox/firefox -P work",
+		200: "/usr/lib/firefox/firefox -P personal",
+		300: "konsole --profile work",
+	}
+	cmdline := func(pid int) (string, error) {
+		line, ok := cmdlines[pid]
+		if !ok {
+			return "", errors.New("no such process")
+		}
+		return line, nil
+	}
+	pids := []int{100, 200, 300, 400}
+	tests := []struct {
+		substr string
+		want   []int
+	}{
+		{substr: "-P work", want: []int{100}},
+		{substr: "work", want: []int{100, 300}},
+		{substr: "firefox", want: []int{100, 200}},
+		{substr: "thunderbird", want: nil},
+	}
+	for _, tt := range tests {
+		if got := filterPIDs(pids, cmdlineContains(tt.substr, cmdline)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("cmdlineContains(%q) kept %v, want %v", tt.substr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// Chromium extension and web app ids are 32 letters from a to p.
+	chromiumAppID = regexp.MustCompile(`^[a-p]{32}$`)
+	// PWAsForFirefox ids are ULIDs.
+	firefoxPWAID = regexp.MustCompile(`^[0-9A-Z]{26}$`)
+)
+
+// pwaMatch builds the --match expression for an installed web app, given
+// either its app id or its name.
+//
+// Chromium-based browsers name app windows crx_<id> on X11 (as the resource
+// name, the class being the browser's) and <browser>-<id>-<profile> on
+// Wayland, with a desktop file of the same name. PWAsForFirefox uses the
+// class FFPWA-<id>. A name is matched against the caption of any window that
+// follows one of those conventions.
+func pwaMatch(app string) string {
+	switch {
+	case chromiumAppID.MatchString(app):
+		return "(name=" + quoteMatchString("crx_"+app) +
+			" || class=" + quoteMatchString("crx_"+app) +
+			" || class~" + quoteMatchString("^[a-z-]+-"+app+"-") +
+			" || desktop-file~" + quoteMatchString("-"+app+"-") + ")"
+	case firefoxPWAID.MatchString(app):
+		return "(class=" + quoteMatchString("FFPWA-"+app) + ")"
+	default:
+		return "((name~" + quoteMatchString("^crx_") +
+			" || class~" + quoteMatchString("^(crx_|FFPWA-|[a-z-]+-[a-p]{32}-)") + ")" +
+			" && caption~" + quoteMatchString(regexp.QuoteMeta(app)) + ")"
+	}
+}
+
+// quoteMatchString quotes s as a --match string value.
+func quoteMatchString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// andMatch joins --match expressions with &&, skipping empty ones.
+func andMatch(exprs ...string) string {
+	var parts []string
+	for _, expr := range exprs {
+		if strings.TrimSpace(expr) != "" {
+			parts = append(parts, "("+expr+")")
+		}
+	}
+	return strings.Join(parts, " && ")
+}
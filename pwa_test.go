@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestPWAMatch(t *testing.T) {
+	const chromiumID = "agimnkijcaahngcdmfeangaknmldooml"
+	tests := []struct {
+		app  string
+		want string
+	}{
+		{
+			app: chromiumID,
+			want: `(name="crx_agimnkijcaahngcdmfeangaknmldooml" || class="crx_agimnkijcaahngcdmfeangaknmldooml"` +
+				` || class~"^[a-z-]+-agimnkijcaahngcdmfeangaknmldooml-" || desktop-file~"-agimnkijcaahngcdmfeangaknmldooml-")`,
+		},
+		{app: "01HQ8ZK4M7X9V2B3C4D5E6F7G8", want: `(class="FFPWA-01HQ8ZK4M7X9V2B3C4D5E6F7G8")`},
+		{
+			app:  "YouTube Music",
+			want: `((name~"^crx_" || class~"^(crx_|FFPWA-|[a-z-]+-[a-p]{32}-)") && caption~"YouTube Music")`,
+		},
+		{
+			app:  `C++ "Docs"`,
+			want: `((name~"^crx_" || class~"^(crx_|FFPWA-|[a-z-]+-[a-p]{32}-)") && caption~"C\\+\\+ \"Docs\"")`,
+		},
+	}
+	for _, tt := range tests {
+		got := pwaMatch(tt.app)
+		if got != tt.want {
+			t.Errorf("pwaMatch(%q) =\n%s\nwant\n%s", tt.app, got, tt.want)
+		}
+		if _, err := compileMatch(got); err != nil {
+			t.Errorf("pwaMatch(%q) does not compile: %v", tt.app, err)
+		}
+	}
+}
+
+func TestAndMatch(t *testing.T) {
+	tests := []struct {
+		exprs []string
+		want  string
+	}{
+		{exprs: nil, want: ""},
+		{exprs: []string{"", " "}, want: ""},
+		{exprs: []string{"active"}, want: "(active)"},
+		{exprs: []string{"class=a || class=b", "", "!minimized"}, want: "(class=a || class=b) && (!minimized)"},
+	}
+	for _, tt := range tests {
+		if got := andMatch(tt.exprs...); got != tt.want {
+			t.Errorf("andMatch(%q) = %q, want %q", tt.exprs, got, tt.want)
+		}
+	}
+}
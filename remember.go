@@ -0,0 +1,34 @@
+package main
+
+// savedGeometry is where a window was when --remember-geometry hid it, so it
+// can be put back exactly there when it is shown again.
+type savedGeometry struct {
+	X             int      `json:"x"`
+	Y             int      `json:"y"`
+	Width         int      `json:"width"`
+	Height        int      `json:"height"`
+	Screen        string   `json:"screen"`
+	Desktops      []string `json:"desktops"`
+	OnAllDesktops bool     `json:"onAllDesktops"`
+}
+
+// geometryStatePath returns the file holding saved geometries, keyed by KWin
+// internal window ID.
+func geometryStatePath() string {
+	return statePath("geometry")
+}
+
+// updateSavedGeometry applies the changes the KWin script reported to the
+// file at path: a window ID mapped to a geometry saves it, one mapped to nil
+// drops it.
+func updateSavedGeometry(path string, updates map[string]*savedGeometry) error {
+	return updateState(path, func(saved map[string]savedGeometry) {
+		for id, geometry := range updates {
+			if geometry == nil {
+				delete(saved, id)
+			} else {
+				saved[id] = *geometry
+			}
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSavedGeometry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geometry.json")
+	saved, err := loadState[savedGeometry](path)
+	if err != nil || len(saved) != 0 {
+		t.Fatalf("missing file: got %v, %v, want no geometries", saved, err)
+	}
+
+	shell := savedGeometry{X: 10, Y: 20, Width: 800, Height: 600, Screen: "DP-1", Desktops: []string{"desktop-2"}}
+	mail := savedGeometry{Width: 1024, Height: 768, OnAllDesktops: true}
+	if err := updateSavedGeometry(path, map[string]*savedGeometry{"{1}": &shell, "{2}": &mail}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := updateSavedGeometry(path, map[string]*savedGeometry{"{2}": nil}); err != nil {
+		t.Fatalf("drop: %v", err)
+	}
+	saved, err = loadState[savedGeometry](path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if want := map[string]savedGeometry{"{1}": shell}; !reflect.DeepEqual(saved, want) {
+		t.Errorf("saved = %+v, want %+v", saved, want)
+	}
+}
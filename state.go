@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// statePath returns the path of a state file kept between invocations. The
+// state refers to KWin internal window IDs, which only last as long as the
+// session; see stateDir.
+func statePath(name string) string {
+	return filepath.Join(stateDir(), "jumpkwapp-"+name+".json")
+}
+
+// stateDir returns the directory of the files kept between invocations:
+// $XDG_RUNTIME_DIR, which is private to the user and cleared at logout, or
+// else a jumpkwapp directory in the user's cache directory. The shared temp
+// directory is only used, through a per-user subdirectory, when there is no
+// home directory either.
+func stateDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "jumpkwapp")
+	}
+	return filepath.Join(os.TempDir(), "jumpkwapp-"+strconv.Itoa(os.Getuid()))
+}
+
+// openStateFile opens the state or lock file at path, creating it and its
+// directory as needed. The directory must belong to the user and not be
+// writable by anyone else, and a symlink in place of the file is refused, so
+// another user cannot redirect the writes.
+func openStateFile(path string, flag int) (*os.File, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !info.IsDir() || info.Mode().Perm()&0o022 != 0 || (ok && int(st.Uid) != os.Getuid()) {
+		return nil, fmt.Errorf("%s is not a private directory of the current user", dir)
+	}
+	return os.OpenFile(path, flag|syscall.O_NOFOLLOW, 0o600)
+}
+
+// loadState reads the JSON state at path into a map. A missing or empty file
+// holds an empty map.
+func loadState[V any](path string) (map[string]V, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]V{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return parseState[V](path, data)
+}
+
+// loadStateJSON reads the JSON state at path and re-encodes it, to be
+// rendered into the KWin script as an object literal.
+func loadStateJSON[V any](path string) (string, error) {
+	state, err := loadState[V](path)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func parseState[V any](path string, data []byte) (map[string]V, error) {
+	state := map[string]V{}
+	if len(data) == 0 {
+		return state, nil
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// updateState applies update to the JSON state at path. The file is read and
+// rewritten under an exclusive flock so concurrent invocations do not lose
+// each other's changes.
+func updateState[V any](path string, update func(state map[string]V)) error {
+	f, err := openStateFile(path, os.O_RDWR|os.O_CREATE)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("lock %s: %w", path, err)
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	state, err := parseState[V](path, data)
+	if err != nil {
+		return err
+	}
+	update(state)
+
+	data, err = json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = f.WriteAt(data, 0)
+	return err
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		runtime string
+		cache   string
+		home    string
+		want    string
+	}{
+		{name: "runtime dir", runtime: "/run/user/1000", cache: "/home/me/.cache", want: "/run/user/1000/jumpkwapp-activations.json"},
+		{name: "cache dir", cache: "/home/me/.cache", want: "/home/me/.cache/jumpkwapp/jumpkwapp-activations.json"},
+		{name: "home", home: "/home/me", want: "/home/me/.cache/jumpkwapp/jumpkwapp-activations.json"},
+		{
+			name: "no home",
+			want: filepath.Join(os.TempDir(), "jumpkwapp-"+strconv.Itoa(os.Getuid()), "jumpkwapp-activations.json"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_RUNTIME_DIR", tt.runtime)
+			t.Setenv("XDG_CACHE_HOME", tt.cache)
+			t.Setenv("HOME", tt.home)
+			if got := statePath("activations"); got != tt.want {
+				t.Errorf("statePath = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenStateFile(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target")
+	if err := os.WriteFile(target, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(dir, "link.json")); err != nil {
+		t.Fatal(err)
+	}
+	shared := filepath.Join(dir, "shared")
+	if err := os.Mkdir(shared, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(shared, 0o777); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "new file", path: filepath.Join(dir, "state.json")},
+		{name: "missing directory is created", path: filepath.Join(dir, "new", "state.json")},
+		{name: "symlink", path: filepath.Join(dir, "link.json"), wantErr: true},
+		{name: "directory writable by others", path: filepath.Join(shared, "state.json"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := openStateFile(tt.path, os.O_RDWR|os.O_CREATE)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("openStateFile error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer f.Close()
+			info, err := os.Stat(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info.Mode().Perm(); perm != 0o600 {
+				t.Errorf("file mode = %o, want 600", perm)
+			}
+		})
+	}
+
+	if info, err := os.Stat(filepath.Join(dir, "new")); err != nil || info.Mode().Perm() != 0o700 {
+		t.Errorf("created directory: %v, %v, want mode 700", info, err)
+	}
+
+	if _, err := loadState[int64](filepath.Join(dir, "link.json")); err == nil {
+		t.Error("loadState followed a symlink")
+	}
+}
+
+func TestLoadState(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		data    string // not written when empty
+		want    map[string]int64
+		wantErr bool
+	}{
+		{name: "missing", want: map[string]int64{}},
+		{name: "times", data: `{"{1}": 5, "{2}": 7}`, want: map[string]int64{"{1}": 5, "{2}": 7}},
+		{name: "corrupt", data: "not json", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".json")
+			if tt.data != "" {
+				if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := loadState[int64](path)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), path) {
+					t.Errorf("got error %v, want one naming %s", err, path)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadState = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordActivation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "activations.json")
+	first := time.UnixMilli(1000)
+	if err := recordActivation(path, "{1}", first); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordActivation(path, "{2}", first.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordActivation(path, "{1}", first.Add(2*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadStateJSON[int64](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"{1}":3000,"{2}":2000}`; got != want {
+		t.Errorf("state = %s, want %s", got, want)
+	}
+}
+
+func TestRecordPreviousWindow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "previous.json")
+	if err := recordPreviousWindow(path, "class=konsole", "{mail}"); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordPreviousWindow(path, "class=konsole", "{browser}"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadState[string](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"class=konsole": "{browser}"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("state = %v, want %v", got, want)
+	}
+}
+
+func TestRecordCyclePosition(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cycle.json")
+	for _, rec := range []struct{ filter, id string }{
+		{"class=konsole", "{1}"},
+		{"class=firefox", "{7}"},
+		{"class=konsole", "{2}"},
+	} {
+		if err := recordCyclePosition(path, rec.filter, rec.id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := loadState[string](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"class=konsole": "{2}", "class=firefox": "{7}"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("state = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statsRecord is one line of the --stats-file log.
+type statsRecord struct {
+	Time    time.Time
+	Filter  string
+	Action  string
+	Matched int
+}
+
+// appendStats appends rec to the CSV file at path as
+// "timestamp,filter,action,matched". The line goes out in a single write on an
+// O_APPEND descriptor, so concurrent invocations never interleave records.
+func appendStats(path string, rec statsRecord) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write([]string{
+		rec.Time.Format(time.RFC3339),
+		rec.Filter,
+		rec.Action,
+		strconv.Itoa(rec.Matched),
+	}); err != nil {
+		return err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// describeFilter summarizes the filters of cfg for the stats log, e.g.
+// "class=firefox desktop=current".
+func describeFilter(cfg config) string {
+	var parts []string
+	add := func(key, value string) {
+		if value != "" {
+			parts = append(parts, fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+	add("profile", cfg.profile)
+	add("class", strings.Join(cfg.filterClasses, ","))
+	add("regex", cfg.filterRegex)
+	add("caption", cfg.filterAlt)
+	add("title", cfg.filterTitle)
+	add("caption-exact", cfg.captionExact)
+	add("fuzzy", cfg.fuzzy)
+	add("cmdline", cfg.cmdline)
+	if cfg.openedWithin > 0 {
+		add("opened-within", cfg.openedWithin.String())
+	}
+	add("desktop-file", cfg.desktopFile)
+	add("name", cfg.resourceName)
+	add("role", cfg.role)
+	add("match", cfg.match)
+	add("exclude-class", strings.Join(cfg.excludeClasses, ","))
+	add("exclude-caption", strings.Join(cfg.excludeCaptions, ","))
+	add("id", cfg.windowID)
+	if cfg.pid != 0 {
+		add("pid", strconv.Itoa(cfg.pid))
+	}
+	if cfg.allFilters {
+		add("all-filters", "true")
+	}
+	if cfg.currentDesktop {
+		add("desktop", "current")
+	}
+	add("desktop", cfg.desktop)
+	if cfg.visibleOnly {
+		add("visible", "only")
+	}
+	if cfg.minimizedOnly {
+		add("minimized", "only")
+	}
+	if cfg.skipMinimized {
+		add("minimized", "skip")
+	}
+	add("state", cfg.state)
+	add("activity", cfg.activity)
+	if cfg.currentActivity {
+		add("activity", "current")
+	}
+	add("screen", cfg.screen)
+	if cfg.currentScreen {
+		add("screen", "current")
+	}
+	return strings.Join(parts, " ")
+}
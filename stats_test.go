@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.csv")
+	at := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	records := []statsRecord{
+		{Time: at, Filter: "class=firefox", Action: "activated", Matched: 2},
+		{Time: at, Filter: `title=a, "b"`, Action: "launched"},
+	}
+	for _, rec := range records {
+		if err := appendStats(path, rec); err != nil {
+			t.Fatalf("appendStats: %v", err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2024-05-01T09:30:00Z,class=firefox,activated,2\n" +
+		`2024-05-01T09:30:00Z,"title=a, ""b""",launched,0` + "\n"
+	if string(data) != want {
+		t.Errorf("stats file =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestDescribeFilter(t *testing.T) {
+	tests := []struct {
+		cfg  config
+		want string
+	}{
+		{cfg: config{}, want: ""},
+		{cfg: config{filterClasses: []string{"firefox"}, currentDesktop: true}, want: "class=firefox desktop=current"},
+		{cfg: config{profile: "term", filterRegex: "^konsole$"}, want: "profile=term regex=^konsole$"},
+		{cfg: config{filterAlt: "YouTube"}, want: "caption=YouTube"},
+		{cfg: config{filterTitle: "Inbox"}, want: "title=Inbox"},
+		{cfg: config{captionExact: "Inbox"}, want: "caption-exact=Inbox"},
+		{cfg: config{fuzzy: "ffx"}, want: "fuzzy=ffx"},
+		{cfg: config{cmdline: "--profile work"}, want: "cmdline=--profile work"},
+		{cfg: config{openedWithin: 30 * time.Second}, want: "opened-within=30s"},
+		{cfg: config{match: "class=firefox && !minimized"}, want: "match=class=firefox && !minimized"},
+		{cfg: config{filterClasses: []string{"firefox"}, filterAlt: "YouTube", allFilters: true}, want: "class=firefox caption=YouTube all-filters=true"},
+		{cfg: config{match: "all", allFilters: true}, want: "match=all all-filters=true"},
+		{cfg: config{filterClasses: []string{"thunderbird"}, excludeCaptions: []string{"^Write:", "Settings"}}, want: "class=thunderbird exclude-caption=^Write:,Settings"},
+		{cfg: config{windowID: "{1}"}, want: "id={1}"},
+		{cfg: config{desktopFile: "org.mozilla.firefox", role: "browser"}, want: "desktop-file=org.mozilla.firefox role=browser"},
+		{cfg: config{resourceName: "navigator"}, want: "name=navigator"},
+		{cfg: config{filterClasses: []string{"konsole"}, pid: 4242}, want: "class=konsole pid=4242"},
+		{cfg: config{filterClasses: []string{"kate", "konsole"}}, want: "class=kate,konsole"},
+		{cfg: config{filterClasses: []string{"kate"}, screen: "DP-1"}, want: "class=kate screen=DP-1"},
+		{cfg: config{filterClasses: []string{"kate"}, currentScreen: true}, want: "class=kate screen=current"},
+		{cfg: config{filterClasses: []string{"kate"}, currentActivity: true}, want: "class=kate activity=current"},
+		{cfg: config{filterClasses: []string{"kate"}, minimizedOnly: true}, want: "class=kate minimized=only"},
+		{cfg: config{filterClasses: []string{"kate"}, visibleOnly: true}, want: "class=kate visible=only"},
+		{cfg: config{filterClasses: []string{"kate"}, desktop: "2"}, want: "class=kate desktop=2"},
+	}
+	for _, tt := range tests {
+		if got := describeFilter(tt.cfg); got != tt.want {
+			t.Errorf("describeFilter(%+v) = %q, want %q", tt.cfg, got, tt.want)
+		}
+	}
+}
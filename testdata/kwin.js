@@ -0,0 +1,319 @@
+// kwin.js runs a rendered jumpkwapp script against a fake KWin workspace so
+// the Go tests can check what the script does, not just what it contains.
+//
+// Usage: node kwin.js SCRIPT FIXTURE
+//
+// FIXTURE is a JSON file describing the windows (see kwinFixture in
+// kwin_script_test.go). The result is printed to stdout as JSON: every call
+// the script made into KWin, the D-Bus calls it sent, and the final state of
+// each window.
+'use strict';
+
+const fs = require('fs');
+const vm = require('vm');
+
+const script = fs.readFileSync(process.argv[2], 'utf8');
+const fixture = JSON.parse(fs.readFileSync(process.argv[3], 'utf8'));
+
+const log = [];
+const dbusCalls = [];
+
+function signal() {
+    const handlers = [];
+    return {
+        connect(handler) { handlers.push(handler); },
+        disconnect(handler) {
+            const i = handlers.indexOf(handler);
+            if (i >= 0) {
+                handlers.splice(i, 1);
+            }
+        },
+        emit(...args) { handlers.slice().forEach((handler) => handler(...args)); },
+    };
+}
+
+const desktops = (fixture.desktops || ['One', 'Two']).map((name, i) => ({
+    id: 'desktop-' + (i + 1),
+    name: name,
+    x11DesktopNumber: i + 1,
+    toString() { return this.name; },
+}));
+
+const screens = (fixture.screens || ['DP-1', 'HDMI-1']).map((name, i) => ({
+    name: name,
+    geometry: { x: i * 1920, y: 0, width: 1920, height: 1080 },
+    toString() { return this.name; },
+}));
+
+function desktopByName(name) {
+    const desktop = desktops.find((d) => d.name === name);
+    if (!desktop) {
+        throw new Error('fixture names unknown desktop ' + name);
+    }
+    return desktop;
+}
+
+function screenByName(name) {
+    const screen = screens.find((s) => s.name === name);
+    if (!screen) {
+        throw new Error('fixture names unknown screen ' + name);
+    }
+    return screen;
+}
+
+let topOfStack = 0;
+
+function makeWindow(spec) {
+    const window = Object.assign({
+        resourceName: String(spec.resourceClass || '').toLowerCase(),
+        pid: 0,
+        normalWindow: true,
+        dialog: false,
+        specialWindow: false,
+        minimizable: true,
+        minimized: false,
+        skipTaskbar: false,
+        skipSwitcher: false,
+        onAllDesktops: false,
+        keepAbove: false,
+        keepBelow: false,
+        fullScreen: false,
+        shade: false,
+        opacity: 1,
+        activities: [],
+    }, spec);
+    window.internalId = spec.internalId || '{' + spec.caption + '}';
+    window.stackingOrder = spec.stackingOrder !== undefined ? spec.stackingOrder : ++topOfStack;
+    topOfStack = Math.max(topOfStack, window.stackingOrder);
+    window.desktops = (spec.desktops || [desktops[0].name]).map(desktopByName);
+    if (fixture.desktopCopies) {
+        // KWin does not promise to hand out the same wrapper object for a
+        // desktop every time, so scripts must compare desktops by id.
+        window.desktops = window.desktops.map((d) => Object.assign({}, d));
+    }
+    window.output = screenByName(spec.output || screens[0].name);
+    window.frameGeometry = Object.assign({}, spec.frameGeometry || {
+        x: window.output.geometry.x + 100,
+        y: window.output.geometry.y + 100,
+        width: 800,
+        height: 600,
+    });
+    // Opacity changes are logged so timed effects can be checked step by step.
+    let opacity = window.opacity;
+    Object.defineProperty(window, 'opacity', {
+        get() { return opacity; },
+        set(value) {
+            log.push(['opacity', window.caption, value]);
+            opacity = value;
+        },
+        enumerable: true,
+    });
+    window.closeWindow = function () {
+        log.push(['close', this.caption]);
+        windows.splice(windows.indexOf(this), 1);
+    };
+    window.setMaximize = function (vertically, horizontally) {
+        log.push(['maximize', this.caption, vertically, horizontally]);
+        this.maximizeMode = (vertically ? 1 : 0) | (horizontally ? 2 : 0);
+    };
+    return window;
+}
+
+const windows = (fixture.windows || []).map(makeWindow);
+// transientFor names the parent window by caption in the fixture.
+for (const window of windows) {
+    if (typeof window.transientFor === 'string') {
+        window.transientFor = windows.find((w) => w.caption === window.transientFor) || null;
+    }
+}
+let activeWindow = windows.find((w) => w.caption === fixture.active) || null;
+// Wayland sometimes ignores activation requests; the fixture can make the
+// first few of them be dropped.
+let ignoredActivations = fixture.ignoredActivations || 0;
+// On X11 the active window only changes once KWin sees the focus event; with
+// deferredActivation requests take effect after the script has returned.
+const deferred = [];
+
+function raise(window) {
+    window.stackingOrder = ++topOfStack;
+}
+
+function activate(window) {
+    activeWindow = window;
+    if (window) {
+        window.minimized = false;
+        raise(window);
+        workspace.windowActivated.emit(window);
+    }
+}
+
+const workspace = {
+    desktops: desktops,
+    currentDesktop: desktopByName(fixture.currentDesktop || desktops[0].name),
+    screens: screens,
+    activeScreen: screenByName(fixture.activeScreen || screens[0].name),
+    currentActivity: fixture.currentActivity,
+    activities: fixture.activities,
+    windowList() { return windows.slice(); },
+    get stackingOrder() {
+        return windows.slice().sort((a, b) => a.stackingOrder - b.stackingOrder);
+    },
+    get activeWindow() { return activeWindow; },
+    set activeWindow(window) {
+        log.push(['activate', window ? window.caption : null]);
+        if (ignoredActivations > 0) {
+            ignoredActivations--;
+            return;
+        }
+        if (fixture.deferredActivation) {
+            deferred.push(window);
+            return;
+        }
+        activate(window);
+    },
+    raiseWindow(window) {
+        log.push(['raise', window.caption]);
+        raise(window);
+    },
+    slotWindowLower() {
+        log.push(['lower', activeWindow ? activeWindow.caption : null]);
+        if (activeWindow) {
+            activeWindow.stackingOrder = Math.min(...windows.map((w) => w.stackingOrder)) - 1;
+        }
+    },
+    clientArea(option, screen) {
+        return Object.assign({}, (screen && screen.geometry) || workspace.activeScreen.geometry);
+    },
+    sendClientToScreen(window, screen) {
+        log.push(['sendToScreen', window.caption, screen.name]);
+        window.output = screen;
+    },
+    createDesktop(position, name) {
+        const desktop = { id: 'desktop-' + (desktops.length + 1), name: name, x11DesktopNumber: desktops.length + 1 };
+        desktops.splice(position, 0, desktop);
+        log.push(['createDesktop', name]);
+        return desktop;
+    },
+    windowAdded: signal(),
+    windowRemoved: signal(),
+    windowActivated: signal(),
+};
+for (const zone of ['Left', 'Right', 'Top', 'Bottom', 'TopLeft', 'TopRight', 'BottomLeft', 'BottomRight']) {
+    workspace['slotWindowQuickTile' + zone] = () => {
+        log.push(['quickTile', activeWindow ? activeWindow.caption : null, zone]);
+    };
+}
+
+// QTimer is driven by a simulated clock so timeouts fire without waiting.
+let clock = 0;
+let timers = [];
+
+function QTimer() {
+    this.interval = 0;
+    this.singleShot = false;
+    this.active = false;
+    this.timeout = signal();
+}
+QTimer.prototype.start = function (interval) {
+    if (interval !== undefined) {
+        this.interval = interval;
+    }
+    this.stop();
+    this.active = true;
+    timers.push({ timer: this, due: clock + this.interval });
+};
+QTimer.prototype.stop = function () {
+    this.active = false;
+    timers = timers.filter((t) => t.timer !== this);
+};
+
+function runTimers() {
+    for (let fired = 0; timers.length > 0; fired++) {
+        if (fired > 10000) {
+            throw new Error('timers never settle');
+        }
+        timers.sort((a, b) => a.due - b.due);
+        const next = timers.shift();
+        clock = next.due;
+        if (next.timer.singleShot) {
+            next.timer.active = false;
+        } else {
+            timers.push({ timer: next.timer, due: clock + Math.max(next.timer.interval, 1) });
+        }
+        next.timer.timeout.emit();
+    }
+}
+
+function callDBus(service, path, iface, method, ...args) {
+    if (args.length > 0 && typeof args[args.length - 1] === 'function') {
+        args.pop();
+    }
+    dbusCalls.push({ service: service, path: path, interface: iface, method: method, args: args.map(String) });
+}
+
+const prints = [];
+
+const context = vm.createContext({
+    workspace: workspace,
+    QTimer: QTimer,
+    callDBus: callDBus,
+    print: (...args) => prints.push(args.join(' ')),
+    KWin: { PlacementArea: 0, MovementArea: 1, MaximizeArea: 2, MaximizeFullArea: 3, FullScreenArea: 4, WorkArea: 7, ScreenArea: 8 },
+    readConfig: (key, fallback) => fallback,
+});
+
+function settle() {
+    while (deferred.length > 0) {
+        activate(deferred.shift());
+    }
+    runTimers();
+    if (deferred.length > 0) {
+        settle();
+    }
+}
+
+vm.runInContext(script, context, { filename: 'script.js' });
+
+// Added windows open at their addedAt time on the simulated clock, after
+// whatever the script did right away.
+for (const spec of fixture.added || []) {
+    const opener = new QTimer();
+    opener.singleShot = true;
+    opener.timeout.connect(() => {
+        const window = makeWindow(spec);
+        windows.push(window);
+        if (fixture.focusAdded) {
+            activate(window);
+        }
+        workspace.windowAdded.emit(window);
+    });
+    opener.start(spec.addedAt || 0);
+}
+settle();
+
+const state = {};
+for (const window of windows) {
+    state[window.caption] = {
+        minimized: window.minimized,
+        stackingOrder: window.stackingOrder,
+        desktops: window.desktops.map((d) => d.name),
+        output: window.output.name,
+        activities: window.activities.map(String),
+        onAllDesktops: window.onAllDesktops,
+        demandsAttention: Boolean(window.demandsAttention),
+        keepAbove: window.keepAbove,
+        fullScreen: window.fullScreen,
+        shade: window.shade,
+        opacity: window.opacity,
+        frameGeometry: window.frameGeometry,
+    };
+}
+
+process.stdout.write(JSON.stringify({
+    log: log,
+    dbus: dbusCalls,
+    prints: prints,
+    active: activeWindow ? activeWindow.caption : '',
+    currentDesktop: workspace.currentDesktop.name,
+    windows: state,
+}));
@@ -0,0 +1,133 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+//go:embed kwin_track_template.js
+var kwinTrackTemplate string
+
+// trackPluginName is the name the --track-windows script is loaded under, so
+// a script left loaded by a tracker that did not exit cleanly is replaced
+// rather than kept reporting to nobody.
+const trackPluginName = "jumpkwapp-track"
+
+// openedStatePath returns the file recording when each window was opened,
+// keyed by KWin internal window ID, for --opened-within, --newest, and
+// --oldest. It only exists while jumpkwapp --track-windows is running.
+func openedStatePath() string {
+	return statePath("opened")
+}
+
+// tracking reports whether jumpkwapp --track-windows is recording opening
+// times to path.
+func tracking(path string) bool {
+	_, err := os.Lstat(path)
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+// recordOpened notes that the window with the given ID was opened at now.
+func recordOpened(path, id string, now time.Time) error {
+	return updateState(path, func(times map[string]int64) {
+		times[id] = now.UnixMilli()
+	})
+}
+
+// forgetWindow drops the opening time of a window that was closed.
+func forgetWindow(path, id string) error {
+	return updateState(path, func(times map[string]int64) {
+		delete(times, id)
+	})
+}
+
+// windowTracker receives the window events of the --track-windows script.
+type windowTracker struct {
+	path string
+	now  func() time.Time
+}
+
+// WindowOpened is called by the script for every window KWin adds.
+func (t *windowTracker) WindowOpened(id string) *dbus.Error {
+	if err := recordOpened(t.path, id, t.now()); err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: record opened window: %v\n", err)
+	}
+	return nil
+}
+
+// WindowClosed is called by the script for every window KWin removes.
+func (t *windowTracker) WindowClosed(id string) *dbus.Error {
+	if err := forgetWindow(t.path, id); err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: forget closed window: %v\n", err)
+	}
+	return nil
+}
+
+// trackWindows loads a resident KWin script that reports every window that
+// opens or closes, and records when each was opened until it is interrupted.
+// KWin does not tell when a window was opened, so windows already open when
+// it starts have no recorded time.
+func trackWindows(conn *dbus.Conn, tmpDir string, listenerPath dbus.ObjectPath, listenerIface string) error {
+	path := openedStatePath()
+	// Times left by an earlier tracker may name windows that have since
+	// closed, or miss windows opened while none was running.
+	if err := updateState(path, func(times map[string]int64) { clear(times) }); err != nil {
+		return fmt.Errorf("reset opening times: %w", err)
+	}
+	defer os.Remove(path)
+
+	dbusAddress, err := getUniqueName(conn)
+	if err != nil {
+		return fmt.Errorf("get unique bus name: %w", err)
+	}
+	script, err := renderListenerScript("kwin-track-script", kwinTrackTemplate, dumpParams{
+		DBusAddress:       dbusAddress,
+		ListenerPath:      string(listenerPath),
+		ListenerInterface: listenerIface,
+	})
+	if err != nil {
+		return fmt.Errorf("render KWin track script: %w", err)
+	}
+
+	tracker := &windowTracker{path: path, now: time.Now}
+	if err := conn.Export(tracker, listenerPath, listenerIface); err != nil {
+		return fmt.Errorf("export listener on D-Bus: %w", err)
+	}
+	defer func() {
+		_ = conn.Export(nil, listenerPath, listenerIface)
+	}()
+
+	scriptFile, err := writeTempScript(tmpDir, script)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(scriptFile)
+
+	scripting := conn.Object(kwinService, dbus.ObjectPath(kwinScriptingPath))
+	_ = scripting.Call(kwinScriptingIface+".unloadScript", 0, trackPluginName).Err
+	scriptPath, err := loadKWinScript(conn, scriptFile, trackPluginName)
+	if err != nil {
+		return err
+	}
+	scriptObj := conn.Object(kwinService, scriptPath)
+	defer func() {
+		_ = stopScript(scriptObj)
+	}()
+
+	if err := scriptObj.Call(kwinScriptIface+".run", 0).Err; err != nil {
+		return fmt.Errorf("run KWin script: %w", err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrackScript(t *testing.T) {
+	script, err := renderListenerScript("kwin-track-script", kwinTrackTemplate, dumpParams{
+		DBusAddress:       ":1.42",
+		ListenerPath:      "/org/jumpkwapp/Listener",
+		ListenerInterface: "org.jumpkwapp.Listener",
+	})
+	if err != nil {
+		t.Fatalf("renderListenerScript: %v", err)
+	}
+	result := runScript(t, script, kwinFixture{
+		Windows: []fakeWindow{{Caption: "mail", ResourceClass: "thunderbird", InternalID: "{1}"}},
+		Added: []fakeWindow{
+			{Caption: "shell", ResourceClass: "konsole", InternalID: "{2}", AddedAt: 100},
+			{Caption: "browser", ResourceClass: "firefox", InternalID: "{3}", AddedAt: 200},
+		},
+	})
+	var opened []string
+	for _, call := range result.DBus {
+		if call.Method != "WindowOpened" || call.Service != ":1.42" {
+			t.Errorf("unexpected D-Bus call %+v", call)
+			continue
+		}
+		opened = append(opened, call.Args...)
+	}
+	if want := []string{"{2}", "{3}"}; !reflect.DeepEqual(opened, want) {
+		t.Errorf("reported %q opened, want %q", opened, want)
+	}
+}
+
+func TestWindowTracker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jumpkwapp-opened.json")
+	if tracking(path) {
+		t.Error("tracking before the tracker ran: got true")
+	}
+	now := time.UnixMilli(1_700_000_000_000)
+	tracker := &windowTracker{path: path, now: func() time.Time { return now }}
+
+	tests := []struct {
+		event string
+		id    string
+		want  map[string]int64
+	}{
+		{event: "opened", id: "{1}", want: map[string]int64{"{1}": now.UnixMilli()}},
+		{event: "opened", id: "{2}", want: map[string]int64{"{1}": now.UnixMilli(), "{2}": now.Add(time.Second).UnixMilli()}},
+		{event: "closed", id: "{1}", want: map[string]int64{"{2}": now.Add(time.Second).UnixMilli()}},
+		{event: "closed", id: "{3}", want: map[string]int64{"{2}": now.Add(time.Second).UnixMilli()}},
+	}
+	for i, tt := range tests {
+		now = time.UnixMilli(1_700_000_000_000).Add(time.Duration(i) * time.Second)
+		if tt.event == "opened" {
+			tracker.WindowOpened(tt.id)
+		} else {
+			tracker.WindowClosed(tt.id)
+		}
+		got, err := loadState[int64](path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("after %s %s: times = %v, want %v", tt.id, tt.event, got, tt.want)
+		}
+	}
+	if !tracking(path) {
+		t.Error("tracking while the tracker runs: got false")
+	}
+}